@@ -1,11 +1,125 @@
 package client
 
 import (
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/utils"
 	"github.com/sirupsen/logrus"
 )
 
-// Client represents a system client. At the moment, this is only
-// to configure a global logger.
+// Client represents a system client. It configures a global logger and
+// how changes are made to the system.
 type Client struct {
 	Logger *logrus.Logger
+
+	// DryRun will cause resources to report the changes they would make
+	// instead of making them.
+	DryRun bool
+
+	// Plan records the changes which were not made because DryRun is
+	// enabled. If Plan is nil, the changes are only logged.
+	Plan *Plan
+
+	// Executor runs commands and accesses files on the managed system.
+	// If not set, the local system is managed.
+	Executor executor.Executor
+
+	// Backup is the policy used to keep copies of files before resources
+	// replace them. If Backup.Dir is empty, no copies are kept.
+	Backup executor.BackupOpts
+
+	// Deferred holds the actions which resources defer until they are
+	// needed or the run ends. If Deferred is nil, actions are run as
+	// soon as they are deferred.
+	Deferred *Deferred
+}
+
+// System returns the executor of the managed system.
+func (c Client) System() executor.Executor {
+	if c.Executor == nil {
+		return executor.Local{}
+	}
+
+	return c.Executor
+}
+
+// Pending determines if an action on a resource must be skipped because
+// the client is in dry-run mode. If so, the action is logged and added
+// to the client's plan.
+//
+// The detail describes the change, such as the command which would
+// have been run.
+func (c Client) Pending(resourceType, name, action, detail string) bool {
+	if !c.DryRun {
+		return false
+	}
+
+	c.Logger.Infof("[dry-run] Would %s %s %s: %s", action, resourceType, name, detail)
+
+	if c.Plan != nil {
+		c.Plan.Add(Step{
+			Type:   resourceType,
+			Name:   name,
+			Action: action,
+			Detail: detail,
+		})
+	}
+
+	return true
+}
+
+// Defer will defer an action until it is run with RunDeferred or the end
+// of the run, so that it is only run once for many changes. If the
+// client does not defer actions, the action is run now.
+func (c Client) Defer(action DeferredAction) error {
+	if c.Deferred == nil {
+		return action.Run(c)
+	}
+
+	c.Logger.Debugf("Deferring %s", action.Name)
+	c.Deferred.Add(action)
+
+	return nil
+}
+
+// RunDeferred will run an action if it has been deferred, such as before
+// a resource which depends on it is changed.
+func (c Client) RunDeferred(name string) error {
+	if c.Deferred == nil {
+		return nil
+	}
+
+	return c.Deferred.Run(c, name)
+}
+
+// WriteFile will atomically replace a file on the managed system. Unless
+// the options set a backup policy, the client's backup policy is used.
+func (c Client) WriteFile(name string, data []byte, writeOpts executor.WriteOpts) error {
+	c.Logger.Debugf("Writing %s", name)
+
+	if writeOpts.Backup.Dir == "" {
+		writeOpts.Backup = c.Backup
+	}
+
+	return executor.Write(c.System(), name, data, writeOpts)
+}
+
+// Exec will run a command on the managed system. The command and its
+// output are logged at the debug level as the command runs, unless the
+// options already handle the output.
+func (c Client) Exec(eo utils.ExecOptions) (utils.ExecResult, error) {
+	c.Logger.Debugf("Running %s", eo.String())
+
+	if eo.OnStdout == nil {
+		eo.OnStdout = func(line string) {
+			c.Logger.Debugf("stdout: %s", line)
+		}
+	}
+
+	if eo.OnStderr == nil {
+		eo.OnStderr = func(line string) {
+			c.Logger.Debugf("stderr: %s", line)
+		}
+	}
+
+	return c.System().Exec(eo)
 }
@@ -0,0 +1,79 @@
+package client
+
+import "sync"
+
+// DeferredAction is an action which a resource deferred so that it is
+// only run once for many changes, such as reloading systemd after unit
+// files were written.
+type DeferredAction struct {
+	// Type is the type of the resource which deferred the action.
+	Type string
+
+	// Name identifies the action, such as "systemd-daemon-reload".
+	// An action is only deferred once per name.
+	Name string
+
+	// Run runs the action.
+	Run func(client Client) error
+}
+
+// Deferred holds the actions which were deferred during a run. Actions
+// may be deferred by resources which are applied concurrently.
+type Deferred struct {
+	actions []DeferredAction
+
+	mu sync.Mutex
+
+	// running is held while an action runs so that a resource which
+	// needs the action waits until it has finished.
+	running sync.Mutex
+}
+
+// Add will defer an action unless an action with the same name is
+// already deferred.
+func (d *Deferred) Add(action DeferredAction) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, a := range d.actions {
+		if a.Name == action.Name {
+			return
+		}
+	}
+
+	d.actions = append(d.actions, action)
+}
+
+// Run will run a deferred action and remove it. Nothing is done if no
+// action with the name is deferred.
+func (d *Deferred) Run(c Client, name string) error {
+	d.running.Lock()
+	defer d.running.Unlock()
+
+	d.mu.Lock()
+	var action *DeferredAction
+	for i, a := range d.actions {
+		if a.Name == name {
+			action = &a
+			d.actions = append(d.actions[:i], d.actions[i+1:]...)
+			break
+		}
+	}
+	d.mu.Unlock()
+
+	if action == nil {
+		return nil
+	}
+
+	return action.Run(c)
+}
+
+// Take will remove and return every deferred action in the order they
+// were deferred.
+func (d *Deferred) Take() (actions []DeferredAction) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	actions, d.actions = d.actions, nil
+	return
+}
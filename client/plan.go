@@ -0,0 +1,51 @@
+package client
+
+import "sync"
+
+// Step represents an action which would have been taken on a resource.
+type Step struct {
+	// Type is the type of the resource.
+	Type string
+
+	// Name is the name of the resource.
+	Name string
+
+	// Action is the action, such as "create", "update", or "delete".
+	Action string
+
+	// Detail describes the change, such as the command which would
+	// have been run.
+	Detail string
+}
+
+// Plan represents the actions which would have been taken on a system.
+// Steps may be added by resources which are applied concurrently.
+type Plan struct {
+	steps []Step
+
+	mu sync.Mutex
+}
+
+// Add will add a step to the plan.
+func (p *Plan) Add(step Step) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.steps = append(p.steps, step)
+}
+
+// Steps returns a copy of the steps of the plan.
+func (p *Plan) Steps() []Step {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Step(nil), p.steps...)
+}
+
+// Empty reports whether the plan contains no steps.
+func (p *Plan) Empty() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.steps) == 0
+}
@@ -0,0 +1,62 @@
+/*
+Craft applies a manifest of resources to the local system.
+
+Usage:
+
+	craft <command> [flags] [manifest]
+
+The commands are:
+
+	plan       Show the changes which would be made to the system
+	apply      Change the system to match the manifest
+	check      Exit with status 2 if the system does not match the manifest
+	resources  List the resource types and their fields
+	facts      Show information about the system
+
+The plan, apply, and check commands take the following flags:
+
+	-format human|json
+		The output format. The default is human.
+	-concurrency n
+		The number of resources to apply at the same time. The default is 1.
+	-debug
+		Log every step and command to standard error.
+	-backup-dir dir
+		Keep a timestamped copy of every file in dir before it is
+		replaced. By default, no copies are kept.
+	-backup-keep n
+		The number of copies to keep of each file, or 0 to keep every
+		copy. The default is 5.
+
+For example, to preview the changes of a manifest:
+
+	$ craft plan host.yaml
+	+ User[deploy]
+	    Name: deploy
+	    Shell: /usr/sbin/nologin
+	~ File[/etc/motd]
+	    Mode: 0644 -> 0600
+	    --- /etc/motd
+	    +++ /etc/motd
+	    @@ -1 +1 @@
+	    -Hello
+	    +Welcome!
+
+	1 to create, 1 to update, 0 to delete, 3 unchanged.
+
+The resources command lists the fields of every resource type, or of the
+types given:
+
+	$ craft resources group
+	group (Group)
+	  ensure    string
+	  requires  list
+	  before    list
+	  notify    list
+	  name      string  required
+	  gid       string
+
+The exit status is 1 if the manifest is invalid or a resource could not be
+applied, in which case the resources which depend on it are skipped.
+*/
+package main
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/facts"
+	"github.com/jtopjian/craft/graph"
+	"github.com/jtopjian/craft/manifest"
+	"github.com/sirupsen/logrus"
+)
+
+// Exit statuses of the command.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitDrift = 2
+)
+
+const usage = `Usage: craft <command> [flags] [manifest]
+
+Commands:
+  plan       Show the changes which would be made to the system
+  apply      Change the system to match the manifest
+  check      Exit with status 2 if the system does not match the manifest
+  resources  List the resource types and their fields
+  facts      Show information about the system
+
+Run "craft <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, nil))
+}
+
+// run is an internal function that will run the command with the given
+// arguments and return its exit status. Resources are managed through the
+// given executor, or the local system if it is nil.
+func run(args []string, stdout, stderr io.Writer, system executor.Executor) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+
+	command := args[0]
+
+	flags := flag.NewFlagSet("craft "+command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	format := flags.String("format", "human", "output format: human or json")
+	concurrency := flags.Int("concurrency", 1, "number of resources to apply at the same time")
+	debug := flags.Bool("debug", false, "log every step and command")
+	backupDir := flags.String("backup-dir", "", "directory to keep copies of files in before they are replaced")
+	backupKeep := flags.Int("backup-keep", 5, "number of copies to keep of each file, or 0 for all")
+
+	switch command {
+	case "plan", "apply", "check":
+		flags.Usage = func() {
+			fmt.Fprintf(stderr, "Usage: craft %s [flags] <manifest>\n\nFlags:\n", command)
+			flags.PrintDefaults()
+		}
+	case "facts":
+		flags.Usage = func() {
+			fmt.Fprintf(stderr, "Usage: craft facts [flags]\n\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	case "resources":
+		flags.Usage = func() {
+			fmt.Fprintf(stderr, "Usage: craft resources [flags] [type...]\n\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", command, usage)
+		return exitError
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitError
+	}
+
+	var out output
+	switch *format {
+	case "human":
+		out = humanOutput{w: stdout}
+	case "json":
+		out = jsonOutput{w: stdout}
+	default:
+		fmt.Fprintf(stderr, "Invalid format %q: must be human or json\n", *format)
+		return exitError
+	}
+
+	if command == "resources" {
+		return runResources(out, flags.Args(), stderr)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(stderr)
+	logger.SetLevel(logrus.WarnLevel)
+	if *debug {
+		logger.SetLevel(logrus.DebugLevel)
+	}
+
+	c := client.Client{
+		Logger:   logger,
+		Executor: system,
+		Backup: executor.BackupOpts{
+			Dir:  *backupDir,
+			Keep: *backupKeep,
+		},
+	}
+
+	if command == "facts" {
+		return runFacts(c, out, stderr)
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitError
+	}
+
+	m, err := manifest.Load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	g, err := graph.New(m.Resources)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", flags.Arg(0), err)
+		return exitError
+	}
+
+	if command != "apply" {
+		c.DryRun = true
+		c.Plan = &client.Plan{}
+	}
+
+	results := g.Apply(c, graph.ApplyOpts{
+		Concurrency: *concurrency,
+	})
+
+	r := newReport(command, results)
+	if err := out.report(r); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	switch {
+	case r.Summary.Failed > 0 || r.Summary.Skipped > 0:
+		return exitError
+	case command == "check" && r.Summary.Changed() > 0:
+		return exitDrift
+	}
+
+	return exitOK
+}
+
+// runResources is an internal function that will list the resource types
+// with the given names, or all types if no names are given.
+func runResources(out output, names []string, stderr io.Writer) int {
+	if len(names) == 0 {
+		names = manifest.Types()
+	}
+
+	var types []resourceType
+	for _, name := range names {
+		fields, ok := manifest.Fields(name)
+		if !ok {
+			fmt.Fprintf(stderr, "Unknown resource type %q\n", name)
+			return exitError
+		}
+
+		r, _ := manifest.Lookup(name)
+		types = append(types, resourceType{
+			Name:   name,
+			Type:   r.Type(),
+			Fields: fields,
+		})
+	}
+
+	if err := out.resources(types); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// runFacts is an internal function that will show the facts of the
+// system.
+func runFacts(c client.Client, out output, stderr io.Writer) int {
+	f, err := facts.Gather(c)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if err := out.facts(f); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
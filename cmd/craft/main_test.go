@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/facts"
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifest = `
+resources:
+  - type: file
+    name: /etc/motd
+    mode: "0644"
+    content: |
+      Welcome!
+  - type: file
+    name: /etc/app/config.yml
+    content: |
+      debug: false
+  - type: directory
+    name: /etc/app
+`
+
+func writeManifest(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "craft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	fileName := filepath.Join(dir, "host.yaml")
+	if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return fileName
+}
+
+func Test_run_Plan(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/motd", "Hello\n", 0644)
+	fileName := writeManifest(t, testManifest)
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"plan", "-format", "json", fileName}, &stdout, &stderr, fake)
+	assert.Equal(t, exitOK, status, stderr.String())
+
+	var r report
+	err := json.Unmarshal(stdout.Bytes(), &r)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "plan", r.Command, "should be equal")
+	assert.Equal(t, summary{Update: 1, Create: 2}, r.Summary, "should be equal")
+	assert.Equal(t, "File", r.Changes[0].Type, "should be equal")
+	assert.Equal(t, "update", r.Changes[0].Action, "should be equal")
+	assert.Contains(t, r.Changes[0].ContentDiff, "+Welcome!", "should be equal")
+
+	// Nothing is changed by a plan.
+	content, _ := fake.ReadFile("/etc/motd")
+	assert.Equal(t, "Hello\n", string(content), "should be equal")
+}
+
+func Test_run_ApplyCheck(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/motd", "Hello\n", 0644)
+	fileName := writeManifest(t, testManifest)
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"check", fileName}, &stdout, &stderr, fake)
+	assert.Equal(t, exitDrift, status, stderr.String())
+
+	stdout.Reset()
+	status = run([]string{"apply", fileName}, &stdout, &stderr, fake)
+	assert.Equal(t, exitOK, status, stderr.String())
+	assert.Contains(t, stdout.String(), "~ File[/etc/motd]\n", "should be equal")
+	assert.Contains(t, stdout.String(), "2 created, 1 updated, 0 deleted, 0 unchanged.\n", "should be equal")
+
+	content, _ := fake.ReadFile("/etc/motd")
+	assert.Equal(t, "Welcome!\n", string(content), "should be equal")
+
+	content, _ = fake.ReadFile("/etc/app/config.yml")
+	assert.Equal(t, "debug: false\n", string(content), "should be equal")
+
+	stdout.Reset()
+	status = run([]string{"check", fileName}, &stdout, &stderr, fake)
+	assert.Equal(t, exitOK, status, stderr.String())
+	assert.Equal(t, "\n0 to create, 0 to update, 0 to delete, 3 unchanged.\n", stdout.String(), "should be equal")
+}
+
+func Test_run_Notify(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/motd", "Hello\n", 0644)
+	fileName := writeManifest(t, `
+resources:
+  - type: file
+    name: /etc/motd
+    content: |
+      Welcome!
+    notify: ["exec[motd-changed]"]
+  - type: exec
+    name: motd-changed
+    command: logger motd changed
+    only_notified: true
+`)
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"apply", fileName}, &stdout, &stderr, fake)
+	assert.Equal(t, exitOK, status, stderr.String())
+	assert.Contains(t, stdout.String(), "* Exec[motd-changed]\n")
+	assert.Contains(t, stdout.String(), "1 updated, 0 deleted, 1 unchanged, 1 notified.\n")
+	assert.Equal(t, []string{"sh -c 'logger motd changed'"}, fake.Commands, "should be equal")
+
+	stdout.Reset()
+	status = run([]string{"apply", fileName}, &stdout, &stderr, fake)
+	assert.Equal(t, exitOK, status, stderr.String())
+	assert.Equal(t, "\n0 created, 0 updated, 0 deleted, 2 unchanged.\n", stdout.String(), "should be equal")
+	assert.Equal(t, 1, len(fake.Commands), "should be equal")
+}
+
+func Test_run_Errors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	status := run([]string{"plan"}, &stdout, &stderr, nil)
+	assert.Equal(t, exitError, status, "should be equal")
+
+	status = run([]string{"destroy"}, &stdout, &stderr, nil)
+	assert.Equal(t, exitError, status, "should be equal")
+
+	stderr.Reset()
+	fileName := writeManifest(t, "resources:\n  - type: package\n")
+	status = run([]string{"plan", fileName}, &stdout, &stderr, nil)
+	assert.Equal(t, exitError, status, "should be equal")
+	assert.Equal(t, fileName+":2:11: unknown resource type \"package\"\n", stderr.String(), "should be equal")
+}
+
+func Test_run_Resources(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	status := run([]string{"resources", "group"}, &stdout, &stderr, nil)
+	assert.Equal(t, exitOK, status, stderr.String())
+
+	expected := `group (Group)
+  ensure    string
+  requires  list
+  before    list
+  notify    list
+  name      string  required
+  gid       string
+`
+	assert.Equal(t, expected, stdout.String(), "should be equal")
+
+	stdout.Reset()
+	status = run([]string{"resources", "-format", "json"}, &stdout, &stderr, nil)
+	assert.Equal(t, exitOK, status, stderr.String())
+
+	var types []resourceType
+	err := json.Unmarshal(stdout.Bytes(), &types)
+	assert.Nil(t, err)
+	assert.Equal(t, "apk_package", types[0].Name, "should be equal")
+}
+
+func Test_run_Facts(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/os-release", "ID=alpine\nPRETTY_NAME=\"Alpine Linux v3.19\"\n", 0644)
+	fake.AddFile("/sbin/apk", "", 0755)
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"facts", "-format", "json"}, &stdout, &stderr, fake)
+	assert.Equal(t, exitOK, status, stderr.String())
+
+	var f facts.Facts
+	err := json.Unmarshal(stdout.Bytes(), &f)
+	assert.Nil(t, err)
+	assert.Equal(t, "alpine", f.OS.ID, "should be equal")
+	assert.Equal(t, "apk", f.PackageManager, "should be equal")
+}
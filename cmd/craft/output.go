@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtopjian/craft/facts"
+	"github.com/jtopjian/craft/graph"
+	"github.com/jtopjian/craft/manifest"
+	"github.com/jtopjian/craft/resources"
+)
+
+// report is the outcome of the plan, apply, and check commands.
+type report struct {
+	Command string   `json:"command"`
+	Changes []change `json:"changes"`
+	Summary summary  `json:"summary"`
+}
+
+// change is the outcome of a single resource.
+type change struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Action      string `json:"action,omitempty"`
+	Diffs       []diff `json:"diffs,omitempty"`
+	ContentDiff string `json:"content_diff,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Skipped     bool   `json:"skipped,omitempty"`
+}
+
+// diff is a field of a resource which was or would be changed.
+type diff struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// summary counts the resources by their outcome.
+type summary struct {
+	Create    int `json:"create"`
+	Update    int `json:"update"`
+	Delete    int `json:"delete"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+
+	// Notify counts the handlers which were or would be notified. They
+	// are not counted as changed since they do not describe drift.
+	Notify int `json:"notify"`
+}
+
+// Changed returns the number of resources which were or would be changed.
+func (s summary) Changed() int {
+	return s.Create + s.Update + s.Delete
+}
+
+// resourceType describes a resource type for the resources command.
+type resourceType struct {
+	Name   string           `json:"name"`
+	Type   string           `json:"type"`
+	Fields []manifest.Field `json:"fields"`
+}
+
+// newReport is an internal function that will summarize the results of
+// applying a graph.
+func newReport(command string, results []graph.Result) (r report) {
+	r.Command = command
+	r.Changes = []change{}
+
+	for _, result := range results {
+		c := change{
+			Type:        result.Change.Type,
+			Name:        result.Change.Name,
+			Action:      result.Change.Action,
+			ContentDiff: result.Change.ContentDiff,
+			Skipped:     result.Skipped,
+		}
+
+		for _, d := range result.Change.Diffs {
+			c.Diffs = append(c.Diffs, diff{Field: d.Field, Old: d.Old, New: d.New})
+		}
+
+		switch {
+		case result.Skipped:
+			c.Error = result.Err.Error()
+			r.Summary.Skipped++
+		case result.Err != nil:
+			c.Error = result.Err.Error()
+			r.Summary.Failed++
+		case c.Action == resources.ActionCreate:
+			r.Summary.Create++
+		case c.Action == resources.ActionUpdate:
+			r.Summary.Update++
+		case c.Action == resources.ActionDelete:
+			r.Summary.Delete++
+		case c.Action == resources.ActionNotify:
+			r.Summary.Notify++
+		default:
+			r.Summary.Unchanged++
+		}
+
+		r.Changes = append(r.Changes, c)
+	}
+
+	return
+}
+
+// output is implemented by the output formats of the command.
+type output interface {
+	report(r report) error
+	resources(types []resourceType) error
+	facts(f facts.Facts) error
+}
+
+// jsonOutput writes output as indented JSON.
+type jsonOutput struct {
+	w io.Writer
+}
+
+func (o jsonOutput) report(r report) error {
+	return o.write(r)
+}
+
+func (o jsonOutput) resources(types []resourceType) error {
+	return o.write(types)
+}
+
+func (o jsonOutput) facts(f facts.Facts) error {
+	return o.write(f)
+}
+
+func (o jsonOutput) write(v interface{}) error {
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// humanOutput writes output meant to be read in a terminal.
+type humanOutput struct {
+	w io.Writer
+}
+
+// actionSymbols are the prefixes of changed resources.
+var actionSymbols = map[string]string{
+	resources.ActionCreate: "+",
+	resources.ActionUpdate: "~",
+	resources.ActionDelete: "-",
+	resources.ActionNotify: "*",
+}
+
+func (o humanOutput) report(r report) error {
+	for _, c := range r.Changes {
+		ref := resources.Ref{Type: c.Type, ID: c.Name}
+
+		switch {
+		case c.Error != "":
+			fmt.Fprintf(o.w, "! %s: %s\n", ref, c.Error)
+			continue
+		case c.Action == "":
+			continue
+		}
+
+		fmt.Fprintf(o.w, "%s %s\n", actionSymbols[c.Action], ref)
+		for _, d := range c.Diffs {
+			if c.Action == resources.ActionCreate {
+				fmt.Fprintf(o.w, "    %s: %s\n", d.Field, d.New)
+			} else {
+				fmt.Fprintf(o.w, "    %s: %s -> %s\n", d.Field, d.Old, d.New)
+			}
+		}
+
+		if c.ContentDiff != "" {
+			for _, line := range strings.SplitAfter(strings.TrimSuffix(c.ContentDiff, "\n"), "\n") {
+				fmt.Fprintf(o.w, "    %s", line)
+			}
+			fmt.Fprintln(o.w)
+		}
+	}
+
+	s := r.Summary
+	verbs := map[string][4]string{
+		"plan":  {"to create", "to update", "to delete", "to notify"},
+		"check": {"to create", "to update", "to delete", "to notify"},
+		"apply": {"created", "updated", "deleted", "notified"},
+	}[r.Command]
+
+	fmt.Fprintf(o.w, "\n%d %s, %d %s, %d %s, %d unchanged",
+		s.Create, verbs[0], s.Update, verbs[1], s.Delete, verbs[2], s.Unchanged)
+
+	if s.Notify > 0 {
+		fmt.Fprintf(o.w, ", %d %s", s.Notify, verbs[3])
+	}
+
+	if s.Failed > 0 || s.Skipped > 0 {
+		fmt.Fprintf(o.w, ", %d failed, %d skipped", s.Failed, s.Skipped)
+	}
+
+	fmt.Fprintln(o.w, ".")
+
+	return nil
+}
+
+func (o humanOutput) resources(types []resourceType) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+
+	for i, t := range types {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		fmt.Fprintf(tw, "%s (%s)\n", t.Name, t.Type)
+		for _, f := range t.Fields {
+			var notes []string
+			if f.Required {
+				notes = append(notes, "required")
+			}
+
+			if f.Default != "" {
+				notes = append(notes, fmt.Sprintf("default %q", f.Default))
+			}
+
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", f.Name, f.Type, strings.Join(notes, ", "))
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// The padding of the last column is trimmed.
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line == "" {
+			continue
+		}
+
+		if _, err := fmt.Fprintln(o.w, strings.TrimRight(line, " \n")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o humanOutput) facts(f facts.Facts) error {
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "os:\t%s\n", f.OS.PrettyName)
+	fmt.Fprintf(tw, "kernel:\t%s %s\n", f.Kernel, f.Arch)
+	fmt.Fprintf(tw, "hostname:\t%s\n", f.FQDN)
+	fmt.Fprintf(tw, "cpus:\t%d\n", f.CPUs)
+	fmt.Fprintf(tw, "memory:\t%d MiB\n", f.Memory.Total/1024/1024)
+	fmt.Fprintf(tw, "package manager:\t%s\n", f.PackageManager)
+	fmt.Fprintf(tw, "init system:\t%s\n", f.InitSystem)
+
+	for _, iface := range f.Interfaces {
+		fmt.Fprintf(tw, "interface %s:\t%s\n", iface.Name, strings.Join(iface.Addresses, ", "))
+	}
+
+	for _, m := range f.Mounts {
+		fmt.Fprintf(tw, "mount %s:\t%s (%s)\n", m.Path, m.Device, m.Type)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,304 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jtopjian/craft/utils"
+)
+
+// runner is implemented by executors which can only run commands, such
+// as SSH and Container.
+type runner interface {
+	// run runs a command. An error is only returned if the command
+	// could not be run, not if it failed.
+	run(eo utils.ExecOptions) (utils.ExecResult, error)
+}
+
+// commandSystem implements the file operations of an Executor with
+// standard POSIX commands which are run by a runner.
+type commandSystem struct {
+	runner runner
+}
+
+// Exec runs a command.
+func (c commandSystem) Exec(eo utils.ExecOptions) (utils.ExecResult, error) {
+	ctx, cancel := utils.ExecContext(eo)
+	defer cancel()
+
+	eo.Context = ctx
+	eo.Timeout = 0
+
+	er, err := c.runner.run(eo)
+	return er, utils.ExecError(ctx, eo, er, err)
+}
+
+// ReadFile returns the content of a file.
+func (c commandSystem) ReadFile(name string) ([]byte, error) {
+	er, err := c.runner.run(utils.ExecOptions{Args: []string{"cat", "--", name}})
+	if err != nil {
+		return nil, err
+	}
+
+	if er.ExitStatus != 0 {
+		return nil, commandFileError("open", name, er)
+	}
+
+	return []byte(er.Stdout), nil
+}
+
+// WriteFile atomically replaces a file. The data is written to a
+// temporary file in the same directory, which is synced, given the owner,
+// mode, and extended attributes of the existing file, and moved over it.
+func (c commandSystem) WriteFile(name string, data []byte, mode os.FileMode) error {
+	script := `set -e
+target=$1
+if [ -L "$target" ]; then target=$(readlink -f -- "$target"); fi
+if [ -d "$target" ]; then echo "$1: Is a directory" >&2; exit 1; fi
+tmp=$(mktemp "$(dirname -- "$target")/.$(basename -- "$target").craft-XXXXXX")
+trap 'rm -f -- "$tmp"' EXIT
+cat > "$tmp"
+if [ -e "$target" ]; then
+  chown --reference="$target" -- "$tmp"
+  chmod --reference="$target" -- "$tmp"
+  cp --attributes-only --preserve=xattr -- "$target" "$tmp" 2>/dev/null || true
+else
+  chmod "$2" -- "$tmp"
+fi
+sync -- "$tmp" 2>/dev/null || sync
+mv -f -- "$tmp" "$target"
+trap - EXIT`
+	args := []string{"sh", "-c", script, "sh", name, utils.ModeToString(mode)}
+
+	er, err := c.runner.run(utils.ExecOptions{Args: args, Stdin: bytes.NewReader(data)})
+	if err != nil {
+		return err
+	}
+
+	if er.ExitStatus != 0 {
+		return commandFileError("open", name, er)
+	}
+
+	return nil
+}
+
+// Stat returns information about a file.
+func (c commandSystem) Stat(name string) (fi FileInfo, err error) {
+	args := []string{"stat", "-L", "-c", "%f %s %u %g %U %G", "--", name}
+
+	er, err := c.runner.run(utils.ExecOptions{Args: args})
+	if err != nil {
+		return
+	}
+
+	if er.ExitStatus != 0 {
+		err = commandFileError("stat", name, er)
+		return
+	}
+
+	fi, err = commandParseStat(er.Stdout)
+	fi.Name = name
+
+	return
+}
+
+// Chown changes the owner and group of a file.
+func (c commandSystem) Chown(name, owner, group string, recurse bool) error {
+	spec := owner
+	if group != "" {
+		spec = owner + ":" + group
+	}
+
+	if spec == "" {
+		return nil
+	}
+
+	args := []string{"chown"}
+	if recurse {
+		args = append(args, "-R")
+	}
+	args = append(args, spec, "--", name)
+
+	return c.runFile("chown", name, args)
+}
+
+// Chmod changes the permissions of a file.
+func (c commandSystem) Chmod(name string, mode os.FileMode, recurse bool) error {
+	args := []string{"chmod"}
+	if recurse {
+		args = append(args, "-R")
+	}
+	args = append(args, utils.ModeToString(mode), "--", name)
+
+	return c.runFile("chmod", name, args)
+}
+
+// Mkdir creates a directory.
+func (c commandSystem) Mkdir(name string, mode os.FileMode, parents bool) error {
+	args := []string{"mkdir"}
+	if parents {
+		args = append(args, "-p")
+	}
+	args = append(args, "-m", utils.ModeToString(mode), "--", name)
+
+	return c.runFile("mkdir", name, args)
+}
+
+// Remove removes a file or directory.
+func (c commandSystem) Remove(name string, recurse bool) error {
+	if recurse {
+		return c.runFile("remove", name, []string{"rm", "-rf", "--", name})
+	}
+
+	script := `if [ -d "$1" ] && [ ! -L "$1" ]; then rmdir -- "$1"; else rm -- "$1"; fi`
+	return c.runFile("remove", name, []string{"sh", "-c", script, "sh", name})
+}
+
+// Glob returns the names of the files which match a pattern.
+func (c commandSystem) Glob(pattern string) (names []string, err error) {
+	script := `IFS=; for f in $1; do if [ -e "$f" ]; then printf '%s\n' "$f"; fi; done`
+
+	er, err := c.runner.run(utils.ExecOptions{Args: []string{"sh", "-c", script, "sh", pattern}})
+	if err != nil {
+		return
+	}
+
+	if er.ExitStatus != 0 {
+		err = commandFileError("glob", pattern, er)
+		return
+	}
+
+	for _, name := range strings.Split(er.Stdout, "\n") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return
+}
+
+// runFile is an internal method that will run a command which operates
+// on a file and convert a failure into an error.
+func (c commandSystem) runFile(op, name string, args []string) error {
+	er, err := c.runner.run(utils.ExecOptions{Args: args})
+	if err != nil {
+		return err
+	}
+
+	if er.ExitStatus != 0 {
+		return commandFileError(op, name, er)
+	}
+
+	return nil
+}
+
+// commandFileError is an internal function that will convert the result
+// of a failed command into an error. Errors for missing files satisfy
+// os.IsNotExist.
+func commandFileError(op, name string, er utils.ExecResult) error {
+	stderr := strings.TrimSpace(er.Stderr)
+	if strings.Contains(stderr, "No such file or directory") {
+		return notExist(op, name)
+	}
+
+	if stderr == "" {
+		stderr = fmt.Sprintf("exit status %d", er.ExitStatus)
+	}
+
+	return &os.PathError{Op: op, Path: name, Err: fmt.Errorf("%s", stderr)}
+}
+
+// commandParseStat is an internal function that will parse the output of
+// stat -c "%f %s %u %g %U %G".
+func commandParseStat(stdout string) (fi FileInfo, err error) {
+	fields := strings.Fields(stdout)
+	if len(fields) != 6 {
+		err = fmt.Errorf("Unable to parse stat output: %s", stdout)
+		return
+	}
+
+	raw, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return
+	}
+
+	fi.Mode = os.FileMode(raw & 0777)
+	switch raw & 0170000 {
+	case 0040000:
+		fi.Mode |= os.ModeDir
+	case 0120000:
+		fi.Mode |= os.ModeSymlink
+	case 0010000:
+		fi.Mode |= os.ModeNamedPipe
+	case 0140000:
+		fi.Mode |= os.ModeSocket
+	case 0020000:
+		fi.Mode |= os.ModeDevice | os.ModeCharDevice
+	case 0060000:
+		fi.Mode |= os.ModeDevice
+	}
+
+	if raw&04000 != 0 {
+		fi.Mode |= os.ModeSetuid
+	}
+	if raw&02000 != 0 {
+		fi.Mode |= os.ModeSetgid
+	}
+	if raw&01000 != 0 {
+		fi.Mode |= os.ModeSticky
+	}
+
+	if fi.Size, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return
+	}
+
+	if fi.UID, err = strconv.Atoi(fields[2]); err != nil {
+		return
+	}
+
+	if fi.GID, err = strconv.Atoi(fields[3]); err != nil {
+		return
+	}
+
+	fi.Owner = fields[4]
+	fi.Group = fields[5]
+
+	// stat prints UNKNOWN for IDs without a name.
+	if fi.Owner == "UNKNOWN" {
+		fi.Owner = fields[2]
+	}
+	if fi.Group == "UNKNOWN" {
+		fi.Group = fields[3]
+	}
+
+	return
+}
+
+// shellCommand is an internal function that will build a shell command
+// line which runs a command in its directory and with its environment.
+func shellCommand(eo utils.ExecOptions, sudo bool) string {
+	var parts []string
+
+	if sudo {
+		parts = append(parts, "sudo", "-n")
+	}
+
+	if len(eo.Env) > 0 {
+		parts = append(parts, "env")
+		for _, e := range eo.Env {
+			parts = append(parts, utils.ShellQuote(e))
+		}
+	}
+
+	parts = append(parts, eo.String())
+
+	command := strings.Join(parts, " ")
+	if eo.Dir != "" {
+		command = "cd " + utils.ShellQuote(eo.Dir) + " && " + command
+	}
+
+	return command
+}
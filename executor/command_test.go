@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRunner records the commands it is asked to run and returns a
+// canned result.
+type testRunner struct {
+	commands []string
+	stdin    string
+	result   utils.ExecResult
+}
+
+func (r *testRunner) run(eo utils.ExecOptions) (utils.ExecResult, error) {
+	r.commands = append(r.commands, shellCommand(eo, false))
+	if eo.Stdin != nil {
+		b, _ := ioutil.ReadAll(eo.Stdin)
+		r.stdin = string(b)
+	}
+
+	return r.result, nil
+}
+
+func Test_commandParseStat(t *testing.T) {
+	fi, err := commandParseStat("41ed 4096 0 0 root root\n")
+	assert.Nil(t, err)
+	assert.Equal(t, true, fi.IsDir(), "should be equal")
+	assert.Equal(t, os.FileMode(0755), fi.Mode.Perm(), "should be equal")
+	assert.Equal(t, "root", fi.Owner, "should be equal")
+
+	fi, err = commandParseStat("81a0 12 1000 1001 UNKNOWN staff\n")
+	assert.Nil(t, err)
+	assert.Equal(t, true, fi.IsRegular(), "should be equal")
+	assert.Equal(t, os.FileMode(0640), fi.Mode.Perm(), "should be equal")
+	assert.Equal(t, int64(12), fi.Size, "should be equal")
+	assert.Equal(t, "1000", fi.Owner, "should be equal")
+	assert.Equal(t, "staff", fi.Group, "should be equal")
+
+	_, err = commandParseStat("")
+	assert.NotNil(t, err)
+}
+
+func Test_shellCommand(t *testing.T) {
+	eo := utils.ExecOptions{
+		Args: []string{"echo", "it's", "a b"},
+		Dir:  "/tmp/foo bar",
+		Env:  []string{"FOO=bar"},
+	}
+
+	command := shellCommand(eo, true)
+	expected := `cd '/tmp/foo bar' && sudo -n env FOO=bar echo 'it'\''s' 'a b'`
+	assert.Equal(t, expected, command, "should be equal")
+}
+
+func Test_commandSystem(t *testing.T) {
+	r := &testRunner{}
+	c := commandSystem{runner: r}
+
+	err := c.WriteFile("/etc/foo", []byte("bar\n"), 0640)
+	assert.Nil(t, err)
+	assert.Equal(t, "bar\n", r.stdin, "should be equal")
+	assert.True(t, strings.HasSuffix(r.commands[0], "sh /etc/foo 0640"))
+
+	err = c.Chown("/etc/foo", "root", "", true)
+	assert.Nil(t, err)
+	assert.Equal(t, "chown -R root -- /etc/foo", r.commands[1], "should be equal")
+
+	r.result = utils.ExecResult{
+		ExitStatus: 1,
+		Stderr:     "stat: cannot statx '/etc/foo': No such file or directory\n",
+	}
+
+	_, err = c.Stat("/etc/foo")
+	assert.True(t, os.IsNotExist(err))
+
+	r.result = utils.ExecResult{ExitStatus: 1}
+	_, err = c.Exec(utils.ExecOptions{Command: "false"})
+	assert.NotNil(t, err)
+}
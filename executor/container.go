@@ -0,0 +1,64 @@
+package executor
+
+import (
+	"github.com/jtopjian/craft/utils"
+)
+
+// Container is an executor for a running container. Commands are run
+// with "docker exec" or a compatible command such as "podman exec".
+// Files are accessed by running commands such as cat and stat, so the
+// container must provide a POSIX shell and coreutils. A Container executor
+// must be created with NewContainer.
+type Container struct {
+	commandSystem
+
+	// Name is the name or ID of the container.
+	Name string
+
+	// Runtime is the command used to run commands in the container.
+	// If not set, "docker" is used.
+	Runtime string
+}
+
+var _ Executor = &Container{}
+
+// NewContainer will return an executor for a container.
+func NewContainer(name string) *Container {
+	c := &Container{Name: name}
+	c.commandSystem = commandSystem{runner: c}
+
+	return c
+}
+
+// run runs a command in the container.
+func (c *Container) run(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+	runtime := c.Runtime
+	if runtime == "" {
+		runtime = "docker"
+	}
+
+	args := []string{runtime, "exec", "-i"}
+	if eo.Dir != "" {
+		args = append(args, "-w", eo.Dir)
+	}
+	for _, e := range eo.Env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, c.Name)
+	args = append(args, eo.Argv()...)
+
+	er, err = utils.Exec(utils.ExecOptions{
+		Args:     args,
+		Stdin:    eo.Stdin,
+		Context:  eo.Context,
+		OnStdout: eo.OnStdout,
+		OnStderr: eo.OnStderr,
+	})
+
+	// A failed command is reported by its result.
+	if er.ExitStatus != 0 {
+		err = nil
+	}
+
+	return
+}
@@ -0,0 +1,47 @@
+/*
+Package executor provides the means by which resources run commands and
+access files on a system.
+
+A client uses the local system unless an executor is set:
+
+	c := client.Client{
+		Logger: logger,
+	}
+
+To manage a remote system over SSH:
+
+	config := &ssh.ClientConfig{
+		User:            "root",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+	}
+
+	e, err := executor.NewSSH("example.com:22", config)
+	if err != nil {
+		panic(err)
+	}
+	defer e.Close()
+
+	c := client.Client{
+		Logger:   logger,
+		Executor: e,
+	}
+
+To manage a running container:
+
+	c := client.Client{
+		Logger:   logger,
+		Executor: executor.NewContainer("web"),
+	}
+
+In tests, a fake system keeps files in memory and records commands:
+
+	fake := executor.NewFake()
+	fake.AddFile("/etc/hosts", "127.0.0.1 localhost\n", 0644)
+
+	c := client.Client{
+		Logger:   logger,
+		Executor: fake,
+	}
+*/
+package executor
@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jtopjian/craft/utils"
+)
+
+// Executor runs commands and accesses files on a system. Resources use
+// an Executor for every interaction with the system they manage.
+//
+// Errors for files which do not exist satisfy os.IsNotExist.
+type Executor interface {
+	// Exec runs a command. The entries of eo.Env are added to the
+	// environment of the command. An error is returned if the command
+	// could not be run or exited with a non-zero status.
+	Exec(eo utils.ExecOptions) (utils.ExecResult, error)
+
+	// ReadFile returns the content of a file.
+	ReadFile(name string) ([]byte, error)
+
+	// WriteFile writes data to a file. If the file does not exist, it
+	// is created with the given mode. Otherwise its owner, mode, and
+	// extended attributes are unchanged.
+	//
+	// The file is replaced atomically: the data is written to a
+	// temporary file in the same directory which is then renamed over
+	// the file, so that a failure never leaves a partially written file.
+	WriteFile(name string, data []byte, mode os.FileMode) error
+
+	// Stat returns information about a file, following symlinks.
+	Stat(name string) (FileInfo, error)
+
+	// Chown changes the owner and group of a file. An empty owner or
+	// group is left unchanged.
+	Chown(name, owner, group string, recurse bool) error
+
+	// Chmod changes the permissions of a file.
+	Chmod(name string, mode os.FileMode, recurse bool) error
+
+	// Mkdir creates a directory. If parents is set, missing parent
+	// directories are created as well.
+	Mkdir(name string, mode os.FileMode, parents bool) error
+
+	// Remove removes a file or an empty directory. If recurse is set,
+	// a directory is removed along with its content.
+	Remove(name string, recurse bool) error
+
+	// Glob returns the names of the files which match a pattern.
+	Glob(pattern string) ([]string, error)
+}
+
+// FileInfo represents information about a file.
+type FileInfo struct {
+	// Name is the name of the file.
+	Name string
+
+	// Mode is the mode of the file.
+	Mode os.FileMode
+
+	// Size is the size of the file in bytes.
+	Size int64
+
+	// UID and GID are the numeric owner and group of the file.
+	UID int
+	GID int
+
+	// Owner and Group are the names of the owner and group of the file.
+	// If a name cannot be determined, the numeric ID is used.
+	Owner string
+	Group string
+}
+
+// IsDir reports whether the file is a directory.
+func (fi FileInfo) IsDir() bool {
+	return fi.Mode.IsDir()
+}
+
+// IsRegular reports whether the file is a regular file.
+func (fi FileInfo) IsRegular() bool {
+	return fi.Mode.IsRegular()
+}
+
+// Exists will determine if a file exists.
+func Exists(e Executor, name string) (bool, error) {
+	_, err := e.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReadLines returns the lines of a file.
+func ReadLines(e Executor, name string) ([]string, error) {
+	content, err := e.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(string(content), "\n"), nil
+}
+
+// notExist is an internal function that will return an error which
+// satisfies os.IsNotExist.
+func notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
@@ -0,0 +1,311 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jtopjian/craft/utils"
+)
+
+// FakeFile represents a file on a fake system.
+type FakeFile struct {
+	// Content is the content of the file.
+	Content []byte
+
+	// Mode is the mode of the file. Directories have os.ModeDir set.
+	Mode os.FileMode
+
+	// Owner and Group are the owner and group of the file.
+	Owner string
+	Group string
+}
+
+// Fake is an in-memory executor for tests. Files are kept in memory and
+// commands are passed to a handler.
+type Fake struct {
+	// Files are the files on the system, keyed by their absolute name.
+	// The root directory always exists.
+	Files map[string]*FakeFile
+
+	// Handler is called for every command which is run. If it is not
+	// set, commands succeed without output.
+	Handler func(eo utils.ExecOptions) (utils.ExecResult, error)
+
+	// Commands records every command which was run, quoted for a shell.
+	Commands []string
+
+	mu sync.Mutex
+}
+
+var _ Executor = &Fake{}
+
+// NewFake will return an empty fake system.
+func NewFake() *Fake {
+	return &Fake{
+		Files: make(map[string]*FakeFile),
+	}
+}
+
+// AddFile will add a file owned by root to the fake system. Missing
+// parent directories are created.
+func (f *Fake) AddFile(name, content string, mode os.FileMode) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = path.Clean(name)
+	f.mkdir(path.Dir(name), 0755, true)
+
+	f.Files[name] = &FakeFile{
+		Content: []byte(content),
+		Mode:    mode,
+		Owner:   "root",
+		Group:   "root",
+	}
+}
+
+// Exec records a command and passes it to the handler.
+func (f *Fake) Exec(eo utils.ExecOptions) (utils.ExecResult, error) {
+	f.mu.Lock()
+	f.Commands = append(f.Commands, eo.String())
+	handler := f.Handler
+	f.mu.Unlock()
+
+	if handler == nil {
+		return utils.ExecResult{}, nil
+	}
+
+	return handler(eo)
+}
+
+// ReadFile returns the content of a fake file.
+func (f *Fake) ReadFile(name string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, ok := f.Files[path.Clean(name)]
+	if !ok {
+		return nil, notExist("open", name)
+	}
+
+	if file.Mode.IsDir() {
+		return nil, &os.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+
+	return append([]byte{}, file.Content...), nil
+}
+
+// WriteFile writes data to a fake file.
+func (f *Fake) WriteFile(name string, data []byte, mode os.FileMode) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = path.Clean(name)
+	if !f.isDir(path.Dir(name)) {
+		return notExist("open", name)
+	}
+
+	if file, ok := f.Files[name]; ok {
+		if file.Mode.IsDir() {
+			return &os.PathError{Op: "open", Path: name, Err: fmt.Errorf("is a directory")}
+		}
+		file.Content = append([]byte{}, data...)
+		return nil
+	}
+
+	f.Files[name] = &FakeFile{
+		Content: append([]byte{}, data...),
+		Mode:    mode.Perm(),
+		Owner:   "root",
+		Group:   "root",
+	}
+
+	return nil
+}
+
+// Stat returns information about a fake file.
+func (f *Fake) Stat(name string) (fi FileInfo, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = path.Clean(name)
+	if name == "/" {
+		fi = FileInfo{Name: name, Mode: os.ModeDir | 0755, Owner: "root", Group: "root"}
+		return
+	}
+
+	file, ok := f.Files[name]
+	if !ok {
+		err = notExist("stat", name)
+		return
+	}
+
+	fi = FileInfo{
+		Name:  name,
+		Mode:  file.Mode,
+		Size:  int64(len(file.Content)),
+		Owner: file.Owner,
+		Group: file.Group,
+	}
+
+	return
+}
+
+// Chown changes the owner and group of a fake file.
+func (f *Fake) Chown(name, owner, group string, recurse bool) error {
+	return f.walk("chown", name, recurse, func(file *FakeFile) {
+		if owner != "" {
+			file.Owner = owner
+		}
+		if group != "" {
+			file.Group = group
+		}
+	})
+}
+
+// Chmod changes the permissions of a fake file.
+func (f *Fake) Chmod(name string, mode os.FileMode, recurse bool) error {
+	return f.walk("chmod", name, recurse, func(file *FakeFile) {
+		file.Mode = file.Mode&os.ModeType | mode.Perm()
+	})
+}
+
+// Mkdir creates a fake directory.
+func (f *Fake) Mkdir(name string, mode os.FileMode, parents bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.mkdir(path.Clean(name), mode, parents)
+}
+
+// mkdir is an internal method that will create a directory. The caller
+// must hold the lock.
+func (f *Fake) mkdir(name string, mode os.FileMode, parents bool) error {
+	if f.isDir(name) {
+		if parents {
+			return nil
+		}
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	if _, ok := f.Files[name]; ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	parent := path.Dir(name)
+	if !f.isDir(parent) {
+		if !parents {
+			return notExist("mkdir", name)
+		}
+
+		if err := f.mkdir(parent, mode, true); err != nil {
+			return err
+		}
+	}
+
+	f.Files[name] = &FakeFile{
+		Mode:  os.ModeDir | mode.Perm(),
+		Owner: "root",
+		Group: "root",
+	}
+
+	return nil
+}
+
+// Remove removes a fake file or directory.
+func (f *Fake) Remove(name string, recurse bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = path.Clean(name)
+	if _, ok := f.Files[name]; !ok {
+		if recurse {
+			return nil
+		}
+		return notExist("remove", name)
+	}
+
+	children := f.children(name)
+	if len(children) > 0 && !recurse {
+		return &os.PathError{Op: "remove", Path: name, Err: fmt.Errorf("directory not empty")}
+	}
+
+	for _, child := range children {
+		delete(f.Files, child)
+	}
+	delete(f.Files, name)
+
+	return nil
+}
+
+// Glob returns the names of the fake files which match a pattern.
+func (f *Fake) Glob(pattern string) (names []string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for name := range f.Files {
+		var match bool
+		match, err = path.Match(pattern, name)
+		if err != nil {
+			return
+		}
+
+		if match {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return
+}
+
+// walk is an internal method that will apply fn to a file and, if
+// recurse is set, to everything beneath it.
+func (f *Fake) walk(op, name string, recurse bool, fn func(*FakeFile)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = path.Clean(name)
+	file, ok := f.Files[name]
+	if !ok {
+		return notExist(op, name)
+	}
+
+	fn(file)
+
+	if recurse {
+		for _, child := range f.children(name) {
+			fn(f.Files[child])
+		}
+	}
+
+	return nil
+}
+
+// isDir is an internal method that will determine if a name is a
+// directory. The caller must hold the lock.
+func (f *Fake) isDir(name string) bool {
+	if name == "/" || name == "." {
+		return true
+	}
+
+	file, ok := f.Files[name]
+	return ok && file.Mode.IsDir()
+}
+
+// children is an internal method that will return the sorted names of
+// the files beneath a directory. The caller must hold the lock.
+func (f *Fake) children(name string) (names []string) {
+	prefix := strings.TrimSuffix(name, "/") + "/"
+	for n := range f.Files {
+		if strings.HasPrefix(n, prefix) {
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+	return
+}
@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Fake(t *testing.T) {
+	fake := NewFake()
+
+	err := fake.WriteFile("/etc/foo/bar", []byte("baz"), 0644)
+	assert.True(t, os.IsNotExist(err))
+
+	err = fake.Mkdir("/etc/foo", 0755, true)
+	assert.Nil(t, err)
+
+	err = fake.WriteFile("/etc/foo/bar", []byte("baz"), 0644)
+	assert.Nil(t, err)
+
+	err = fake.Chown("/etc/foo", "nobody", "nogroup", true)
+	assert.Nil(t, err)
+
+	fi, err := fake.Stat("/etc/foo/bar")
+	assert.Nil(t, err)
+	assert.Equal(t, "nobody", fi.Owner, "should be equal")
+	assert.Equal(t, int64(3), fi.Size, "should be equal")
+
+	names, err := fake.Glob("/etc/foo/*")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/etc/foo/bar"}, names, "should be equal")
+
+	err = fake.Remove("/etc/foo", false)
+	assert.NotNil(t, err)
+
+	err = fake.Remove("/etc/foo", true)
+	assert.Nil(t, err)
+
+	exists, err := Exists(fake, "/etc/foo/bar")
+	assert.Nil(t, err)
+	assert.Equal(t, false, exists, "should be equal")
+
+	fake.Handler = func(eo utils.ExecOptions) (utils.ExecResult, error) {
+		return utils.ExecResult{Stdout: "hello"}, nil
+	}
+
+	er, err := fake.Exec(utils.ExecOptions{Command: "echo hello"})
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", er.Stdout, "should be equal")
+	assert.Equal(t, []string{"echo hello"}, fake.Commands, "should be equal")
+}
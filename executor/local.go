@@ -0,0 +1,198 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/jtopjian/craft/utils"
+)
+
+// Local is an executor for the system craft is running on.
+type Local struct{}
+
+var _ Executor = Local{}
+
+// Exec runs a command on the local system.
+func (Local) Exec(eo utils.ExecOptions) (utils.ExecResult, error) {
+	return utils.Exec(eo)
+}
+
+// ReadFile returns the content of a local file.
+func (Local) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+// WriteFile atomically replaces a local file. The data is written to a
+// temporary file in the same directory, which is synced, given the owner,
+// mode, and extended attributes of the existing file, and renamed over it.
+// A symlink is followed so that its target is replaced.
+func (Local) WriteFile(name string, data []byte, mode os.FileMode) (err error) {
+	uid, gid := -1, -1
+	mode = mode.Perm()
+
+	info, err := os.Stat(name)
+	switch {
+	case err == nil:
+		if info.IsDir() {
+			return &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+
+		mode = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(st.Uid), int(st.Gid)
+		}
+
+		if name, err = filepath.EvalSymlinks(name); err != nil {
+			return
+		}
+	case os.IsNotExist(err):
+		err = nil
+	default:
+		return
+	}
+
+	dir := filepath.Dir(name)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(name)+".craft-")
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return
+	}
+
+	if uid != -1 {
+		if err = tmp.Chown(uid, gid); err != nil {
+			return
+		}
+
+		if err = localCopyXattrs(name, tmp.Name()); err != nil {
+			return
+		}
+	}
+
+	// The mode is set after the owner since chown clears setuid.
+	if err = tmp.Chmod(mode); err != nil {
+		return
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+
+	if err = tmp.Close(); err != nil {
+		return
+	}
+
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return
+	}
+
+	// The directory is synced so that the rename survives a crash.
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+
+	d.Sync()
+
+	return nil
+}
+
+// Stat returns information about a local file.
+func (Local) Stat(name string) (fi FileInfo, err error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return
+	}
+
+	fi.Name = name
+	fi.Mode = info.Mode()
+	fi.Size = info.Size()
+
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		fi.UID = int(st.Uid)
+		fi.GID = int(st.Gid)
+	}
+
+	fi.Owner = strconv.Itoa(fi.UID)
+	if u, err := user.LookupId(fi.Owner); err == nil {
+		fi.Owner = u.Username
+	}
+
+	fi.Group = strconv.Itoa(fi.GID)
+	if g, err := user.LookupGroupId(fi.Group); err == nil {
+		fi.Group = g.Name
+	}
+
+	return
+}
+
+// Chown changes the owner and group of a local file.
+func (Local) Chown(name, owner, group string, recurse bool) (err error) {
+	uid, gid := -1, -1
+
+	if owner != "" {
+		uid, err = utils.UsernameToID(owner)
+		if err != nil {
+			return
+		}
+	}
+
+	if group != "" {
+		gid, err = utils.GroupToID(group)
+		if err != nil {
+			return
+		}
+	}
+
+	if recurse {
+		return utils.ChownR(name, uid, gid)
+	}
+
+	return os.Chown(name, uid, gid)
+}
+
+// Chmod changes the permissions of a local file.
+func (Local) Chmod(name string, mode os.FileMode, recurse bool) error {
+	if recurse {
+		return utils.ChmodR(name, mode)
+	}
+
+	return os.Chmod(name, mode)
+}
+
+// Mkdir creates a local directory.
+func (Local) Mkdir(name string, mode os.FileMode, parents bool) error {
+	if parents {
+		return os.MkdirAll(name, mode)
+	}
+
+	return os.Mkdir(name, mode)
+}
+
+// Glob returns the names of the local files which match a pattern.
+func (Local) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+// Remove removes a local file or directory.
+func (Local) Remove(name string, recurse bool) error {
+	if recurse {
+		return os.RemoveAll(name)
+	}
+
+	return os.Remove(name)
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+)
+
+// localCopyXattrs is an internal function that will copy the extended
+// attributes of one local file to another. File systems which do not
+// support extended attributes are ignored.
+func localCopyXattrs(src, dst string) error {
+	size, err := syscall.Listxattr(src, nil)
+	if err != nil || size == 0 {
+		return localXattrError("listxattr", src, err)
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(src, buf)
+	if err != nil {
+		return localXattrError("listxattr", src, err)
+	}
+
+	for _, attr := range bytes.Split(buf[:size], []byte{0}) {
+		if len(attr) == 0 {
+			continue
+		}
+
+		name := string(attr)
+		n, err := syscall.Getxattr(src, name, nil)
+		if err != nil {
+			return localXattrError("getxattr", src, err)
+		}
+
+		value := make([]byte, n)
+		n, err = syscall.Getxattr(src, name, value)
+		if err != nil {
+			return localXattrError("getxattr", src, err)
+		}
+
+		if err := syscall.Setxattr(dst, name, value[:n], 0); err != nil {
+			return localXattrError("setxattr", dst, err)
+		}
+	}
+
+	return nil
+}
+
+// localXattrError is an internal function that will ignore the errors of
+// file systems which do not support extended attributes.
+func localXattrError(op, name string, err error) error {
+	if err == nil || err == syscall.ENOTSUP || err == syscall.ENODATA {
+		return nil
+	}
+
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
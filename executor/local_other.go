@@ -0,0 +1,9 @@
+//go:build !linux
+
+package executor
+
+// localCopyXattrs is an internal function that does nothing on systems
+// where extended attributes are not copied.
+func localCopyXattrs(src, dst string) error {
+	return nil
+}
@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Local(t *testing.T) {
+	dir, err := ioutil.TempDir("", "craft-executor")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var local Local
+	name := filepath.Join(dir, "foo")
+
+	err = local.WriteFile(name, []byte("bar\n"), 0640)
+	assert.Nil(t, err)
+
+	fi, err := local.Stat(name)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode.Perm(), "should be equal")
+	assert.Equal(t, os.Getuid(), fi.UID, "should be equal")
+
+	lines, err := ReadLines(local, name)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bar", ""}, lines, "should be equal")
+
+	err = local.Remove(name, false)
+	assert.Nil(t, err)
+
+	_, err = local.Stat(name)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_Local_WriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "craft-executor")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var local Local
+	name := filepath.Join(dir, "foo")
+	link := filepath.Join(dir, "link")
+
+	err = ioutil.WriteFile(name, []byte("bar\n"), 0600)
+	assert.Nil(t, err)
+
+	err = os.Chmod(name, 0751)
+	assert.Nil(t, err)
+
+	err = os.Symlink(name, link)
+	assert.Nil(t, err)
+
+	before, err := os.Stat(name)
+	assert.Nil(t, err)
+
+	// Writing through the symlink replaces its target and keeps its mode.
+	err = local.WriteFile(link, []byte("baz\n"), 0644)
+	assert.Nil(t, err)
+
+	after, err := os.Stat(name)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0751), after.Mode().Perm(), "should be equal")
+	assert.False(t, os.SameFile(before, after))
+
+	fi, err := os.Lstat(link)
+	assert.Nil(t, err)
+	assert.Equal(t, os.ModeSymlink, fi.Mode()&os.ModeSymlink, "should be equal")
+
+	content, err := ioutil.ReadFile(name)
+	assert.Nil(t, err)
+	assert.Equal(t, "baz\n", string(content), "should be equal")
+
+	// No temporary files are left behind.
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{name, link}, names, "should be equal")
+
+	err = local.WriteFile(dir, []byte("baz\n"), 0644)
+	assert.NotNil(t, err)
+
+	names, err = filepath.Glob(filepath.Join(dir, ".*"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(names), "should be equal")
+}
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/jtopjian/craft/utils"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH is an executor for a remote system which is reached over SSH.
+// Files are accessed by running commands such as cat and stat, so the
+// remote system must provide a POSIX shell and coreutils. An SSH executor
+// must be created with NewSSH.
+type SSH struct {
+	commandSystem
+
+	// Client is the connection to the remote system.
+	Client *ssh.Client
+
+	// Sudo will run every command with "sudo -n".
+	Sudo bool
+}
+
+var _ Executor = &SSH{}
+
+// NewSSH will connect to a remote system. The address is a host and port
+// such as "example.com:22".
+func NewSSH(address string, config *ssh.ClientConfig) (*SSH, error) {
+	client, err := ssh.Dial("tcp", address, config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SSH{Client: client}
+	s.commandSystem = commandSystem{runner: s}
+
+	return s, nil
+}
+
+// Close will close the connection to the remote system.
+func (s *SSH) Close() error {
+	return s.Client.Close()
+}
+
+// run runs a command in a new SSH session. The session is closed if the
+// command's context is done before the command exits.
+func (s *SSH) run(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+	session, err := s.Client.NewSession()
+	if err != nil {
+		return
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	stdoutLines := utils.NewLineWriter(eo.OnStdout)
+	stderrLines := utils.NewLineWriter(eo.OnStderr)
+	session.Stdin = eo.Stdin
+	session.Stdout = io.MultiWriter(&stdout, stdoutLines)
+	session.Stderr = io.MultiWriter(&stderr, stderrLines)
+
+	if err = session.Start(shellCommand(eo, s.Sudo)); err != nil {
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	ctx := eo.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		err = <-done
+	}
+
+	stdoutLines.Flush()
+	stderrLines.Flush()
+
+	er.Stdout = stdout.String()
+	er.Stderr = stderr.String()
+
+	switch e := err.(type) {
+	case *ssh.ExitError:
+		er.ExitStatus = e.ExitStatus()
+		er.Signal = e.Signal()
+		err = nil
+	case *ssh.ExitMissingError:
+		er.ExitStatus = -1
+		err = nil
+	}
+
+	return
+}
@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jtopjian/craft/utils"
+)
+
+// backupTimeFormat is the format of the timestamp which is appended to
+// the name of a backup. It sorts in the order the backups were made.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// BackupOpts represents the policy used to keep copies of files before
+// they are replaced.
+type BackupOpts struct {
+	// Dir is the directory which backups are kept in. A backup keeps the
+	// path of its file below Dir, followed by a timestamp, such as
+	// /var/backups/craft/etc/sudoers.20240102T030405.000000000Z.
+	// If Dir is empty, no backups are kept.
+	Dir string
+
+	// Keep is the number of backups to keep of each file. Older backups
+	// are removed. If Keep is zero, every backup is kept.
+	Keep int
+}
+
+// WriteOpts represents options used to write a file.
+type WriteOpts struct {
+	// Mode is the mode of the file if it does not exist.
+	Mode os.FileMode
+
+	// Backup is the policy used to keep a copy of the file before it is
+	// replaced.
+	Backup BackupOpts
+
+	// ValidateCmd is a command which checks the new content before the
+	// file is replaced, such as "visudo -cf %s". It is run by sh -c, so
+	// it may quote its arguments. The %s is replaced with the quoted name
+	// of a staged copy of the file in the same directory, which has the
+	// owner and mode of the file. If there is no %s, the name is appended
+	// to the command.
+	ValidateCmd string
+}
+
+// Write will atomically replace the content of a file. If a validation
+// command is set, the file is only replaced if the command succeeds. If a
+// backup directory is set, a copy of the existing file is kept first.
+func Write(e Executor, name string, data []byte, writeOpts WriteOpts) error {
+	if writeOpts.ValidateCmd != "" {
+		if err := Validate(e, name, data, writeOpts); err != nil {
+			return err
+		}
+	}
+
+	if writeOpts.Backup.Dir != "" {
+		if err := Backup(e, name, writeOpts.Backup); err != nil {
+			return err
+		}
+	}
+
+	return e.WriteFile(name, data, writeOpts.Mode)
+}
+
+// Validate will write data to a staged copy of a file and run the
+// validation command against it. The staged copy is always removed and
+// the file itself is never changed.
+func Validate(e Executor, name string, data []byte, writeOpts WriteOpts) (err error) {
+	fi, err := e.Stat(name)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	mode := writeOpts.Mode
+	if exists {
+		mode = fi.Mode.Perm()
+	}
+
+	staged := path.Join(path.Dir(name),
+		"."+path.Base(name)+".craft-validate-"+strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err = e.WriteFile(staged, data, mode); err != nil {
+		return
+	}
+	defer e.Remove(staged, false)
+
+	if exists {
+		if err = e.Chown(staged, fi.Owner, fi.Group, false); err != nil {
+			return
+		}
+
+		// chown may clear setuid and setgid, so the mode is set again.
+		if err = e.Chmod(staged, mode, false); err != nil {
+			return
+		}
+	}
+
+	var eo utils.ExecOptions
+	eo.Args = validateArgs(writeOpts.ValidateCmd, staged)
+	if _, err = e.Exec(eo); err != nil {
+		err = fmt.Errorf("Validation of %s failed: %s", name, err)
+		return
+	}
+
+	return
+}
+
+// Backup will keep a timestamped copy of a file in the backup directory
+// and remove the oldest copies beyond the number to keep. Nothing is done
+// if the file does not exist.
+func Backup(e Executor, name string, backupOpts BackupOpts) error {
+	fi, err := e.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	content, err := e.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	base := path.Join(backupOpts.Dir, path.Clean("/"+name))
+	if err := e.Mkdir(path.Dir(base), 0700, true); err != nil {
+		return err
+	}
+
+	// Backups are written like the file so that a backup which is kept
+	// is never partial, and keep the mode of the file.
+	backup := base + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := e.WriteFile(backup, content, fi.Mode.Perm()); err != nil {
+		return err
+	}
+
+	if backupOpts.Keep <= 0 {
+		return nil
+	}
+
+	backups, err := backupList(e, base)
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > backupOpts.Keep {
+		if err := e.Remove(backups[0], false); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// validateArgs is an internal function that will build the arguments of
+// a validation command for a staged file. The command is run by sh -c.
+func validateArgs(validateCmd, staged string) []string {
+	quoted := utils.ShellQuote(staged)
+
+	command := validateCmd
+	if strings.Contains(command, "%s") {
+		command = strings.Replace(command, "%s", quoted, -1)
+	} else {
+		command += " " + quoted
+	}
+
+	return []string{"sh", "-c", command}
+}
+
+// backupList is an internal function that will return the backups of a
+// file, oldest first.
+func backupList(e Executor, base string) (backups []string, err error) {
+	names, err := e.Glob(base + ".*")
+	if err != nil {
+		return
+	}
+
+	for _, name := range names {
+		suffix := strings.TrimPrefix(name, base+".")
+		if _, err := time.Parse(backupTimeFormat, suffix); err == nil {
+			backups = append(backups, name)
+		}
+	}
+
+	sort.Strings(backups)
+
+	return
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Write(t *testing.T) {
+	fake := NewFake()
+	fake.AddFile("/etc/sudoers", "root ALL=(ALL) ALL\n", 0440)
+
+	writeOpts := WriteOpts{
+		Mode: 0644,
+		Backup: BackupOpts{
+			Dir:  "/var/backups/craft",
+			Keep: 2,
+		},
+	}
+
+	for _, content := range []string{"one\n", "two\n", "three\n"} {
+		err := Write(fake, "/etc/sudoers", []byte(content), writeOpts)
+		assert.Nil(t, err)
+	}
+
+	content, err := fake.ReadFile("/etc/sudoers")
+	assert.Nil(t, err)
+	assert.Equal(t, "three\n", string(content), "should be equal")
+
+	fi, err := fake.Stat("/etc/sudoers")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0440), fi.Mode.Perm(), "should be equal")
+
+	backups, err := backupList(fake, "/var/backups/craft/etc/sudoers")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(backups), "should be equal")
+
+	// The oldest backup, of the original content, has been removed.
+	content, err = fake.ReadFile(backups[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "one\n", string(content), "should be equal")
+
+	content, err = fake.ReadFile(backups[1])
+	assert.Nil(t, err)
+	assert.Equal(t, "two\n", string(content), "should be equal")
+
+	fi, err = fake.Stat(backups[1])
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0440), fi.Mode.Perm(), "should be equal")
+
+	// A new file has nothing to back up.
+	err = Write(fake, "/etc/sudoers.d/deploy", []byte("deploy ALL=(ALL) NOPASSWD: ALL\n"), writeOpts)
+	assert.True(t, os.IsNotExist(err))
+
+	fake.Mkdir("/etc/sudoers.d", 0755, false)
+	err = Write(fake, "/etc/sudoers.d/deploy", []byte("deploy ALL=(ALL) NOPASSWD: ALL\n"), writeOpts)
+	assert.Nil(t, err)
+
+	names, err := fake.Glob("/var/backups/craft/etc/sudoers.d/*")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(names), "should be equal")
+
+	// The sudoers.d backup directory is not a backup of sudoers.
+	backups, err = backupList(fake, "/var/backups/craft/etc/sudoers")
+	assert.Nil(t, err)
+	for _, backup := range backups {
+		assert.True(t, strings.HasPrefix(backup, "/var/backups/craft/etc/sudoers.2"))
+	}
+}
+
+func Test_validateArgs(t *testing.T) {
+	args := validateArgs("sshd -t -f %s", "/etc/ssh/.sshd_config.craft")
+	assert.Equal(t, []string{"sh", "-c", "sshd -t -f /etc/ssh/.sshd_config.craft"}, args, "should be equal")
+
+	args = validateArgs("nginx -t -c", "/etc/nginx/.nginx.conf.craft")
+	assert.Equal(t, []string{"sh", "-c", "nginx -t -c /etc/nginx/.nginx.conf.craft"}, args, "should be equal")
+
+	// Quoted arguments are kept, and the name is quoted.
+	args = validateArgs("sh -c 'visudo -cf \"$1\"' -- %s", "/etc/my sudoers/.sudoers.craft")
+	assert.Equal(t, []string{"sh", "-c", "sh -c 'visudo -cf \"$1\"' -- '/etc/my sudoers/.sudoers.craft'"}, args, "should be equal")
+}
@@ -0,0 +1,33 @@
+/*
+Package facts gathers information about a system, such as its operating
+system, hardware, and network interfaces.
+
+Facts are read through the client's executor, so they describe the system
+being managed, which may be a remote system or a container:
+
+	f, err := facts.Gather(client)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("%s %s on %s\n", f.OS.Name, f.OS.VersionID, f.Arch)
+
+	if f.OS.Like("debian") {
+		// install packages with apt
+	}
+
+Only the operating system is read from /etc/os-release, so it does not
+require lsb_release to be installed:
+
+	os, err := facts.ReadOS(client)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(os.Codename) // jammy
+
+Facts can be exported as JSON:
+
+	data, err := f.JSON()
+*/
+package facts
@@ -0,0 +1,251 @@
+package facts
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/utils"
+)
+
+// Facts represents information about a system.
+type Facts struct {
+	// OS is the operating system of the system.
+	OS OS `json:"os"`
+
+	// Kernel is the release of the kernel, such as "5.15.0-91-generic".
+	Kernel string `json:"kernel"`
+
+	// Arch is the machine architecture, such as "x86_64".
+	Arch string `json:"arch"`
+
+	// Hostname is the short host name of the system.
+	Hostname string `json:"hostname"`
+
+	// FQDN is the fully qualified domain name of the system. It is the
+	// host name if no domain is configured.
+	FQDN string `json:"fqdn"`
+
+	// CPUs is the number of logical processors.
+	CPUs int `json:"cpus"`
+
+	// Memory is the memory of the system.
+	Memory Memory `json:"memory"`
+
+	// Interfaces are the network interfaces of the system.
+	Interfaces []Interface `json:"interfaces"`
+
+	// Mounts are the mounted filesystems of the system.
+	Mounts []Mount `json:"mounts"`
+
+	// PackageManager is the package manager of the system, such as
+	// "apt" or "dnf". It is empty if none was found.
+	PackageManager string `json:"package_manager"`
+
+	// InitSystem is the init system of the system, such as "systemd".
+	// It is empty if it could not be determined, as is common in
+	// containers.
+	InitSystem string `json:"init_system"`
+}
+
+// Gather will collect all facts about the system managed by a client.
+func Gather(client client.Client) (facts Facts, err error) {
+	client.Logger.Debug("Gathering facts")
+
+	if facts.OS, err = ReadOS(client); err != nil {
+		return
+	}
+
+	if facts.Kernel, facts.Arch, err = factsUname(client); err != nil {
+		return
+	}
+
+	if facts.Hostname, facts.FQDN, err = factsHostname(client); err != nil {
+		return
+	}
+
+	if facts.CPUs, err = factsCPUs(client); err != nil {
+		return
+	}
+
+	if facts.Memory, err = ReadMemory(client); err != nil {
+		return
+	}
+
+	if facts.Interfaces, err = ReadInterfaces(client); err != nil {
+		return
+	}
+
+	if facts.Mounts, err = ReadMounts(client); err != nil {
+		return
+	}
+
+	if facts.PackageManager, err = ReadPackageManager(client); err != nil {
+		return
+	}
+
+	if facts.InitSystem, err = ReadInitSystem(client); err != nil {
+		return
+	}
+
+	return
+}
+
+// JSON returns the facts as indented JSON.
+func (f Facts) JSON() ([]byte, error) {
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// packageManagers are the commands of the known package managers in the
+// order they are checked.
+var packageManagers = []struct {
+	name    string
+	command string
+}{
+	{"apt", "apt-get"},
+	{"dnf", "dnf"},
+	{"yum", "yum"},
+	{"apk", "apk"},
+	{"zypper", "zypper"},
+	{"pacman", "pacman"},
+}
+
+// binDirs are the directories searched for the command of a package
+// manager.
+var binDirs = []string{"/usr/bin", "/bin", "/usr/sbin", "/sbin"}
+
+// ReadPackageManager will determine the package manager of a system. An
+// empty string is returned if none of the known package managers is
+// installed.
+func ReadPackageManager(client client.Client) (name string, err error) {
+	system := client.System()
+
+	for _, pm := range packageManagers {
+		for _, dir := range binDirs {
+			var exists bool
+			exists, err = executor.Exists(system, dir+"/"+pm.command)
+			if err != nil {
+				return
+			}
+
+			if exists {
+				name = pm.name
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// ReadInitSystem will determine the init system of a system: "systemd",
+// "openrc", or "sysvinit". An empty string is returned if it could not
+// be determined.
+func ReadInitSystem(client client.Client) (name string, err error) {
+	system := client.System()
+
+	checks := []struct {
+		name string
+		file string
+	}{
+		{"systemd", "/run/systemd/system"},
+		{"openrc", "/run/openrc"},
+	}
+
+	for _, c := range checks {
+		var exists bool
+		exists, err = executor.Exists(system, c.file)
+		if err != nil {
+			return
+		}
+
+		if exists {
+			name = c.name
+			return
+		}
+	}
+
+	comm, err := factsReadFile(client, "/proc/1/comm")
+	if err != nil {
+		return
+	}
+
+	if comm == "init" {
+		name = "sysvinit"
+	}
+
+	return
+}
+
+// factsUname is an internal function that will determine the kernel
+// release and machine architecture of a system.
+func factsUname(client client.Client) (kernel, arch string, err error) {
+	var eo utils.ExecOptions
+
+	eo.Args = []string{"uname", "-r", "-m"}
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	fields := strings.Fields(execResult.Stdout)
+	if len(fields) == 2 {
+		kernel, arch = fields[0], fields[1]
+	}
+
+	return
+}
+
+// factsHostname is an internal function that will determine the host
+// name and fully qualified domain name of a system.
+func factsHostname(client client.Client) (hostname, fqdn string, err error) {
+	var eo utils.ExecOptions
+
+	hostname, err = factsReadFile(client, "/proc/sys/kernel/hostname")
+	if err != nil {
+		return
+	}
+
+	// hostname -f fails if the host name cannot be resolved, in which
+	// case the host name is the best which is known.
+	fqdn = hostname
+	eo.Args = []string{"hostname", "-f"}
+	if execResult, err := client.Exec(eo); err == nil {
+		if v := strings.TrimSpace(execResult.Stdout); v != "" {
+			fqdn = v
+		}
+	}
+
+	return
+}
+
+// factsReadFile is an internal function that will return the trimmed
+// content of a file. An empty string is returned if the file does not
+// exist.
+func factsReadFile(client client.Client, name string) (content string, err error) {
+	exists, err := executor.Exists(client.System(), name)
+	if err != nil || !exists {
+		return
+	}
+
+	data, err := client.System().ReadFile(name)
+	if err != nil {
+		return
+	}
+
+	content = strings.TrimSpace(string(data))
+	return
+}
+
+// factsReadLines is an internal function that will return the lines of
+// a file. No lines are returned if the file does not exist.
+func factsReadLines(client client.Client, name string) (lines []string, err error) {
+	content, err := factsReadFile(client, name)
+	if err != nil || content == "" {
+		return
+	}
+
+	lines = strings.Split(content, "\n")
+	return
+}
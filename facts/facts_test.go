@@ -0,0 +1,157 @@
+package facts
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testOSRelease = `PRETTY_NAME="Ubuntu 22.04.3 LTS"
+NAME="Ubuntu"
+VERSION_ID="22.04"
+VERSION="22.04.3 LTS (Jammy Jellyfish)"
+VERSION_CODENAME=jammy
+ID=ubuntu
+ID_LIKE=debian
+UBUNTU_CODENAME=jammy
+`
+
+const testMeminfo = `MemTotal:        2000000 kB
+MemFree:          500000 kB
+MemAvailable:    1000000 kB
+SwapTotal:             0 kB
+`
+
+const testMounts = `/dev/sda1 / ext4 rw,relatime 0 0
+tmpfs /run tmpfs rw,nosuid,nodev 0 0
+/dev/sdb1 /mnt/my\040disk xfs ro 0 0
+`
+
+const testIPAddr = `1: lo    inet 127.0.0.1/8 scope host lo\       valid_lft forever preferred_lft forever
+1: lo    inet6 ::1/128 scope host \       valid_lft forever preferred_lft forever
+2: eth0@if12    inet 172.17.0.2/16 brd 172.17.255.255 scope global eth0\       valid_lft forever preferred_lft forever
+`
+
+func Test_ParseOSRelease(t *testing.T) {
+	expected := OS{
+		ID:         "ubuntu",
+		IDLike:     []string{"debian"},
+		Name:       "Ubuntu",
+		PrettyName: "Ubuntu 22.04.3 LTS",
+		Version:    "22.04.3 LTS (Jammy Jellyfish)",
+		VersionID:  "22.04",
+		Codename:   "jammy",
+	}
+
+	actual := ParseOSRelease(strings.Split(testOSRelease, "\n"))
+	assert.Equal(t, expected, actual, "should be equal")
+	assert.True(t, actual.Like("debian"))
+	assert.False(t, actual.Like("rhel"))
+
+	actual = ParseOSRelease([]string{"# Alpine", "ID=alpine", "NAME='Alpine Linux'"})
+	assert.Equal(t, OS{ID: "alpine", Name: "Alpine Linux"}, actual, "should be equal")
+}
+
+func Test_ParseMeminfo(t *testing.T) {
+	expected := Memory{
+		Total:     2000000 * 1024,
+		Available: 1000000 * 1024,
+	}
+
+	actual := ParseMeminfo(strings.Split(testMeminfo, "\n"))
+	assert.Equal(t, expected, actual, "should be equal")
+}
+
+func Test_ParseMounts(t *testing.T) {
+	expected := []Mount{
+		{Device: "/dev/sda1", Path: "/", Type: "ext4", Options: []string{"rw", "relatime"}},
+		{Device: "tmpfs", Path: "/run", Type: "tmpfs", Options: []string{"rw", "nosuid", "nodev"}},
+		{Device: "/dev/sdb1", Path: "/mnt/my disk", Type: "xfs", Options: []string{"ro"}},
+	}
+
+	actual := ParseMounts(strings.Split(testMounts, "\n"))
+	assert.Equal(t, expected, actual, "should be equal")
+}
+
+func Test_ParseIPAddr(t *testing.T) {
+	expected := map[string][]string{
+		"lo":   {"127.0.0.1/8", "::1/128"},
+		"eth0": {"172.17.0.2/16"},
+	}
+
+	actual := ParseIPAddr(testIPAddr)
+	assert.Equal(t, expected, actual, "should be equal")
+}
+
+func Test_Gather(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/os-release", testOSRelease, 0644)
+	fake.AddFile("/proc/sys/kernel/hostname", "web1\n", 0644)
+	fake.AddFile("/proc/cpuinfo", "processor\t: 0\nmodel name\t: x\n\nprocessor\t: 1\n", 0644)
+	fake.AddFile("/proc/meminfo", testMeminfo, 0644)
+	fake.AddFile("/proc/mounts", testMounts, 0644)
+	fake.AddFile("/sys/class/net/eth0/address", "02:42:ac:11:00:02\n", 0644)
+	fake.AddFile("/sys/class/net/lo/address", "00:00:00:00:00:00\n", 0644)
+	fake.AddFile("/sys/class/net/bonding_masters", "", 0644)
+	fake.AddFile("/usr/bin/apt-get", "", 0755)
+	fake.Mkdir("/run/systemd/system", 0755, true)
+
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch eo.String() {
+		case "uname -r -m":
+			er.Stdout = "5.15.0-91-generic x86_64\n"
+		case "hostname -f":
+			er.Stdout = "web1.example.com\n"
+		case "ip -o addr show":
+			er.Stdout = testIPAddr
+		default:
+			err = fmt.Errorf("unexpected command: %s", eo.String())
+		}
+		return
+	}
+
+	client := testhelper.TestClient()
+	client.Executor = fake
+
+	f, err := Gather(client)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "jammy", f.OS.Codename, "should be equal")
+	assert.Equal(t, "5.15.0-91-generic", f.Kernel, "should be equal")
+	assert.Equal(t, "x86_64", f.Arch, "should be equal")
+	assert.Equal(t, "web1", f.Hostname, "should be equal")
+	assert.Equal(t, "web1.example.com", f.FQDN, "should be equal")
+	assert.Equal(t, 2, f.CPUs, "should be equal")
+	assert.Equal(t, uint64(2000000*1024), f.Memory.Total, "should be equal")
+	assert.Equal(t, 3, len(f.Mounts), "should be equal")
+	assert.Equal(t, "apt", f.PackageManager, "should be equal")
+	assert.Equal(t, "systemd", f.InitSystem, "should be equal")
+
+	expected := []Interface{
+		{Name: "eth0", MAC: "02:42:ac:11:00:02", Addresses: []string{"172.17.0.2/16"}},
+		{Name: "lo", MAC: "00:00:00:00:00:00", Addresses: []string{"127.0.0.1/8", "::1/128"}},
+	}
+	assert.Equal(t, expected, f.Interfaces, "should be equal")
+
+	data, err := f.JSON()
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"package_manager": "apt"`)
+}
+
+func Test_Gather_Local(t *testing.T) {
+	acc := os.Getenv("TEST_ACC")
+	if acc == "" {
+		t.Skip("TEST_ACC is not set. Skipping")
+	}
+
+	f, err := Gather(testhelper.TestClient())
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", f.OS.ID)
+	assert.NotEqual(t, 0, f.CPUs)
+}
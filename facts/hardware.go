@@ -0,0 +1,148 @@
+package facts
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+)
+
+// Memory represents the memory of a system.
+type Memory struct {
+	// Total is the total memory in bytes.
+	Total uint64 `json:"total"`
+
+	// Available is the memory which is available to start new
+	// applications in bytes.
+	Available uint64 `json:"available"`
+
+	// SwapTotal is the total swap space in bytes.
+	SwapTotal uint64 `json:"swap_total"`
+}
+
+// Mount represents a mounted filesystem.
+type Mount struct {
+	// Device is the mounted device, such as "/dev/sda1" or "tmpfs".
+	Device string `json:"device"`
+
+	// Path is where the filesystem is mounted.
+	Path string `json:"path"`
+
+	// Type is the type of the filesystem, such as "ext4".
+	Type string `json:"type"`
+
+	// Options are the mount options, such as "rw".
+	Options []string `json:"options"`
+}
+
+// ReadMemory will read the memory of a system from /proc/meminfo.
+func ReadMemory(client client.Client) (memory Memory, err error) {
+	lines, err := factsReadLines(client, "/proc/meminfo")
+	if err != nil {
+		return
+	}
+
+	memory = ParseMeminfo(lines)
+	return
+}
+
+// ParseMeminfo parses the lines of /proc/meminfo.
+func ParseMeminfo(lines []string) (memory Memory) {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if len(fields) > 2 && fields[2] == "kB" {
+			v *= 1024
+		}
+
+		switch fields[0] {
+		case "MemTotal:":
+			memory.Total = v
+		case "MemAvailable:":
+			memory.Available = v
+		case "SwapTotal:":
+			memory.SwapTotal = v
+		}
+	}
+
+	return
+}
+
+// ReadMounts will read the mounted filesystems of a system from
+// /proc/mounts.
+func ReadMounts(client client.Client) (mounts []Mount, err error) {
+	lines, err := factsReadLines(client, "/proc/mounts")
+	if err != nil {
+		return
+	}
+
+	mounts = ParseMounts(lines)
+	return
+}
+
+// ParseMounts parses the lines of /proc/mounts.
+func ParseMounts(lines []string) (mounts []Mount) {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		mounts = append(mounts, Mount{
+			Device:  factsUnescapeMount(fields[0]),
+			Path:    factsUnescapeMount(fields[1]),
+			Type:    fields[2],
+			Options: strings.Split(fields[3], ","),
+		})
+	}
+
+	return
+}
+
+// factsCPUs is an internal function that will count the logical
+// processors of a system.
+func factsCPUs(client client.Client) (cpus int, err error) {
+	lines, err := factsReadLines(client, "/proc/cpuinfo")
+	if err != nil {
+		return
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "processor") {
+			cpus++
+		}
+	}
+
+	return
+}
+
+// factsUnescapeMount is an internal function that will decode the octal
+// escapes, such as \040 for a space, of a field of /proc/mounts.
+func factsUnescapeMount(v string) string {
+	if !strings.Contains(v, `\`) {
+		return v
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] == '\\' && i+4 <= len(v) {
+			if n, err := strconv.ParseUint(v[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+
+		b.WriteByte(v[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package facts
+
+import (
+	"path"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/utils"
+)
+
+// Interface represents a network interface.
+type Interface struct {
+	// Name is the name of the interface, such as "eth0".
+	Name string `json:"name"`
+
+	// MAC is the hardware address of the interface.
+	MAC string `json:"mac"`
+
+	// Addresses are the IPv4 and IPv6 addresses of the interface in
+	// CIDR notation, such as "192.168.1.10/24".
+	Addresses []string `json:"addresses"`
+}
+
+// ReadInterfaces will read the network interfaces of a system from
+// /sys/class/net. Addresses are read with the ip command and are not
+// set if it is not installed.
+func ReadInterfaces(client client.Client) (interfaces []Interface, err error) {
+	var eo utils.ExecOptions
+
+	system := client.System()
+
+	names, err := system.Glob("/sys/class/net/*")
+	if err != nil {
+		return
+	}
+
+	for _, name := range names {
+		// Some files, such as bonding_masters, are not interfaces.
+		var fi executor.FileInfo
+		if fi, err = system.Stat(name); err != nil {
+			return
+		}
+
+		if !fi.IsDir() {
+			continue
+		}
+
+		var mac string
+		mac, err = factsReadFile(client, name+"/address")
+		if err != nil {
+			return
+		}
+
+		interfaces = append(interfaces, Interface{
+			Name: path.Base(name),
+			MAC:  mac,
+		})
+	}
+
+	eo.Args = []string{"ip", "-o", "addr", "show"}
+	execResult, ipErr := client.Exec(eo)
+	if ipErr != nil {
+		client.Logger.Debugf("Unable to read addresses: %s", ipErr)
+		return
+	}
+
+	addresses := ParseIPAddr(execResult.Stdout)
+	for i, iface := range interfaces {
+		interfaces[i].Addresses = addresses[iface.Name]
+	}
+
+	return
+}
+
+// ParseIPAddr parses the output of "ip -o addr show" and returns the
+// addresses of each interface.
+func ParseIPAddr(stdout string) map[string][]string {
+	addresses := make(map[string][]string)
+
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		if fields[2] != "inet" && fields[2] != "inet6" {
+			continue
+		}
+
+		// Interfaces of containers are listed as eth0@if12.
+		name := strings.SplitN(fields[1], "@", 2)[0]
+		addresses[name] = append(addresses[name], fields[3])
+	}
+
+	return addresses
+}
@@ -0,0 +1,127 @@
+package facts
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+)
+
+// OS represents the operating system of a system as described by
+// /etc/os-release.
+type OS struct {
+	// ID identifies the operating system in lower case, such as "ubuntu".
+	ID string `json:"id"`
+
+	// IDLike are the operating systems this one derives from, such as
+	// "debian" for Ubuntu.
+	IDLike []string `json:"id_like"`
+
+	// Name is the name of the operating system, such as "Ubuntu".
+	Name string `json:"name"`
+
+	// PrettyName is a descriptive name, such as "Ubuntu 22.04.3 LTS".
+	PrettyName string `json:"pretty_name"`
+
+	// Version is the version including its codename, if any.
+	Version string `json:"version"`
+
+	// VersionID is the version number, such as "22.04".
+	VersionID string `json:"version_id"`
+
+	// Codename is the release codename, such as "jammy". It is empty
+	// for distributions without codenames.
+	Codename string `json:"codename"`
+}
+
+// Like reports whether the operating system is, or derives from, the
+// operating system with the given ID.
+func (o OS) Like(id string) bool {
+	if o.ID == id {
+		return true
+	}
+
+	for _, v := range o.IDLike {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// osReleaseFiles are the locations of the os-release file in the order
+// they are read.
+var osReleaseFiles = []string{"/etc/os-release", "/usr/lib/os-release"}
+
+// ReadOS will read the operating system of a system from its os-release
+// file.
+func ReadOS(client client.Client) (os OS, err error) {
+	client.Logger.Debug("Reading operating system")
+
+	for _, name := range osReleaseFiles {
+		var lines []string
+		lines, err = factsReadLines(client, name)
+		if err != nil {
+			return
+		}
+
+		if len(lines) > 0 {
+			os = ParseOSRelease(lines)
+			return
+		}
+	}
+
+	return
+}
+
+// ParseOSRelease parses the lines of an os-release file.
+func ParseOSRelease(lines []string) (os OS) {
+	values := make(map[string]string)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		values[parts[0]] = factsUnquote(parts[1])
+	}
+
+	os.ID = values["ID"]
+	if v := values["ID_LIKE"]; v != "" {
+		os.IDLike = strings.Fields(v)
+	}
+	os.Name = values["NAME"]
+	os.PrettyName = values["PRETTY_NAME"]
+	os.Version = values["VERSION"]
+	os.VersionID = values["VERSION_ID"]
+
+	os.Codename = values["VERSION_CODENAME"]
+	if os.Codename == "" {
+		os.Codename = values["UBUNTU_CODENAME"]
+	}
+
+	return
+}
+
+// factsUnquote is an internal function that will unquote a value of an
+// os-release file, which follows the quoting rules of a shell.
+func factsUnquote(v string) string {
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return v[1 : len(v)-1]
+	}
+
+	if len(v) >= 2 && v[0] == '"' {
+		if s, err := strconv.Unquote(v); err == nil {
+			return s
+		}
+		return strings.Trim(v, `"`)
+	}
+
+	return v
+}
@@ -0,0 +1,84 @@
+/*
+Package graph orders resources by their dependencies and applies them.
+
+A resource declares the resources it depends on with the Requires
+parameter and the resources which depend on it with the Before parameter.
+Both refer to resources in the form Type[ID]:
+
+	var rs = []resources.Resource{
+		file.Resource{
+			Meta: resources.Meta{
+				Requires: []string{"GitRepo[/srv/app]"},
+			},
+			CreateOpts: file.CreateOpts{
+				Name:    "/srv/app/config.yml",
+				Content: "debug: false\n",
+			},
+		},
+		gitrepo.Resource{
+			CreateOpts: gitrepo.CreateOpts{
+				Name:   "/srv/app",
+				Source: "https://github.com/example/app",
+				Owner:  "deploy",
+			},
+		},
+		useradd.Resource{
+			CreateOpts: useradd.CreateOpts{
+				Name: "deploy",
+			},
+		},
+	}
+
+Some dependencies are added automatically. Above, the repository is
+applied after the deploy user since the user owns it. Other automatic
+dependencies include a file on the directory which contains it, and an
+apt package on every apt key, source, and PPA.
+
+The graph is built once and then applied:
+
+	g, err := graph.New(rs)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, result := range g.Apply(client, graph.ApplyOpts{}) {
+		if result.Err != nil {
+			fmt.Printf("%s[%s]: %s\n", result.Change.Type, result.Change.Name, result.Err)
+		}
+	}
+
+If a resource fails, the resources which depend on it are skipped and
+the others are still applied.
+
+Resources which do not depend on each other can be applied concurrently:
+
+	results := g.Apply(client, graph.ApplyOpts{
+		Concurrency: 4,
+	})
+
+Resources which share a lock are still applied one at a time. All apt
+resources share a lock since apt-get and dpkg cannot run concurrently,
+and cron entries share a lock with the other entries of the same crontab.
+
+A resource can notify handlers, such as a command which reloads a
+service, with the Notify parameter. Once every resource has been applied,
+each handler which was notified by a resource that changed is run once:
+
+	file.Resource{
+		Meta: resources.Meta{
+			Notify: []string{"Exec[reload-nginx]"},
+		},
+		CreateOpts: file.CreateOpts{
+			Name:    "/etc/nginx/conf.d/app.conf",
+			Content: config,
+		},
+	}
+
+Handlers which were run are returned after the other results with the
+action resources.ActionNotify.
+
+Some resources defer work so that it is done once per run. For example,
+systemd is reloaded once after any number of units were written, before
+the next service is started or at the end of the run.
+*/
+package graph
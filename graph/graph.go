@@ -0,0 +1,535 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/resources"
+)
+
+// Graph represents resources and the order in which they must be
+// applied.
+type Graph struct {
+	nodes []*node
+
+	// order is the nodes in topological order.
+	order []*node
+}
+
+// node is an internal type which represents a resource in a graph.
+type node struct {
+	resource resources.Resource
+	ref      resources.Ref
+	index    int
+
+	// requires are the nodes which must be applied before this node.
+	requires []*node
+
+	// notifies are the handlers which are run if this node changed.
+	notifies []*node
+}
+
+// CycleError is returned when resources require each other.
+type CycleError struct {
+	// Cycle lists the resources in the cycle. The first resource is
+	// repeated at the end.
+	Cycle []resources.Ref
+}
+
+func (e CycleError) Error() string {
+	var refs []string
+	for _, ref := range e.Cycle {
+		refs = append(refs, ref.String())
+	}
+
+	return fmt.Sprintf("Dependency cycle: %s", strings.Join(refs, " -> "))
+}
+
+// New will build a graph of resources.
+//
+// A resource is applied after the resources it refers to in its Requires
+// parameter and the resources which refer to it in their Before parameter.
+// Resources which implement resources.AutoRequirer are also applied after
+// the resources they automatically require, if those are in the graph.
+//
+// The resources in the Notify parameter must implement resources.Handler.
+//
+// An error is returned if a resource is listed twice, a reference is
+// invalid or refers to a resource which is not in the graph, a resource
+// notifies a resource which is not a handler, or if the resources form a
+// cycle.
+func New(rs []resources.Resource) (g *Graph, err error) {
+	g = &Graph{}
+	index := make(map[resources.Ref]*node)
+
+	for i, r := range rs {
+		n := &node{resource: r, ref: resources.RefOf(r), index: i}
+		if _, ok := index[n.ref]; ok {
+			err = fmt.Errorf("Duplicate resource %s", n.ref)
+			return
+		}
+
+		index[n.ref] = n
+		g.nodes = append(g.nodes, n)
+	}
+
+	edges := make(map[*node]map[*node]bool)
+	addEdge := func(n, req *node) {
+		if edges[n] == nil {
+			edges[n] = make(map[*node]bool)
+		}
+
+		if !edges[n][req] {
+			edges[n][req] = true
+			n.requires = append(n.requires, req)
+		}
+	}
+
+	for _, n := range g.nodes {
+		meta := n.resource.Metadata()
+
+		for _, s := range meta.Requires {
+			var matches []*node
+			if matches, err = g.lookup(index, n, s); err != nil {
+				return
+			}
+
+			for _, m := range matches {
+				addEdge(n, m)
+			}
+		}
+
+		for _, s := range meta.Before {
+			var matches []*node
+			if matches, err = g.lookup(index, n, s); err != nil {
+				return
+			}
+
+			for _, m := range matches {
+				addEdge(m, n)
+			}
+		}
+
+		for _, s := range meta.Notify {
+			var matches []*node
+			if matches, err = g.lookup(index, n, s); err != nil {
+				return
+			}
+
+			for _, m := range matches {
+				if _, ok := m.resource.(resources.Handler); !ok {
+					err = fmt.Errorf("%s notifies %s which is not a handler", n.ref, m.ref)
+					return
+				}
+
+				n.notifies = append(n.notifies, m)
+			}
+		}
+
+		if ar, ok := n.resource.(resources.AutoRequirer); ok {
+			for _, ref := range ar.AutoRequires() {
+				for _, m := range g.match(index, ref, n) {
+					if m != n {
+						addEdge(n, m)
+					}
+				}
+			}
+		}
+	}
+
+	err = g.sort()
+
+	return
+}
+
+// Resources returns the resources of the graph in the order in which
+// they must be applied. Resources which do not depend on each other
+// keep the order in which they were given.
+func (g *Graph) Resources() (rs []resources.Resource) {
+	for _, n := range g.order {
+		rs = append(rs, n.resource)
+	}
+
+	return
+}
+
+// Requires returns the resources which must be applied before a
+// resource.
+func (g *Graph) Requires(r resources.Resource) (rs []resources.Resource) {
+	ref := resources.RefOf(r)
+	for _, n := range g.nodes {
+		if n.ref != ref {
+			continue
+		}
+
+		for _, req := range n.requires {
+			rs = append(rs, req.resource)
+		}
+	}
+
+	return
+}
+
+// lookup is an internal method that will parse a reference made by a
+// node and return the nodes it refers to.
+func (g *Graph) lookup(index map[resources.Ref]*node, n *node, s string) (matches []*node, err error) {
+	ref, err := resources.ParseRef(s)
+	if err != nil {
+		err = fmt.Errorf("%s: %s", n.ref, err)
+		return
+	}
+
+	matches = g.match(index, ref, n)
+	if len(matches) == 0 && !ref.Wildcard() {
+		err = fmt.Errorf("%s refers to unknown resource %s", n.ref, ref)
+	}
+
+	return
+}
+
+// match is an internal method that will return the nodes which a
+// reference made by a node refers to. A wildcard never refers to the
+// node which made the reference.
+func (g *Graph) match(index map[resources.Ref]*node, ref resources.Ref, from *node) (matches []*node) {
+	if !ref.Wildcard() {
+		if n, ok := index[ref]; ok {
+			matches = append(matches, n)
+		}
+		return
+	}
+
+	for _, n := range g.nodes {
+		if ref.Matches(n.ref) && n != from {
+			matches = append(matches, n)
+		}
+	}
+
+	return
+}
+
+// sort is an internal method that will order the nodes so that every
+// node comes after the nodes it requires. Of the nodes which are ready,
+// the one given first is always chosen so that the order is stable.
+func (g *Graph) sort() error {
+	done := make(map[*node]bool)
+
+	for len(g.order) < len(g.nodes) {
+		var next *node
+		for _, n := range g.nodes {
+			if done[n] {
+				continue
+			}
+
+			ready := true
+			for _, req := range n.requires {
+				if !done[req] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				next = n
+				break
+			}
+		}
+
+		if next == nil {
+			return g.cycle(done)
+		}
+
+		done[next] = true
+		g.order = append(g.order, next)
+	}
+
+	return nil
+}
+
+// cycle is an internal method that will find a cycle among the nodes
+// which could not be ordered.
+func (g *Graph) cycle(done map[*node]bool) error {
+	// Every remaining node requires another remaining node, so
+	// following those requirements must eventually revisit a node.
+	var n *node
+	for _, m := range g.nodes {
+		if !done[m] {
+			n = m
+			break
+		}
+	}
+
+	seen := make(map[*node]int)
+	var path []*node
+	for {
+		if i, ok := seen[n]; ok {
+			path = append(path[i:], n)
+			break
+		}
+
+		seen[n] = len(path)
+		path = append(path, n)
+
+		for _, req := range n.requires {
+			if !done[req] {
+				n = req
+				break
+			}
+		}
+	}
+
+	var e CycleError
+	for _, n := range path {
+		e.Cycle = append(e.Cycle, n.ref)
+	}
+
+	return e
+}
+
+// Result represents the outcome of applying a resource in a graph.
+type Result struct {
+	Resource resources.Resource
+
+	// Change describes what was done to the resource.
+	Change resources.Change
+
+	// Err is the error which occurred when applying the resource.
+	Err error
+
+	// Skipped is set if the resource was not applied because a
+	// resource it requires failed or was skipped.
+	Skipped bool
+}
+
+// ApplyOpts represents options used to apply a graph.
+type ApplyOpts struct {
+	// Concurrency is the maximum number of resources which are applied
+	// at the same time. If it is less than 1, resources are applied one
+	// at a time.
+	Concurrency int
+}
+
+// applied is an internal type that holds the outcome of applying a node.
+type applied struct {
+	node   *node
+	result Result
+}
+
+// Apply will apply the resources of a graph. A resource is applied once
+// the resources it requires have been applied, and up to
+// applyOpts.Concurrency resources are applied at the same time. Resources
+// which implement resources.Locker are never applied at the same time as
+// other resources with the same lock.
+//
+// A resource is skipped if a resource it requires failed or was skipped,
+// but the resources which do not depend on it are still applied.
+//
+// Once every resource has been applied, the handlers which were notified
+// by a resource that changed are run one at a time in order. A handler
+// is run once no matter how many resources notified it, and is not run
+// if it failed or was skipped itself. A handler which was created in the
+// run is not run again, since creating it, such as running a command,
+// already did its work.
+//
+// Actions which resources defer, such as reloading systemd, are run once
+// every resource has been applied, unless a resource ran them earlier.
+// They are run again after the handlers.
+//
+// A result is returned for every resource in the order they finished,
+// followed by a result with the action resources.ActionNotify for every
+// handler which was run. A deferred action which failed is returned as a
+// result with the type of the resource which deferred it.
+func (g *Graph) Apply(client client.Client, applyOpts ApplyOpts) (results []Result) {
+	client = graphDeferred(client)
+
+	concurrency := applyOpts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	started := make(map[*node]bool)
+	finished := make(map[*node]bool)
+	failed := make(map[*node]bool)
+	changed := make(map[*node]bool)
+	created := make(map[*node]bool)
+	locks := make(map[string]bool)
+	done := make(chan applied)
+	running := 0
+
+	for len(results) < len(g.order) {
+		for _, n := range g.order {
+			if running >= concurrency {
+				break
+			}
+
+			if started[n] {
+				continue
+			}
+
+			ready := true
+			var failedReq *node
+			for _, req := range n.requires {
+				if !finished[req] {
+					ready = false
+					break
+				}
+
+				if failed[req] && failedReq == nil {
+					failedReq = req
+				}
+			}
+
+			if !ready {
+				continue
+			}
+
+			if failedReq != nil {
+				client.Logger.Debugf("Skipping %s since %s failed", n.ref, failedReq.ref)
+
+				started[n] = true
+				finished[n] = true
+				failed[n] = true
+				results = append(results, Result{
+					Resource: n.resource,
+					Change:   resources.Change{Type: n.ref.Type, Name: n.ref.ID},
+					Err:      fmt.Errorf("Skipped %s since %s failed", n.ref, failedReq.ref),
+					Skipped:  true,
+				})
+				continue
+			}
+
+			lock := graphLockName(n)
+			if lock != "" {
+				if locks[lock] {
+					continue
+				}
+				locks[lock] = true
+			}
+
+			started[n] = true
+			running++
+
+			go func(n *node) {
+				var a applied
+				a.node = n
+				a.result.Resource = n.resource
+				a.result.Change, a.result.Err = resources.Apply(client, n.resource)
+				done <- a
+			}(n)
+		}
+
+		// Skipping a resource may make others ready without any
+		// resource running, in which case they are checked again.
+		if running == 0 {
+			continue
+		}
+
+		a := <-done
+		running--
+
+		if lock := graphLockName(a.node); lock != "" {
+			delete(locks, lock)
+		}
+
+		finished[a.node] = true
+		if a.result.Err != nil {
+			failed[a.node] = true
+		} else if a.result.Change.Changed() {
+			changed[a.node] = true
+			created[a.node] = a.result.Change.Action == resources.ActionCreate
+		}
+
+		results = append(results, a.result)
+	}
+
+	results = append(results, graphRunDeferred(client)...)
+	results = append(results, g.notify(client, changed, created, failed)...)
+	results = append(results, graphRunDeferred(client)...)
+
+	return
+}
+
+// notify is an internal method that will run the handlers notified by
+// the nodes which changed, unless the handlers were created.
+func (g *Graph) notify(client client.Client, changed, created, failed map[*node]bool) (results []Result) {
+	notified := make(map[*node]bool)
+	for _, n := range g.order {
+		if !changed[n] {
+			continue
+		}
+
+		for _, h := range n.notifies {
+			client.Logger.Debugf("%s notifies %s", n.ref, h.ref)
+			notified[h] = true
+		}
+	}
+
+	for _, h := range g.order {
+		if !notified[h] {
+			continue
+		}
+
+		if failed[h] {
+			client.Logger.Debugf("Not notifying %s since it failed", h.ref)
+			continue
+		}
+
+		if created[h] {
+			client.Logger.Debugf("Not notifying %s since it was created", h.ref)
+			continue
+		}
+
+		client.Logger.Debugf("Notifying %s", h.ref)
+
+		result := Result{
+			Resource: h.resource,
+			Change: resources.Change{
+				Type:   h.ref.Type,
+				Name:   h.ref.ID,
+				Action: resources.ActionNotify,
+			},
+		}
+		result.Err = h.resource.(resources.Handler).Handle(client)
+
+		results = append(results, result)
+	}
+
+	return
+}
+
+// graphDeferred is an internal function that will return a client which
+// defers actions until they are run by the graph.
+func graphDeferred(c client.Client) client.Client {
+	if c.Deferred == nil {
+		c.Deferred = &client.Deferred{}
+	}
+
+	return c
+}
+
+// graphRunDeferred is an internal function that will run the actions which
+// were deferred and return a result for each action which failed.
+func graphRunDeferred(c client.Client) (results []Result) {
+	for _, action := range c.Deferred.Take() {
+		c.Logger.Debugf("Running deferred %s", action.Name)
+
+		if err := action.Run(c); err != nil {
+			results = append(results, Result{
+				Change: resources.Change{Type: action.Type, Name: action.Name},
+				Err:    err,
+			})
+		}
+	}
+
+	return
+}
+
+// graphLockName is an internal function that will return the name of the
+// lock a node needs, if any.
+func graphLockName(n *node) string {
+	if l, ok := n.resource.(resources.Locker); ok {
+		return l.LockName()
+	}
+
+	return ""
+}
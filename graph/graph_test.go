@@ -0,0 +1,454 @@
+package graph
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/aptkey"
+	"github.com/jtopjian/craft/resources/aptpkg"
+	"github.com/jtopjian/craft/resources/aptsource"
+	"github.com/jtopjian/craft/resources/directory"
+	"github.com/jtopjian/craft/resources/exec"
+	"github.com/jtopjian/craft/resources/file"
+	"github.com/jtopjian/craft/resources/gitrepo"
+	"github.com/jtopjian/craft/resources/service"
+	"github.com/jtopjian/craft/resources/systemdunit"
+	"github.com/jtopjian/craft/resources/useradd"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// tracker records how resources of a test were applied.
+type tracker struct {
+	mu sync.Mutex
+
+	// applied are the names of the resources which were created.
+	applied []string
+
+	// running and max are the current and highest number of resources
+	// being created at the same time.
+	running int
+	max     int
+}
+
+// testResource is an in-memory resource used to test a graph.
+type testResource struct {
+	resources.Meta
+
+	Name string
+
+	// fail makes Create return an error.
+	fail bool
+
+	// lock is the name of the lock the resource needs.
+	lock string
+
+	tracker *tracker
+}
+
+func (r testResource) Type() string     { return "Test" }
+func (r testResource) ID() string       { return r.Name }
+func (r testResource) LockName() string { return r.lock }
+
+func (r testResource) Read(client client.Client) (interface{}, error) {
+	return nil, resources.NotFoundError{Type: "Test", Name: r.Name}
+}
+
+func (r testResource) Exists(client client.Client) (bool, error) { return false, nil }
+func (r testResource) Diff(current interface{}) []resources.Diff { return nil }
+
+func (r testResource) Create(client client.Client) error {
+	if r.fail {
+		return fmt.Errorf("unable to create %s", r.Name)
+	}
+
+	t := r.tracker
+	t.mu.Lock()
+	t.running++
+	if t.running > t.max {
+		t.max = t.running
+	}
+	t.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	t.mu.Lock()
+	t.running--
+	t.applied = append(t.applied, r.Name)
+	t.mu.Unlock()
+
+	return nil
+}
+
+func (r testResource) Update(client client.Client, diffs []resources.Diff) error { return nil }
+func (r testResource) Delete(client client.Client) error                         { return nil }
+
+// testHandler is an in-memory handler which is always up to date.
+type testHandler struct {
+	testResource
+
+	// missing makes the handler be created when it is applied.
+	missing bool
+}
+
+func (r testHandler) Type() string                              { return "Handler" }
+func (r testHandler) Exists(client client.Client) (bool, error) { return !r.missing, nil }
+
+func (r testHandler) Read(client client.Client) (interface{}, error) { return r, nil }
+
+func (r testHandler) Handle(client client.Client) error {
+	r.tracker.mu.Lock()
+	defer r.tracker.mu.Unlock()
+
+	r.tracker.applied = append(r.tracker.applied, "notified "+r.Name)
+	return nil
+}
+
+func ids(rs []resources.Resource) (ids []string) {
+	for _, r := range rs {
+		ids = append(ids, resources.RefOf(r).String())
+	}
+	return
+}
+
+func TestGraph_Order(t *testing.T) {
+	rs := []resources.Resource{
+		testResource{Name: "a", Meta: resources.Meta{Requires: []string{"Test[c]"}}},
+		testResource{Name: "b"},
+		testResource{Name: "c"},
+		testResource{Name: "d", Meta: resources.Meta{Before: []string{"Test[b]"}}},
+	}
+
+	g, err := New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Test[c]", "Test[a]", "Test[d]", "Test[b]"}
+	assert.Equal(t, expected, ids(g.Resources()), "should be equal")
+	assert.Equal(t, []string{"Test[c]"}, ids(g.Requires(rs[0])), "should be equal")
+}
+
+func TestGraph_AutoRequires(t *testing.T) {
+	rs := []resources.Resource{
+		file.Resource{CreateOpts: file.CreateOpts{Name: "/srv/app/config.yml", Owner: "deploy"}},
+		gitrepo.Resource{CreateOpts: gitrepo.CreateOpts{Name: "/srv/app", Source: "https://example.com/app"}},
+		aptpkg.Resource{CreateOpts: aptpkg.CreateOpts{Name: "app"}},
+		directory.Resource{CreateOpts: directory.CreateOpts{Name: "/srv"}},
+		useradd.Resource{CreateOpts: useradd.CreateOpts{Name: "deploy"}},
+		aptsource.Resource{CreateOpts: aptsource.CreateOpts{Name: "app"}},
+		aptkey.Resource{CreateOpts: aptkey.CreateOpts{KeyID: "ABCD1234"}},
+	}
+
+	g, err := New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"Directory[/srv]",
+		"GitRepo[/srv/app]",
+		"User[deploy]",
+		"File[/srv/app/config.yml]",
+		"AptKey[ABCD1234]",
+		"AptSource[app]",
+		"AptPkg[app]",
+	}
+
+	assert.Equal(t, expected, ids(g.Resources()), "should be equal")
+
+	// A service only requires its own unit and drop-ins.
+	rs = []resources.Resource{
+		service.Resource{CreateOpts: service.CreateOpts{Name: "app"}},
+		systemdunit.Resource{CreateOpts: systemdunit.CreateOpts{Name: "app.service"}},
+		systemdunit.Resource{CreateOpts: systemdunit.CreateOpts{Name: "app.service", DropIn: "override"}},
+		systemdunit.Resource{CreateOpts: systemdunit.CreateOpts{Name: "other.service"}},
+		systemdunit.Resource{CreateOpts: systemdunit.CreateOpts{Name: "app.timer"}},
+	}
+
+	g, err = New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []string{"SystemdUnit[app.service]", "SystemdUnit[app.service/override]"}
+	assert.Equal(t, expected, ids(g.Requires(rs[0])), "should be equal")
+}
+
+func TestGraph_Errors(t *testing.T) {
+	_, err := New([]resources.Resource{
+		testResource{Name: "a", Meta: resources.Meta{Requires: []string{"Test[b]"}}},
+		testResource{Name: "b", Meta: resources.Meta{Requires: []string{"Test[c]"}}},
+		testResource{Name: "c", Meta: resources.Meta{Requires: []string{"Test[b]"}}},
+	})
+
+	expected := CycleError{Cycle: []resources.Ref{
+		{Type: "Test", ID: "b"},
+		{Type: "Test", ID: "c"},
+		{Type: "Test", ID: "b"},
+	}}
+	assert.Equal(t, expected, err, "should be equal")
+	assert.Equal(t, "Dependency cycle: Test[b] -> Test[c] -> Test[b]", err.Error(), "should be equal")
+
+	_, err = New([]resources.Resource{
+		testResource{Name: "a", Meta: resources.Meta{Requires: []string{"Test[b]"}}},
+	})
+	assert.Equal(t, "Test[a] refers to unknown resource Test[b]", err.Error(), "should be equal")
+
+	_, err = New([]resources.Resource{
+		testResource{Name: "a", Meta: resources.Meta{Requires: []string{"b"}}},
+	})
+	assert.Equal(t, `Test[a]: Invalid resource reference "b": expected Type[ID]`, err.Error(), "should be equal")
+
+	_, err = New([]resources.Resource{testResource{Name: "a"}, testResource{Name: "a"}})
+	assert.Equal(t, "Duplicate resource Test[a]", err.Error(), "should be equal")
+}
+
+func TestGraph_Apply(t *testing.T) {
+	tr := &tracker{}
+
+	rs := []resources.Resource{
+		testResource{Name: "a", fail: true, tracker: tr},
+		testResource{Name: "b", tracker: tr, Meta: resources.Meta{Requires: []string{"Test[a]"}}},
+		testResource{Name: "c", tracker: tr, Meta: resources.Meta{Requires: []string{"Test[b]"}}},
+		testResource{Name: "d", tracker: tr},
+	}
+
+	g, err := New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := g.Apply(testhelper.TestClient(), ApplyOpts{})
+	assert.Equal(t, []string{"d"}, tr.applied, "should be equal")
+	assert.Equal(t, 4, len(results), "should be equal")
+
+	assert.Equal(t, "unable to create a", results[0].Err.Error(), "should be equal")
+	assert.Equal(t, false, results[0].Skipped, "should be equal")
+	assert.Equal(t, true, results[1].Skipped, "should be equal")
+	assert.Equal(t, "Skipped Test[b] since Test[a] failed", results[1].Err.Error(), "should be equal")
+	assert.Equal(t, true, results[2].Skipped, "should be equal")
+	assert.Equal(t, nil, results[3].Err, "should be equal")
+	assert.Equal(t, resources.ActionCreate, results[3].Change.Action, "should be equal")
+}
+
+func TestGraph_Apply_Concurrency(t *testing.T) {
+	tr := &tracker{}
+
+	rs := []resources.Resource{
+		testResource{Name: "a", tracker: tr},
+		testResource{Name: "b", tracker: tr},
+		testResource{Name: "c", tracker: tr},
+		testResource{Name: "d", tracker: tr},
+		testResource{Name: "e", tracker: tr},
+		testResource{Name: "f", tracker: tr, Meta: resources.Meta{Requires: []string{"Test[*]"}}},
+	}
+
+	g, err := New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := g.Apply(testhelper.TestClient(), ApplyOpts{Concurrency: 3})
+	assert.Equal(t, 6, len(results), "should be equal")
+	assert.Equal(t, 3, tr.max, "should be equal")
+	assert.Equal(t, "f", tr.applied[5], "should be equal")
+}
+
+func TestGraph_Apply_Locks(t *testing.T) {
+	tr := &tracker{}
+
+	rs := []resources.Resource{
+		testResource{Name: "a", tracker: tr, lock: "apt"},
+		testResource{Name: "b", tracker: tr, lock: "apt"},
+		testResource{Name: "c", tracker: tr, lock: "apt"},
+	}
+
+	g, err := New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := g.Apply(testhelper.TestClient(), ApplyOpts{Concurrency: 3})
+	assert.Equal(t, 3, len(results), "should be equal")
+	assert.Equal(t, 1, tr.max, "should be equal")
+}
+
+func TestGraph_Apply_Notify(t *testing.T) {
+	tr := &tracker{}
+
+	rs := []resources.Resource{
+		testHandler{testResource: testResource{Name: "reload", tracker: tr}},
+		testHandler{testResource: testResource{Name: "restart", tracker: tr}},
+		testHandler{testResource: testResource{Name: "unchanged", tracker: tr}},
+		testResource{Name: "a", tracker: tr, Meta: resources.Meta{Notify: []string{"Handler[reload]"}}},
+		testResource{Name: "b", tracker: tr, Meta: resources.Meta{Notify: []string{"Handler[reload]"}}},
+		testResource{Name: "c", fail: true, Meta: resources.Meta{Notify: []string{"Handler[restart]"}}},
+		testHandler{testResource: testResource{Name: "d", tracker: tr, Meta: resources.Meta{Notify: []string{"Handler[unchanged]"}}}},
+	}
+
+	g, err := New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := g.Apply(testhelper.TestClient(), ApplyOpts{Concurrency: 2})
+	assert.Equal(t, 8, len(results), "should be equal")
+	assert.Equal(t, "notified reload", tr.applied[len(tr.applied)-1], "should be equal")
+	assert.Equal(t, 3, len(tr.applied), "should be equal")
+
+	last := results[7]
+	assert.Equal(t, nil, last.Err, "should be equal")
+	assert.Equal(t, resources.ActionNotify, last.Change.Action, "should be equal")
+	assert.Equal(t, "reload", last.Change.Name, "should be equal")
+
+	rs = []resources.Resource{
+		testResource{Name: "a", Meta: resources.Meta{Notify: []string{"Test[b]"}}},
+		testResource{Name: "b"},
+	}
+
+	_, err = New(rs)
+	assert.Equal(t, "Test[a] notifies Test[b] which is not a handler", err.Error(), "should be equal")
+}
+
+func TestGraph_Apply_Notify_Created(t *testing.T) {
+	tr := &tracker{}
+
+	// A handler which was created is not notified, whichever resource
+	// finished first.
+	rs := []resources.Resource{
+		testResource{Name: "a", tracker: tr, Meta: resources.Meta{Notify: []string{"Handler[run]"}}},
+		testResource{Name: "b", tracker: tr, Meta: resources.Meta{Notify: []string{"Handler[run]"}}},
+		testHandler{testResource: testResource{Name: "run", tracker: tr}, missing: true},
+		testHandler{testResource: testResource{Name: "reload", tracker: tr}},
+		testResource{Name: "c", tracker: tr, Meta: resources.Meta{Notify: []string{"Handler[reload]"}}},
+	}
+
+	g, err := New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := g.Apply(testhelper.TestClient(), ApplyOpts{Concurrency: 4})
+	assert.Equal(t, 6, len(results), "should be equal")
+	assert.Equal(t, 5, len(tr.applied), "should be equal")
+	assert.Contains(t, tr.applied, "run")
+	assert.NotContains(t, tr.applied, "notified run")
+	assert.Equal(t, "notified reload", tr.applied[4], "should be equal")
+
+	// A command which runs in the same run as the file which notifies
+	// it only runs once.
+	fake := executor.NewFake()
+	fake.Mkdir("/etc/app", 0755, true)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	rs = []resources.Resource{
+		file.Resource{
+			Meta: resources.Meta{Notify: []string{"Exec[reload-app]"}},
+			CreateOpts: file.CreateOpts{
+				Name:    "/etc/app/app.conf",
+				Content: "debug = false\n",
+			},
+		},
+		exec.Resource{
+			Meta: resources.Meta{Requires: []string{"File[/etc/app/app.conf]"}},
+			CreateOpts: exec.CreateOpts{
+				Name:    "reload-app",
+				Command: "app reload",
+				Creates: "/etc/app/reloaded",
+			},
+		},
+	}
+
+	g, err = New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range g.Apply(c, ApplyOpts{}) {
+		assert.Nil(t, result.Err)
+	}
+	assert.Equal(t, []string{"sh -c 'app reload'"}, fake.Commands, "should be equal")
+}
+
+func TestGraph_Apply_Deferred(t *testing.T) {
+	fake := executor.NewFake()
+	fake.Mkdir(systemdunit.UnitDir, 0755, true)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		if eo.Args[1] == "show" {
+			er.Stdout = "Id=app.service\nLoadState=loaded\nActiveState=inactive\nUnitFileState=disabled\n"
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	rs := []resources.Resource{
+		service.Resource{
+			CreateOpts: service.CreateOpts{Name: "app"},
+		},
+		systemdunit.Resource{
+			CreateOpts: systemdunit.CreateOpts{
+				Name:    "app.service",
+				Content: "[Service]\nExecStart=/usr/local/bin/app\n",
+			},
+		},
+		systemdunit.Resource{
+			CreateOpts: systemdunit.CreateOpts{
+				Name:    "app.service",
+				DropIn:  "override",
+				Content: "[Service]\nRestart=always\n",
+			},
+		},
+		systemdunit.Resource{
+			CreateOpts: systemdunit.CreateOpts{
+				Name:    "app.timer",
+				Content: "[Timer]\nOnCalendar=daily\n",
+			},
+		},
+	}
+
+	g, err := New(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range g.Apply(c, ApplyOpts{Concurrency: 4}) {
+		assert.Nil(t, result.Err)
+	}
+
+	var changes []string
+	for _, command := range fake.Commands {
+		if command != "systemctl show --property=Id,Description,LoadState,ActiveState,SubState,UnitFileState,MainPID,FragmentPath -- app" {
+			changes = append(changes, command)
+		}
+	}
+
+	expected := []string{
+		"systemctl daemon-reload",
+		"systemctl start -- app",
+		"systemctl enable -- app",
+	}
+	assert.Equal(t, expected, changes, "should be equal")
+
+	// A unit which changes after every service is reloaded at the end.
+	fake.Commands = nil
+	g, err = New(rs[3:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Remove("/etc/systemd/system/app.timer", false)
+	results := g.Apply(c, ApplyOpts{})
+	assert.Equal(t, 1, len(results), "should be equal")
+	assert.Equal(t, []string{"systemctl daemon-reload"}, fake.Commands, "should be equal")
+}
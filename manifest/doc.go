@@ -0,0 +1,80 @@
+/*
+Package manifest loads resources which are described as data instead of Go
+code.
+
+A manifest is a YAML or JSON document with a list of resources. Each
+resource has a type and the fields of the resource package's CreateOpts,
+written in lower case with underscores:
+
+	resources:
+	  - type: user
+	    name: deploy
+	    shell: /bin/bash
+	    groups: [sudo, docker]
+
+	  - type: file
+	    name: /etc/motd
+	    owner: root
+	    mode: "0644"
+	    content: |
+	      Welcome!
+
+	  - type: cron_entry
+	    user: deploy
+	    name: backup
+	    command: /usr/local/bin/backup
+	    hour: "2"
+
+	  - type: apt_package
+	    name: sl
+	    ensure: absent
+
+The fields common to all resources, such as ensure, may be set on every
+resource. Run Types to list the available resource types.
+
+The requires and before fields order resources. They refer to other
+resources as type[id], where the type is either its name in the manifest
+or the type of the resource:
+
+	  - type: git_repo
+	    name: /srv/app
+	    source: https://github.com/example/app
+	    requires: ["user[deploy]"]
+
+The notify field refers to handlers, such as an exec, which are run at
+the end of the run if the resource changed:
+
+	  - type: file
+	    name: /etc/nginx/conf.d/app.conf
+	    local_source: files/app.conf
+	    notify: ["exec[reload-nginx]"]
+
+	  - type: exec
+	    name: reload-nginx
+	    command: nginx -s reload
+	    only_notified: true
+
+Use the graph package to apply the resources of a manifest in order.
+
+Load a manifest and apply its resources:
+
+	m, err := manifest.Load("host.yaml")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, r := range m.Resources {
+		change, err := resources.Apply(client, r)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+Unknown resource types, unknown fields, values of the wrong type, and
+missing required fields are all reported together, each with the line
+and column where it was found:
+
+	host.yaml:7:5: file: unknown field "perms"
+	host.yaml:12:12: user: field "groups": expected a list, got "sudo"
+*/
+package manifest
@@ -0,0 +1,388 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest represents the desired state of a system as data.
+type Manifest struct {
+	// Resources are the resources of the manifest in the order they
+	// were listed.
+	Resources []resources.Resource
+}
+
+// Error represents a problem at a position in a manifest.
+type Error struct {
+	// File is the name of the manifest file, if known.
+	File string
+
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e Error) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	}
+
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// Errors is a list of problems found in a manifest.
+type Errors []Error
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Load will read and parse a manifest file.
+func Load(fileName string) (m Manifest, err error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+
+	m, err = Parse(data)
+	if errs, ok := err.(Errors); ok {
+		for i := range errs {
+			errs[i].File = fileName
+		}
+	} else if err != nil {
+		err = fmt.Errorf("%s: %s", fileName, err)
+	}
+
+	return
+}
+
+// Parse will parse a manifest in YAML or JSON format. If the manifest is
+// invalid, the returned error is of type Errors and lists every problem
+// which was found.
+func Parse(data []byte) (m Manifest, err error) {
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return
+	}
+
+	d := &decoder{}
+	m = d.document(&doc)
+	if len(d.errs) > 0 {
+		err = d.errs
+	}
+
+	return
+}
+
+// decoder is an internal type that decodes a manifest and collects the
+// problems found.
+type decoder struct {
+	errs Errors
+}
+
+// errorf is an internal method that will record a problem at a node.
+func (d *decoder) errorf(node *yaml.Node, format string, args ...interface{}) {
+	d.errs = append(d.errs, Error{
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// document is an internal method that will decode the root of a manifest.
+func (d *decoder) document(doc *yaml.Node) (m Manifest) {
+	// An empty document is an empty manifest.
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		d.errorf(root, "expected a mapping with a resources key, got %s", nodeKind(root))
+		return
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		switch key.Value {
+		case "resources":
+			m.Resources = d.resources(value)
+		default:
+			d.errorf(key, "unknown field %q", key.Value)
+		}
+	}
+
+	return
+}
+
+// resources is an internal method that will decode a list of resources.
+func (d *decoder) resources(node *yaml.Node) (rs []resources.Resource) {
+	if node.Kind != yaml.SequenceNode {
+		d.errorf(node, "resources: expected a list, got %s", nodeKind(node))
+		return
+	}
+
+	for _, item := range node.Content {
+		if r := d.resource(item); r != nil {
+			rs = append(rs, r)
+		}
+	}
+
+	return
+}
+
+// resource is an internal method that will decode a single resource.
+// nil is returned if the resource is invalid.
+func (d *decoder) resource(node *yaml.Node) resources.Resource {
+	if node.Kind != yaml.MappingNode {
+		d.errorf(node, "expected a resource, got %s", nodeKind(node))
+		return nil
+	}
+
+	var typeNode *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "type" {
+			typeNode = node.Content[i+1]
+		}
+	}
+
+	if typeNode == nil {
+		d.errorf(node, "resource has no type")
+		return nil
+	}
+
+	zero, ok := types[typeNode.Value]
+	if !ok || typeNode.Kind != yaml.ScalarNode {
+		d.errorf(typeNode, "unknown resource type %q", typeNode.Value)
+		return nil
+	}
+
+	typeName := typeNode.Value
+	v := reflect.New(reflect.TypeOf(zero)).Elem()
+	fields := structFields(v.Type())
+
+	errCount := len(d.errs)
+	seen := make(map[string]bool)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "type" {
+			continue
+		}
+
+		if seen[key.Value] {
+			d.errorf(key, "%s: duplicate field %q", typeName, key.Value)
+			continue
+		}
+		seen[key.Value] = true
+
+		index, ok := fields[key.Value]
+		if !ok {
+			d.errorf(key, "%s: unknown field %q", typeName, key.Value)
+			continue
+		}
+
+		field := v.FieldByIndex(index)
+		if err := decodeValue(value, field); err != nil {
+			d.errorf(value, "%s: field %q: %s", typeName, key.Value, err)
+			continue
+		}
+
+		if key.Value == "requires" || key.Value == "before" || key.Value == "notify" {
+			for i, item := range field.Interface().([]string) {
+				ref, err := parseRef(item)
+				if err != nil {
+					d.errorf(value.Content[i], "%s: field %q: %s", typeName, key.Value, err)
+					continue
+				}
+
+				field.Index(i).SetString(ref.String())
+			}
+		}
+	}
+
+	if len(d.errs) > errCount {
+		return nil
+	}
+
+	if err := validate(v); err != nil {
+		if e, ok := err.(utils.MissingInputError); ok {
+			d.errorf(node, "%s: missing required field %q", typeName, fieldName(e.Field))
+		} else {
+			d.errorf(node, "%s: %s", typeName, err)
+		}
+		return nil
+	}
+
+	return v.Interface().(resources.Resource)
+}
+
+// parseRef is an internal function that will parse a reference to a
+// resource. The type may be given by its name in a manifest, such as
+// "user[deploy]", or by the type of the resource, such as "User[deploy]".
+func parseRef(s string) (ref resources.Ref, err error) {
+	ref, err = resources.ParseRef(s)
+	if err != nil {
+		return
+	}
+
+	if r, ok := types[ref.Type]; ok {
+		ref.Type = r.Type()
+	}
+
+	return
+}
+
+// decodeValue is an internal function that will set a field from a
+// node, checking that the node has the type of the field.
+func decodeValue(node *yaml.Node, field reflect.Value) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("expected a string, got %s", nodeKind(node))
+		}
+
+		// The text is used as-is so that values such as a mode of
+		// 0644 are not interpreted as numbers.
+		if node.Tag != "!!null" {
+			field.SetString(node.Value)
+		}
+
+		return nil
+
+	case reflect.Bool:
+		var b bool
+		if node.Kind != yaml.ScalarNode || node.Decode(&b) != nil {
+			return fmt.Errorf("expected a boolean, got %s", nodeKind(node))
+		}
+
+		field.SetBool(b)
+		return nil
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return fmt.Errorf("expected a list, got %s", nodeKind(node))
+		}
+
+		slice := reflect.MakeSlice(field.Type(), len(node.Content), len(node.Content))
+		for i, item := range node.Content {
+			if err := decodeValue(item, slice.Index(i)); err != nil {
+				return fmt.Errorf("item %d: %s", i+1, err)
+			}
+		}
+
+		field.Set(slice)
+		return nil
+	}
+
+	if err := node.Decode(field.Addr().Interface()); err != nil {
+		return fmt.Errorf("expected a %s, got %s", field.Type(), nodeKind(node))
+	}
+
+	return nil
+}
+
+// validate is an internal function that will check the required fields
+// of a resource, including those of its embedded structs.
+func validate(v reflect.Value) error {
+	if err := utils.BuildRequest(v.Addr().Interface()); err != nil {
+		return err
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := validate(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// structFields is an internal function that will map the manifest names
+// of the exported fields of a struct to their index. Fields of embedded
+// structs are included as if they belonged to the struct.
+func structFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for name, index := range structFields(f.Type) {
+				fields[name] = append([]int{i}, index...)
+			}
+			continue
+		}
+
+		if f.PkgPath != "" || f.Tag.Get("manifest") == "-" {
+			continue
+		}
+
+		fields[fieldName(f.Name)] = []int{i}
+	}
+
+	return fields
+}
+
+// fieldName is an internal function that will convert the name of a Go
+// field to its name in a manifest, such as HomeDir to home_dir and KeyID
+// to key_id.
+func fieldName(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		upper := r >= 'A' && r <= 'Z'
+		if upper && i > 0 {
+			prevLower := runes[i-1] >= 'a' && runes[i-1] <= 'z'
+			nextLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
+			prevUpper := runes[i-1] >= 'A' && runes[i-1] <= 'Z'
+			if prevLower || (prevUpper && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+
+		b.WriteString(strings.ToLower(string(r)))
+	}
+
+	return b.String()
+}
+
+// nodeKind is an internal function that will describe a node for an
+// error message.
+func nodeKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.AliasNode:
+		return "an alias"
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "null"
+		}
+		return fmt.Sprintf("%q", node.Value)
+	}
+
+	return "nothing"
+}
@@ -0,0 +1,134 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/cronentry"
+	"github.com/jtopjian/craft/resources/file"
+	"github.com/jtopjian/craft/resources/useradd"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parse(t *testing.T) {
+	data := `
+resources:
+  - type: user
+    name: deploy
+    comment: Deploy User
+    groups: [sudo, docker]
+  - type: file
+    name: /etc/motd
+    mode: 0644
+    content: |
+      Welcome!
+  - type: cron_entry
+    user: deploy
+    name: backup
+    command: /usr/local/bin/backup
+    ensure: absent
+    requires: ["user[deploy]", "File[/etc/motd]"]
+`
+
+	m, err := Parse([]byte(data))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(m.Resources), "should be equal")
+
+	user := m.Resources[0].(useradd.Resource)
+	assert.Equal(t, "Deploy User", user.Comment, "should be equal")
+	assert.Equal(t, []string{"sudo", "docker"}, user.Groups, "should be equal")
+
+	f := m.Resources[1].(file.Resource)
+	assert.Equal(t, "0644", f.Mode, "should be equal")
+	assert.Equal(t, "Welcome!\n", f.Content, "should be equal")
+
+	entry := m.Resources[2].(cronentry.Resource)
+	assert.Equal(t, "deploy", entry.User, "should be equal")
+	assert.Equal(t, resources.Absent, entry.Ensure, "should be equal")
+	assert.Equal(t, []string{"User[deploy]", "File[/etc/motd]"}, entry.Requires, "should be equal")
+}
+
+func Test_Parse_JSON(t *testing.T) {
+	data := `{"resources": [{"type": "group", "name": "docker", "gid": "999"}]}`
+
+	m, err := Parse([]byte(data))
+	assert.Nil(t, err)
+	assert.Equal(t, "Group", m.Resources[0].Type(), "should be equal")
+	assert.Equal(t, "docker", m.Resources[0].ID(), "should be equal")
+
+	data = `{"resources": [{"type": "file", "name": "/etc/app.conf", "template": "{{ .Vars.port }}", "vars": {"port": 8080}}]}`
+
+	m, err = Parse([]byte(data))
+	assert.Nil(t, err)
+
+	f := m.Resources[0].(file.Resource)
+	assert.Equal(t, map[string]interface{}{"port": 8080}, f.Vars, "should be equal")
+}
+
+func Test_Parse_Errors(t *testing.T) {
+	data := `
+resources:
+  - type: file
+    name: /etc/motd
+    perms: "0644"
+  - type: user
+    name: deploy
+    groups: sudo
+  - type: cron_entry
+    name: backup
+    command: /bin/true
+  - type: package
+    name: sl
+  - type: group
+    name: docker
+    before: [user]
+`
+
+	_, err := Parse([]byte(data))
+	errs, ok := err.(Errors)
+	assert.True(t, ok)
+
+	expected := Errors{
+		{Line: 5, Column: 5, Message: `file: unknown field "perms"`},
+		{Line: 8, Column: 13, Message: `user: field "groups": expected a list, got "sudo"`},
+		{Line: 9, Column: 5, Message: `cron_entry: missing required field "user"`},
+		{Line: 12, Column: 11, Message: `unknown resource type "package"`},
+		{Line: 16, Column: 14, Message: `group: field "before": Invalid resource reference "user": expected Type[ID]`},
+	}
+
+	assert.Equal(t, expected, errs, "should be equal")
+}
+
+func Test_fieldName(t *testing.T) {
+	names := map[string]string{
+		"Name":          "name",
+		"HomeDir":       "home_dir",
+		"KeyID":         "key_id",
+		"UID":           "uid",
+		"RemoteKeyFile": "remote_key_file",
+		"DayOfMonth":    "day_of_month",
+	}
+
+	for k, v := range names {
+		assert.Equal(t, v, fieldName(k), "should be equal")
+	}
+}
+
+func Test_Fields(t *testing.T) {
+	fields, ok := Fields("group")
+	assert.True(t, ok)
+
+	expected := []Field{
+		{Name: "ensure", Type: "string"},
+		{Name: "requires", Type: "list"},
+		{Name: "before", Type: "list"},
+		{Name: "notify", Type: "list"},
+		{Name: "name", Type: "string", Required: true},
+		{Name: "gid", Type: "string"},
+	}
+
+	assert.Equal(t, expected, fields, "should be equal")
+
+	_, ok = Fields("package")
+	assert.False(t, ok)
+}
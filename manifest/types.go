@@ -0,0 +1,142 @@
+package manifest
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/apkpkg"
+	"github.com/jtopjian/craft/resources/apthold"
+	"github.com/jtopjian/craft/resources/aptkey"
+	"github.com/jtopjian/craft/resources/aptpin"
+	"github.com/jtopjian/craft/resources/aptpkg"
+	"github.com/jtopjian/craft/resources/aptppa"
+	"github.com/jtopjian/craft/resources/aptsource"
+	"github.com/jtopjian/craft/resources/cronentry"
+	"github.com/jtopjian/craft/resources/debconf"
+	"github.com/jtopjian/craft/resources/directory"
+	"github.com/jtopjian/craft/resources/dnfpkg"
+	"github.com/jtopjian/craft/resources/exec"
+	"github.com/jtopjian/craft/resources/file"
+	"github.com/jtopjian/craft/resources/fileini"
+	"github.com/jtopjian/craft/resources/fileline"
+	"github.com/jtopjian/craft/resources/gitrepo"
+	"github.com/jtopjian/craft/resources/groupadd"
+	"github.com/jtopjian/craft/resources/service"
+	"github.com/jtopjian/craft/resources/systemdunit"
+	"github.com/jtopjian/craft/resources/useradd"
+)
+
+// types maps the name of a resource type in a manifest to a zero value
+// of the resource.
+var types = map[string]resources.Resource{
+	"apk_package":  apkpkg.Resource{},
+	"apt_hold":     apthold.Resource{},
+	"apt_key":      aptkey.Resource{},
+	"apt_package":  aptpkg.Resource{},
+	"apt_pin":      aptpin.Resource{},
+	"apt_ppa":      aptppa.Resource{},
+	"apt_source":   aptsource.Resource{},
+	"cron_entry":   cronentry.Resource{},
+	"debconf":      debconf.Resource{},
+	"directory":    directory.Resource{},
+	"dnf_package":  dnfpkg.Resource{},
+	"exec":         exec.Resource{},
+	"file":         file.Resource{},
+	"file_ini":     fileini.Resource{},
+	"file_line":    fileline.Resource{},
+	"git_repo":     gitrepo.Resource{},
+	"group":        groupadd.Resource{},
+	"service":      service.Resource{},
+	"systemd_unit": systemdunit.Resource{},
+	"user":         useradd.Resource{},
+}
+
+// Register will add a resource type to the manifest format. The resource
+// must be a struct value, such as file.Resource{}. Its exported fields,
+// including those of embedded structs, are set from the manifest. Fields
+// which cannot be written as data, such as an fs.FS, must be tagged with
+// manifest:"-".
+func Register(name string, r resources.Resource) {
+	types[name] = r
+}
+
+// Types returns the names of all resource types in sorted order.
+func Types() (names []string) {
+	for name := range types {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return
+}
+
+// Lookup returns a zero value of the resource type with the given name.
+func Lookup(name string) (resources.Resource, bool) {
+	r, ok := types[name]
+	return r, ok
+}
+
+// Field describes a field of a resource type in a manifest.
+type Field struct {
+	// Name is the name of the field in a manifest, such as home_dir.
+	Name string `json:"name"`
+
+	// Type is the type of the value: "string", "bool", "list", or
+	// "mapping".
+	Type string `json:"type"`
+
+	// Required is whether the field must be set.
+	Required bool `json:"required"`
+
+	// Default is the value used if the field is not set.
+	Default string `json:"default,omitempty"`
+}
+
+// Fields returns the fields of the resource type with the given name in
+// the order they are declared. The fields common to all resources, such
+// as ensure, are listed first.
+func Fields(name string) (fields []Field, ok bool) {
+	r, ok := types[name]
+	if !ok {
+		return
+	}
+
+	fields = typeFields(reflect.TypeOf(r))
+	return
+}
+
+// typeFields is an internal function that will describe the exported
+// fields of a struct, including those of embedded structs.
+func typeFields(t reflect.Type) (fields []Field) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, typeFields(f.Type)...)
+			continue
+		}
+
+		if f.PkgPath != "" || f.Tag.Get("manifest") == "-" {
+			continue
+		}
+
+		field := Field{
+			Name:     fieldName(f.Name),
+			Type:     f.Type.String(),
+			Required: f.Tag.Get("required") == "true",
+			Default:  f.Tag.Get("default"),
+		}
+
+		switch f.Type.Kind() {
+		case reflect.Slice:
+			field.Type = "list"
+		case reflect.Map:
+			field.Type = "mapping"
+		}
+
+		fields = append(fields, field)
+	}
+
+	return
+}
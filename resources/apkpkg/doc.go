@@ -0,0 +1,39 @@
+/*
+Package apkpkg manages a package via apk.
+
+The installed packages are read from the apk database,
+/lib/apk/db/installed.
+
+To see if a package is installed:
+
+	exists, err := apkpkg.Exists(client, "sl")
+
+To install a specific version of a package:
+
+	createOpts := apkpkg.CreateOpts{
+		Name:    "sl",
+		Version: "5.02-r1",
+	}
+
+	err := apkpkg.Create(client, createOpts)
+	if err != nil {
+		return err
+	}
+
+To update a package:
+
+	updateOpts := apkpkg.UpdateOpts{
+		Version: "latest",
+	}
+
+	err := apkpkg.Update(client, "sl", updateOpts)
+	if err != nil {
+		return err
+	}
+
+To obtain a list of all packages installed:
+
+	pkgs, err := apkpkg.List(client)
+
+*/
+package apkpkg
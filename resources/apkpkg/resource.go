@@ -0,0 +1,346 @@
+package apkpkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/utils"
+)
+
+const Type = "ApkPkg"
+
+// InstalledDB is the database of the packages installed by apk.
+const InstalledDB = "/lib/apk/db/installed"
+
+// ApkPkg represents a package managed by apk.
+type ApkPkg struct {
+	// Name is the name of the package.
+	Name string
+
+	// Version is the version of the package.
+	Version string
+
+	// Arch is the architecture of the package.
+	Arch string
+
+	// LatestVersion is the latest version of the package available.
+	LatestVersion string
+}
+
+// CreateOpts represents options used to install a package via apk.
+type CreateOpts struct {
+	// Name is the name of the package.
+	Name string `required:"true"`
+
+	// Version is the version of the package.
+	// The following values are valid: a specific version number and "latest".
+	Version string
+}
+
+// UpdateOpts represents options used to update a package via apk.
+type UpdateOpts struct {
+	// Version is the version of the package.
+	// The following values are valid: a specific version number and "latest".
+	Version string
+}
+
+// Read will retrieve information about an installed apk package.
+func Read(client client.Client, pkgName string) (apkPkg ApkPkg, err error) {
+	var eo utils.ExecOptions
+
+	client.Logger.Debugf("Reading package %s", pkgName)
+
+	pkgs, err := apkPkgReadInstalled(client)
+	if err != nil {
+		return
+	}
+
+	var found bool
+	for _, pkg := range pkgs {
+		if pkg.Name == pkgName {
+			apkPkg = pkg
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		err = resources.NotFoundError{Type: Type, Name: pkgName}
+		return
+	}
+
+	eo.Args = []string{"apk", "list", pkgName}
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	apkPkg.LatestVersion = apkPkgParseApkList(execResult.Stdout, pkgName)
+	if apkPkg.LatestVersion == "" {
+		apkPkg.LatestVersion = apkPkg.Version
+	}
+
+	return
+}
+
+// Exists will report if a given package exists on a system.
+func Exists(client client.Client, pkgName string) (exists bool, err error) {
+	client.Logger.Debugf("Checking if package %s exists", pkgName)
+
+	_, err = Read(client, pkgName)
+	if err != nil {
+		if _, ok := err.(resources.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	exists = true
+
+	return
+}
+
+// List will retrieve all apk managed packages on a system.
+func List(client client.Client) (apkPkgs []ApkPkg, err error) {
+	client.Logger.Debugf("Listing all packages")
+
+	return apkPkgReadInstalled(client)
+}
+
+// Create will install a package via apk.
+func Create(client client.Client, createOpts CreateOpts) (err error) {
+	client.Logger.Debugf("Installing package")
+
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("Package Create Options: %#v", createOpts)
+
+	return apkPkgInstall(client, resources.ActionCreate, createOpts)
+}
+
+// Update will update a package via apk.
+func Update(client client.Client, pkgName string, updateOpts UpdateOpts) (err error) {
+	client.Logger.Debugf("Upgrading package")
+
+	if err = utils.BuildRequest(&updateOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("Package Update Options: %#v", updateOpts)
+
+	createOpts := CreateOpts{
+		Name:    pkgName,
+		Version: updateOpts.Version,
+	}
+
+	return apkPkgInstall(client, resources.ActionUpdate, createOpts)
+}
+
+// apkPkgInstall is an internal function that will install a package
+// via apk. The action is what is being done to the package.
+func apkPkgInstall(client client.Client, action string, createOpts CreateOpts) (err error) {
+	var eo utils.ExecOptions
+
+	// apk records the version constraint in /etc/apk/world, so adding
+	// the package without a version also removes an earlier pin.
+	eo.Args = []string{"apk", "add", "--no-progress"}
+
+	pkg := createOpts.Name
+	switch createOpts.Version {
+	case "":
+	case "latest":
+		eo.Args = append(eo.Args, "--upgrade")
+	default:
+		pkg = fmt.Sprintf("%s=%s", createOpts.Name, createOpts.Version)
+	}
+
+	eo.Args = append(eo.Args, pkg)
+
+	if client.Pending(Type, createOpts.Name, action, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Delete will uninstall a package via apk.
+func Delete(client client.Client, pkgName string) (err error) {
+	var eo utils.ExecOptions
+
+	client.Logger.Debugf("Deleting package %s", pkgName)
+
+	eo.Args = []string{"apk", "del", "--no-progress", pkgName}
+	if client.Pending(Type, pkgName, resources.ActionDelete, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// apkPkgReadInstalled is an internal function that will read the
+// packages in the installed database of the managed system.
+func apkPkgReadInstalled(client client.Client) (pkgs []ApkPkg, err error) {
+	content, err := client.System().ReadFile(InstalledDB)
+	if err != nil {
+		return
+	}
+
+	pkgs = apkPkgParseInstalled(string(content))
+
+	return
+}
+
+// apkPkgParseInstalled is an internal function that will parse the
+// installed database of apk and return the packages in it. Each package
+// is a block of single letter fields, such as "P:musl", separated by a
+// blank line.
+func apkPkgParseInstalled(content string) (pkgs []ApkPkg) {
+	var pkg ApkPkg
+	for _, line := range strings.Split(content+"\n", "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			if pkg.Name != "" {
+				pkgs = append(pkgs, pkg)
+			}
+			pkg = ApkPkg{}
+			continue
+		}
+
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+
+		switch value := line[2:]; line[0] {
+		case 'P':
+			pkg.Name = value
+		case 'V':
+			pkg.Version = value
+		case 'A':
+			pkg.Arch = value
+		}
+	}
+
+	return
+}
+
+// apkPkgParseApkList is an internal function that will parse the output
+// of apk list and return the version of a package which an installed
+// package is upgradable to. Each line is in the form
+// "name-version arch {origin} (license) [status]".
+func apkPkgParseApkList(stdout, pkgName string) (latest string) {
+	for _, line := range strings.Split(stdout, "\n") {
+		if !strings.Contains(line, "[upgradable from: ") {
+			continue
+		}
+
+		v := strings.Fields(line)
+		if len(v) == 0 || !strings.HasPrefix(v[0], pkgName+"-") {
+			continue
+		}
+
+		// A package whose name starts with the name of this one, such
+		// as sl-doc for sl, is told apart by its version starting with
+		// a digit.
+		version := strings.TrimPrefix(v[0], pkgName+"-")
+		if version == "" || version[0] < '0' || version[0] > '9' {
+			continue
+		}
+
+		latest = version
+	}
+
+	return
+}
+
+// Resource represents the desired state of an apk package.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge a package to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the package.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the package.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the package is installed.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff will compare an installed package to the desired version.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	pkg, _ := current.(ApkPkg)
+
+	switch r.Version {
+	case "":
+	case "latest":
+		if pkg.Version != pkg.LatestVersion {
+			diffs = append(diffs, resources.Diff{Field: "Version", Old: pkg.Version, New: pkg.LatestVersion})
+		}
+	default:
+		if pkg.Version != r.Version {
+			diffs = append(diffs, resources.Diff{Field: "Version", Old: pkg.Version, New: r.Version})
+		}
+	}
+
+	return
+}
+
+// Create will install the package.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will update the package to the desired version.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		Version: r.Version,
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will uninstall the package.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// LockName returns the apk lock since apk can only install one package
+// at a time.
+func (r Resource) LockName() string {
+	return "apk"
+}
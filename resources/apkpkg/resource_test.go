@@ -0,0 +1,177 @@
+package apkpkg
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testInstalled = `C:Q1s3cDiT2sZsiyUc6+2FEBnk6mGqo=
+P:musl
+V:1.2.4-r2
+A:x86_64
+S:383352
+I:622592
+T:the musl c library (libc) implementation
+U:https://musl.libc.org/
+L:MIT
+o:musl
+m:Timo Teräs <timo.teras@iki.fi>
+t:1695139402
+c:e87c4b4d0b4e35ed2ae8be6ca1b4eb3da5d6c4fd
+p:so:libc.musl-x86_64.so.1=1
+F:lib
+R:ld-musl-x86_64.so.1
+a:0:0:755
+Z:Q1lm5GlsZFmHKR2AVSfnJbxqoIdf4=
+
+C:Q1bGgf5ViEt6ZiKfz3sRs7bmIxsoQ=
+P:sl
+V:5.02-r1
+A:x86_64
+S:7001
+I:24576
+T:Steam Locomotive runs across your terminal when you type sl
+U:https://github.com/mtoyoda/sl
+L:custom
+o:sl
+D:so:libc.musl-x86_64.so.1 so:libncursesw.so.6
+F:usr
+F:usr/bin
+R:sl
+a:0:0:755
+`
+
+func Test_apkPkgParseInstalled(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected []ApkPkg
+	}{
+		{
+			testInstalled,
+			[]ApkPkg{
+				{Name: "musl", Version: "1.2.4-r2", Arch: "x86_64"},
+				{Name: "sl", Version: "5.02-r1", Arch: "x86_64"},
+			},
+		},
+		{
+			strings.Replace(testInstalled, "\n", "\r\n", -1),
+			[]ApkPkg{
+				{Name: "musl", Version: "1.2.4-r2", Arch: "x86_64"},
+				{Name: "sl", Version: "5.02-r1", Arch: "x86_64"},
+			},
+		},
+		{
+			"P:busybox\nV:1.36.1-r5\n\n\n\nP:zlib\nV:1.3-r2\nA:aarch64",
+			[]ApkPkg{
+				{Name: "busybox", Version: "1.36.1-r5"},
+				{Name: "zlib", Version: "1.3-r2", Arch: "aarch64"},
+			},
+		},
+		{
+			"V:1.0-r0\nA:x86_64\n",
+			nil,
+		},
+		{
+			"",
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		pkgs := apkPkgParseInstalled(test.content)
+		assert.Equal(t, test.expected, pkgs, "should be equal")
+	}
+}
+
+func Test_apkPkgParseApkList(t *testing.T) {
+	tests := []struct {
+		stdout string
+		latest string
+	}{
+		{"sl-5.02-r1 x86_64 {sl} (custom) [installed]\n", ""},
+		{"sl-5.05-r0 x86_64 {sl} (custom) [upgradable from: sl-5.02-r1]\n", "5.05-r0"},
+		{"sl-doc-5.05-r0 x86_64 {sl} (custom) [upgradable from: sl-doc-5.02-r1]\n", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		latest := apkPkgParseApkList(test.stdout, "sl")
+		assert.Equal(t, test.latest, latest, "should be equal")
+	}
+}
+
+func Test_ApkPkg_Fake(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile(InstalledDB, testInstalled, 0644)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		if eo.Args[1] == "list" {
+			er.Stdout = "sl-5.05-r0 x86_64 {sl} (custom) [upgradable from: sl-5.02-r1]\n"
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	pkg, err := Read(c, "sl")
+	assert.Nil(t, err)
+	assert.Equal(t, ApkPkg{Name: "sl", Version: "5.02-r1", Arch: "x86_64", LatestVersion: "5.05-r0"}, pkg, "should be equal")
+
+	exists, err := Exists(c, "curl")
+	assert.Nil(t, err)
+	assert.Equal(t, false, exists, "should be equal")
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:    "sl",
+			Version: "5.02-r1",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Version = "latest"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "update", change.Action, "should be equal")
+	assert.Contains(t, fake.Commands, "apk add --no-progress --upgrade sl")
+
+	r.Name = "curl"
+	r.Version = "8.5.0-r0"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "create", change.Action, "should be equal")
+	assert.Contains(t, fake.Commands, "apk add --no-progress curl=8.5.0-r0")
+}
+
+func Test_ApkPkg_Apply(t *testing.T) {
+	acc := os.Getenv("TEST_ACC")
+	if acc == "" {
+		t.Skip("TEST_ACC is not set. Skipping")
+	}
+
+	client := testhelper.TestClient()
+	pkgName := "sl"
+
+	createOpts := CreateOpts{
+		Name: "sl",
+	}
+
+	err := Create(client, createOpts)
+	assert.Nil(t, err)
+
+	exists, err := Exists(client, pkgName)
+	assert.Nil(t, err)
+	assert.Equal(t, true, exists, "should be equal")
+
+	err = Delete(client, pkgName)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,218 @@
+package resources
+
+import (
+	"fmt"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/utils"
+)
+
+// Valid values of Meta.Ensure.
+const (
+	Present = "present"
+	Absent  = "absent"
+)
+
+// Actions which Apply can take on a resource.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+
+	// ActionNotify is the action of a Handler which was notified.
+	ActionNotify = "notify"
+)
+
+// Meta contains parameters which are common to all resources. They
+// describe how a resource is managed rather than the resource itself.
+type Meta struct {
+	// Ensure is the desired state of the resource.
+	// The following values are valid: "present" and "absent".
+	// If not set, "present" is used.
+	Ensure string
+
+	// Requires are references to resources which must be applied
+	// before this resource, such as "User[deploy]".
+	Requires []string
+
+	// Before are references to resources which must be applied after
+	// this resource.
+	Before []string
+
+	// Notify are references to handlers which are run at the end of the
+	// run if this resource changed, such as "Exec[reload-nginx]".
+	Notify []string
+}
+
+// Metadata returns the common parameters of a resource.
+func (m Meta) Metadata() Meta {
+	return m
+}
+
+// Diff represents a field of a resource whose current value differs
+// from its desired value.
+type Diff struct {
+	// Field is the name of the field.
+	Field string
+
+	// Old is the current value of the field.
+	Old string
+
+	// New is the desired value of the field.
+	New string
+}
+
+// String returns the diff in the form "Field: old -> new".
+func (d Diff) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Field, d.Old, d.New)
+}
+
+// Desired will apply the defaults of the options of a resource, which
+// opts must point to, so that they can be compared to the current state.
+// If the options are not valid, a diff which reports the error is
+// returned instead, so that the resource is changed and Create or Update
+// reports the error.
+func Desired(opts interface{}) (invalid []Diff) {
+	if err := utils.BuildRequest(opts); err != nil {
+		invalid = []Diff{{Field: "Options", New: fmt.Sprintf("invalid: %s", err)}}
+	}
+
+	return
+}
+
+// Change represents the outcome of applying a resource.
+type Change struct {
+	// Type is the type of the resource.
+	Type string
+
+	// Name is the identifier of the resource.
+	Name string
+
+	// Action is the action which was taken on the resource.
+	// It is empty if the resource was already in the desired state.
+	Action string
+
+	// Diffs are the fields which were set by a create or changed by
+	// an update.
+	Diffs []Diff
+
+	// ContentDiff is a unified diff of the content of the resource.
+	// It is only set for resources which implement ContentDiffer and
+	// whose content was changed.
+	ContentDiff string
+}
+
+// Changed reports whether applying the resource changed anything.
+func (c Change) Changed() bool {
+	return c.Action != ""
+}
+
+// Apply will converge a resource to its desired state.
+//
+// If the resource should be absent, it is deleted if it exists. If the
+// resource should be present, it is created if it does not exist.
+// Otherwise its current state is compared to the desired state and it
+// is only updated if any fields differ.
+//
+// Resources which implement Preparer are prepared before they are
+// compared to their current state.
+//
+// The returned Change describes what was done. When a resource is created,
+// its diffs list the desired value of every managed field.
+func Apply(client client.Client, r Resource) (change Change, err error) {
+	change.Type = r.Type()
+	change.Name = r.ID()
+
+	client.Logger.Debugf("Applying %s %s", change.Type, change.Name)
+
+	ensure := r.Metadata().Ensure
+	if ensure == "" {
+		ensure = Present
+	}
+
+	if ensure != Present && ensure != Absent {
+		err = fmt.Errorf("Invalid ensure value for %s %s: %s", change.Type, change.Name, ensure)
+		return
+	}
+
+	exists, err := r.Exists(client)
+	if err != nil {
+		return
+	}
+
+	if ensure == Absent {
+		if !exists {
+			return
+		}
+
+		if err = r.Delete(client); err != nil {
+			return
+		}
+
+		change.Action = ActionDelete
+		return
+	}
+
+	// A resource which is deleted does not need its desired state.
+	if p, ok := r.(Preparer); ok {
+		if r, err = p.Prepare(client); err != nil {
+			return
+		}
+	}
+
+	if !exists {
+		diffs := r.Diff(nil)
+
+		var contentDiff string
+		if contentDiff, err = applyContentDiff(client, r); err != nil {
+			return
+		}
+
+		if err = r.Create(client); err != nil {
+			return
+		}
+
+		change.Action = ActionCreate
+		change.Diffs = diffs
+		change.ContentDiff = contentDiff
+		return
+	}
+
+	current, err := r.Read(client)
+	if err != nil {
+		return
+	}
+
+	diffs := r.Diff(current)
+	if len(diffs) == 0 {
+		client.Logger.Debugf("%s %s is up to date", change.Type, change.Name)
+		return
+	}
+
+	client.Logger.Debugf("%s %s differs: %v", change.Type, change.Name, diffs)
+
+	contentDiff, err := applyContentDiff(client, r)
+	if err != nil {
+		return
+	}
+
+	if err = r.Update(client, diffs); err != nil {
+		return
+	}
+
+	change.Action = ActionUpdate
+	change.Diffs = diffs
+	change.ContentDiff = contentDiff
+
+	return
+}
+
+// applyContentDiff is an internal function that will compute the content
+// diff of a resource before it is changed.
+func applyContentDiff(client client.Client, r Resource) (string, error) {
+	if cd, ok := r.(ContentDiffer); ok {
+		return cd.ContentDiff(client)
+	}
+
+	return "", nil
+}
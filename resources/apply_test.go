@@ -0,0 +1,114 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/stretchr/testify/assert"
+)
+
+// testResource is an in-memory resource used to test Apply.
+type testResource struct {
+	Meta
+
+	Name  string
+	Value string
+
+	// current is the value on the "system". nil means it does not exist.
+	current *string
+	calls   []string
+}
+
+func (r *testResource) Type() string { return "Test" }
+func (r *testResource) ID() string   { return r.Name }
+
+func (r *testResource) Read(client client.Client) (interface{}, error) {
+	if r.current == nil {
+		return nil, NotFoundError{Type: "Test", Name: r.Name}
+	}
+	return *r.current, nil
+}
+
+func (r *testResource) Exists(client client.Client) (bool, error) {
+	return r.current != nil, nil
+}
+
+func (r *testResource) Diff(current interface{}) (diffs []Diff) {
+	if v, _ := current.(string); v != r.Value {
+		diffs = append(diffs, Diff{Field: "Value", Old: v, New: r.Value})
+	}
+	return
+}
+
+func (r *testResource) Create(client client.Client) error {
+	r.calls = append(r.calls, ActionCreate)
+	v := r.Value
+	r.current = &v
+	return nil
+}
+
+func (r *testResource) Update(client client.Client, diffs []Diff) error {
+	r.calls = append(r.calls, ActionUpdate)
+	v := r.Value
+	r.current = &v
+	return nil
+}
+
+func (r *testResource) Delete(client client.Client) error {
+	r.calls = append(r.calls, ActionDelete)
+	r.current = nil
+	return nil
+}
+
+func Test_Apply(t *testing.T) {
+	client := testhelper.TestClient()
+	r := &testResource{Name: "foo", Value: "bar"}
+
+	change, err := Apply(client, r)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionCreate, change.Action, "should be equal")
+	assert.Equal(t, true, change.Changed(), "should be equal")
+	assert.Equal(t, []Diff{{Field: "Value", Old: "", New: "bar"}}, change.Diffs, "should be equal")
+
+	change, err = Apply(client, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Value = "baz"
+	change, err = Apply(client, r)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionUpdate, change.Action, "should be equal")
+	assert.Equal(t, []Diff{{Field: "Value", Old: "bar", New: "baz"}}, change.Diffs, "should be equal")
+
+	r.Ensure = Absent
+	change, err = Apply(client, r)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionDelete, change.Action, "should be equal")
+
+	change, err = Apply(client, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	assert.Equal(t, []string{ActionCreate, ActionUpdate, ActionDelete}, r.calls, "should be equal")
+
+	r.Ensure = "latest"
+	_, err = Apply(client, r)
+	assert.NotNil(t, err)
+}
+
+func Test_Desired(t *testing.T) {
+	opts := struct {
+		Name  string `required:"true"`
+		Shell string `default:"/bin/sh"`
+	}{Name: "deploy"}
+
+	invalid := Desired(&opts)
+	assert.Nil(t, invalid)
+	assert.Equal(t, "/bin/sh", opts.Shell, "should be equal")
+
+	opts.Name = ""
+	invalid = Desired(&opts)
+	assert.Equal(t, 1, len(invalid), "should be equal")
+	assert.Equal(t, "Options", invalid[0].Field, "should be equal")
+}
@@ -0,0 +1,28 @@
+/*
+Package apthold manages the hold of an apt package.
+
+A held package is not upgraded or removed by apt. Holds are set and removed via
+apt-mark and read from the selections of dpkg.
+
+To see if a package is held:
+
+	exists, err := apthold.Exists(client, "nginx")
+
+To hold a package:
+
+	createOpts := apthold.CreateOpts{
+		Name: "nginx",
+	}
+
+	err := apthold.Create(client, createOpts)
+
+To unhold a package:
+
+	err := apthold.Delete(client, "nginx")
+
+To get a list of all held packages:
+
+	holds, err := apthold.List(client)
+
+*/
+package apthold
@@ -0,0 +1,254 @@
+package apthold
+
+import (
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/aptpkg"
+	"github.com/jtopjian/craft/utils"
+)
+
+const Type = "AptHold"
+
+// AptHold represents a package which is held on a system.
+type AptHold struct {
+	// Name is the name of the package.
+	Name string
+}
+
+// CreateOpts represents options used to hold a package via apt-mark.
+type CreateOpts struct {
+	// Name is the name of the package.
+	// It may include an architecture, such as "libc6:i386".
+	Name string `required:"true"`
+}
+
+// Read will return information about a held package. A package which is
+// not held is not found.
+func Read(client client.Client, pkgName string) (aptHold AptHold, err error) {
+	client.Logger.Debugf("Reading hold of package %s", pkgName)
+
+	selections, err := aptHoldReadSelections(client)
+	if err != nil {
+		return
+	}
+
+	if aptHoldFind(selections, pkgName) != "hold" {
+		err = resources.NotFoundError{Type: Type, Name: pkgName}
+		return
+	}
+
+	aptHold = AptHold{
+		Name: pkgName,
+	}
+
+	return
+}
+
+// Exists determines if a package is held on a system.
+func Exists(client client.Client, pkgName string) (exists bool, err error) {
+	client.Logger.Debugf("Checking if package %s is held", pkgName)
+
+	_, err = Read(client, pkgName)
+	if err != nil {
+		if _, ok := err.(resources.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	exists = true
+
+	return
+}
+
+// List will return all packages which are held on a system.
+func List(client client.Client) (aptHolds []AptHold, err error) {
+	client.Logger.Debugf("Listing all held packages")
+
+	selections, err := aptHoldReadSelections(client)
+	if err != nil {
+		return
+	}
+
+	for _, s := range selections {
+		if s.selection == "hold" {
+			aptHolds = append(aptHolds, AptHold{Name: s.name})
+		}
+	}
+
+	return
+}
+
+// Create will hold a package via apt-mark.
+func Create(client client.Client, createOpts CreateOpts) (err error) {
+	var eo utils.ExecOptions
+	client.Logger.Debugf("Holding package")
+
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("AptHold Create Options: %#v", createOpts)
+
+	eo.Args = []string{"apt-mark", "hold", createOpts.Name}
+	if client.Pending(Type, createOpts.Name, resources.ActionCreate, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Update is not implemented for apthold.
+func Update() (err error) {
+	return
+}
+
+// Delete will unhold a package via apt-mark.
+func Delete(client client.Client, pkgName string) (err error) {
+	var eo utils.ExecOptions
+	client.Logger.Debugf("Unholding package %s", pkgName)
+
+	eo.Args = []string{"apt-mark", "unhold", pkgName}
+	if client.Pending(Type, pkgName, resources.ActionDelete, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// selection represents a line of dpkg --get-selections.
+type selection struct {
+	name      string
+	selection string
+}
+
+// aptHoldReadSelections is an internal function that will read the
+// selections of every package via dpkg --get-selections.
+func aptHoldReadSelections(client client.Client) (selections []selection, err error) {
+	var eo utils.ExecOptions
+
+	eo.Args = []string{"dpkg", "--get-selections"}
+	er, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	selections = aptHoldParseSelections(er.Stdout)
+
+	return
+}
+
+// aptHoldParseSelections is an internal function that will parse the
+// output of dpkg --get-selections. Each line has the name of a package,
+// with its architecture if it is not the native one, and its selection.
+func aptHoldParseSelections(stdout string) (selections []selection) {
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		selections = append(selections, selection{name: fields[0], selection: fields[1]})
+	}
+
+	return
+}
+
+// aptHoldFind is an internal function that will return the selection of
+// a package. A name without an architecture also matches the package of
+// a foreign architecture, since dpkg only names the architecture of
+// packages which are not native.
+func aptHoldFind(selections []selection, pkgName string) (sel string) {
+	for _, s := range selections {
+		if s.name == pkgName {
+			return s.selection
+		}
+
+		if !strings.Contains(pkgName, ":") && strings.HasPrefix(s.name, pkgName+":") && sel == "" {
+			sel = s.selection
+		}
+	}
+
+	return
+}
+
+// Resource represents the desired state of a held package.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge the hold of a package to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the package.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the hold of the package.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the package is held.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff always reports no differences since a package is either held
+// or not.
+func (r Resource) Diff(current interface{}) []resources.Diff {
+	return nil
+}
+
+// Create will hold the package.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update does nothing since a hold cannot be updated.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	return nil
+}
+
+// Delete will unhold the package.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// AutoRequires returns the package so that it is installed at the
+// desired version before it is held.
+func (r Resource) AutoRequires() []resources.Ref {
+	return []resources.Ref{{Type: aptpkg.Type, ID: r.Name}}
+}
+
+// LockName returns the apt lock since apt-mark changes the selections
+// of dpkg.
+func (r Resource) LockName() string {
+	return "apt"
+}
@@ -0,0 +1,112 @@
+package apthold
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSelections = `adduser						install
+libc6:amd64					install
+libc6:i386					hold
+nginx						hold
+sl						deinstall
+`
+
+func Test_aptHoldParseSelections(t *testing.T) {
+	expected := []selection{
+		{name: "adduser", selection: "install"},
+		{name: "libc6:amd64", selection: "install"},
+		{name: "libc6:i386", selection: "hold"},
+		{name: "nginx", selection: "hold"},
+		{name: "sl", selection: "deinstall"},
+	}
+
+	selections := aptHoldParseSelections(testSelections)
+	assert.Equal(t, expected, selections, "should be equal")
+
+	tests := []struct {
+		name      string
+		selection string
+	}{
+		{"nginx", "hold"},
+		{"sl", "deinstall"},
+		{"libc6", "install"},
+		{"libc6:i386", "hold"},
+		{"curl", ""},
+	}
+
+	for _, test := range tests {
+		sel := aptHoldFind(selections, test.name)
+		assert.Equal(t, test.selection, sel, test.name)
+	}
+}
+
+func Test_AptHold_Fake(t *testing.T) {
+	fake := executor.NewFake()
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		if eo.Args[0] == "dpkg" {
+			er.Stdout = testSelections
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	holds, err := List(c)
+	assert.Nil(t, err)
+	assert.Equal(t, []AptHold{{Name: "libc6:i386"}, {Name: "nginx"}}, holds, "should be equal")
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name: "nginx",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Name = "sl"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "create", change.Action, "should be equal")
+	assert.Contains(t, fake.Commands, "apt-mark hold sl")
+
+	err = Delete(c, "nginx")
+	assert.Nil(t, err)
+	assert.Contains(t, fake.Commands, "apt-mark unhold nginx")
+}
+
+func Test_AptHold_Apply(t *testing.T) {
+	acc := os.Getenv("TEST_ACC")
+	if acc == "" {
+		t.Skip("TEST_ACC is not set. Skipping")
+	}
+
+	client := testhelper.TestClient()
+	pkgName := "sl"
+
+	createOpts := CreateOpts{
+		Name: pkgName,
+	}
+
+	err := Create(client, createOpts)
+	assert.Nil(t, err)
+
+	exists, err := Exists(client, pkgName)
+	assert.Nil(t, err)
+	assert.Equal(t, true, exists, "should be equal")
+
+	err = Delete(client, pkgName)
+	assert.Nil(t, err)
+
+	exists, err = Exists(client, pkgName)
+	assert.Nil(t, err)
+	assert.Equal(t, false, exists, "should be equal")
+}
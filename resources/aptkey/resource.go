@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/jtopjian/craft/client"
 	"github.com/jtopjian/craft/resources"
@@ -49,8 +49,8 @@ func Read(client client.Client, keyID string) (aptKey AptKey, err error) {
 
 	client.Logger.Debugf("Reading key %s", keyID)
 
-	eo.Command = fmt.Sprintf("apt-key export %s", keyID)
-	execResult, err := utils.Exec(eo)
+	eo.Args = []string{"apt-key", "export", keyID}
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -97,8 +97,8 @@ func List(client client.Client) (aptKeys []AptKey, err error) {
 
 	client.Logger.Debugf("Listing all keys via apt-key list")
 
-	eo.Command = fmt.Sprintf("apt-key list")
-	execResult, err := utils.Exec(eo)
+	eo.Args = []string{"apt-key", "list"}
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -130,6 +130,21 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 
 	client.Logger.Debugf("AptKey Create Options: %#v", createOpts)
 
+	if client.DryRun {
+		if createOpts.RemoteKeyFile != "" {
+			detail := fmt.Sprintf("apt-key add %s", createOpts.RemoteKeyFile)
+			client.Pending(Type, createOpts.KeyID, resources.ActionCreate, detail)
+		}
+
+		if createOpts.KeyServer != "" {
+			detail := fmt.Sprintf("apt-key adv --keyserver %s --recv-keys %s",
+				createOpts.KeyServer, createOpts.KeyID)
+			client.Pending(Type, createOpts.KeyID, resources.ActionCreate, detail)
+		}
+
+		return
+	}
+
 	if createOpts.RemoteKeyFile != "" {
 		var key string
 		key, err = aptKeyGetRemoteKeyFile(createOpts.RemoteKeyFile)
@@ -137,23 +152,19 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 			return
 		}
 
-		var tmpfile *os.File
-		tmpfile, err = ioutil.TempFile("/tmp", "apt-key")
-		if err != nil {
-			return
-		}
-		defer os.Remove(tmpfile.Name())
-
-		if _, err = tmpfile.Write([]byte(key)); err != nil {
-			return
-		}
+		// The key is staged on the managed system since apt-key
+		// must be able to read it there.
+		system := client.System()
+		keyFile := fmt.Sprintf("/tmp/craft-apt-key-%d", time.Now().UnixNano())
 
-		if err = tmpfile.Close(); err != nil {
+		err = system.WriteFile(keyFile, []byte(key), 0600)
+		if err != nil {
 			return
 		}
+		defer system.Remove(keyFile, false)
 
-		eo.Command = fmt.Sprintf("apt-key add %s", tmpfile.Name())
-		execResult, err = utils.Exec(eo)
+		eo.Args = []string{"apt-key", "add", keyFile}
+		execResult, err = client.Exec(eo)
 		if err != nil {
 			return
 		}
@@ -166,10 +177,10 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 	}
 
 	if createOpts.KeyServer != "" {
-		eo.Command = fmt.Sprintf("apt-key adv --keyserver %s --recv-keys %s",
-			createOpts.KeyServer, createOpts.KeyID)
+		eo.Args = []string{"apt-key", "adv", "--keyserver", createOpts.KeyServer,
+			"--recv-keys", createOpts.KeyID}
 
-		execResult, err = utils.Exec(eo)
+		execResult, err = client.Exec(eo)
 		if err != nil {
 			return
 		}
@@ -195,8 +206,12 @@ func Delete(client client.Client, keyID string) (err error) {
 
 	client.Logger.Debugf("Deleting key %s", keyID)
 
-	eo.Command = fmt.Sprintf("apt-key del %s", keyID)
-	execResult, err := utils.Exec(eo)
+	eo.Args = []string{"apt-key", "del", keyID}
+	if client.Pending(Type, keyID, resources.ActionDelete, eo.String()) {
+		return
+	}
+
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -276,3 +291,65 @@ func aptKeyParseList(list string) (keys []string) {
 
 	return
 }
+
+// Resource represents the desired state of an apt-key key.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge a key to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the key ID of the key.
+func (r Resource) ID() string {
+	return r.KeyID
+}
+
+// Read will read the key.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.KeyID)
+}
+
+// Exists will determine if the key exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.KeyID)
+}
+
+// Diff always reports no differences since a key is either present
+// or absent.
+func (r Resource) Diff(current interface{}) []resources.Diff {
+	return nil
+}
+
+// Create will add the key.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update does nothing since a key cannot be updated.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	return nil
+}
+
+// Delete will delete the key.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.KeyID)
+}
+
+// LockName returns the apt lock since apt-key changes the keyring
+// which apt-get reads.
+func (r Resource) LockName() string {
+	return "apt"
+}
@@ -0,0 +1,42 @@
+/*
+Package aptpin manages an apt pin.
+
+A pin sets the priority of some versions of a package, as described by
+apt_preferences(5). Each pin is placed into an individual file under
+/etc/apt/preferences.d. The name of the file is the "name" to reference the pin
+by. For example, the name "nginx" references the file
+/etc/apt/preferences.d/nginx.pref. apt also reads files without an
+extension, so if only /etc/apt/preferences.d/nginx exists, that file is
+managed instead.
+
+To see if a pin exists:
+
+	exists, err := aptpin.Exists(client, "nginx")
+
+To create a pin:
+
+	createOpts := aptpin.CreateOpts{
+		Name:        "nginx",
+		Package:     "nginx nginx-common",
+		Pin:         "version 1.18.*",
+		Priority:    "1001",
+		Explanation: "Keep nginx at 1.18",
+	}
+
+	err := aptpin.Create(client, createOpts)
+
+To delete a pin:
+
+	err := aptpin.Delete(client, "nginx")
+
+To get a list of all pins under /etc/apt/preferences.d:
+
+	pins, err := aptpin.List(client)
+
+Preferences files can also be parsed and formatted:
+
+	prefs, err := aptpin.ParsePreferences(content)
+	content := aptpin.FormatPreferences(prefs)
+
+*/
+package aptpin
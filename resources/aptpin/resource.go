@@ -0,0 +1,572 @@
+package aptpin
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/utils"
+)
+
+const Type = "AptPin"
+
+// PreferencesDir is the directory which holds the apt preferences files.
+const PreferencesDir = "/etc/apt/preferences.d"
+
+// AptPin represents an apt pin in a preferences file.
+type AptPin struct {
+	// Name is the name of the pin.
+	// It is used as the name of the file which contains the pin.
+	Name string
+
+	// Package is the package the pin applies to, such as "nginx",
+	// "linux-image-*", or "*".
+	Package string
+
+	// Pin selects the versions the pin applies to, such as
+	// "version 1.18.*" or "release a=stable".
+	Pin string
+
+	// Priority is the priority of the versions which were selected.
+	Priority string
+
+	// Explanation is a comment which describes the pin.
+	Explanation string
+
+	// Stanzas is the number of stanzas in the file of the pin. A file
+	// which is managed as a pin has a single stanza.
+	Stanzas int
+}
+
+// CreateOpts represents options used to create an apt pin.
+type CreateOpts struct {
+	// Name is the name of the pin.
+	// It is used as the name of the file which contains the pin, so it
+	// may only contain letters, digits, underscores, hyphens, and dots.
+	// The file is name.pref, or name if only that file exists.
+	Name string `required:"true"`
+
+	// Package is the package the pin applies to, such as "nginx",
+	// "linux-image-*", or "*".
+	Package string `required:"true"`
+
+	// Pin selects the versions the pin applies to, such as
+	// "version 1.18.*" or "release a=stable".
+	Pin string `required:"true"`
+
+	// Priority is the priority of the versions which were selected, such
+	// as "1001" to install them even if it means a downgrade.
+	Priority string `required:"true"`
+
+	// Explanation is a comment which describes the pin.
+	Explanation string
+}
+
+// UpdateOpts represents options used to update an apt pin.
+type UpdateOpts struct {
+	// Package is the package the pin applies to.
+	Package string `required:"true"`
+
+	// Pin selects the versions the pin applies to.
+	Pin string `required:"true"`
+
+	// Priority is the priority of the versions which were selected.
+	Priority string `required:"true"`
+
+	// Explanation is a comment which describes the pin.
+	Explanation string
+}
+
+// Read will retrieve information about an existing apt pin. If the file
+// of the pin has more than one stanza, the first one is read and
+// Stanzas reports how many there are.
+func Read(client client.Client, name string) (aptPin AptPin, err error) {
+	client.Logger.Debugf("Reading apt pin %s", name)
+
+	content, err := client.System().ReadFile(aptPinFileName(client, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = resources.NotFoundError{Type: Type, Name: name}
+		}
+		return
+	}
+
+	prefs, err := ParsePreferences(string(content))
+	if err != nil {
+		err = fmt.Errorf("Unable to read apt pin %s: %s", name, err)
+		return
+	}
+
+	if len(prefs) == 0 {
+		err = resources.NotFoundError{Type: Type, Name: name}
+		return
+	}
+
+	aptPin = aptPinNew(name, prefs[0])
+	aptPin.Stanzas = len(prefs)
+
+	return
+}
+
+// Exists will report if a given apt pin exists on a system.
+func Exists(client client.Client, name string) (exists bool, err error) {
+	client.Logger.Debugf("Checking if apt pin %s exists", name)
+
+	_, err = Read(client, name)
+	if err != nil {
+		if _, ok := err.(resources.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	exists = true
+
+	return
+}
+
+// List will list every stanza of the preferences files under
+// /etc/apt/preferences.d. The name of each pin is the name of its file.
+func List(client client.Client) (aptPins []AptPin, err error) {
+	client.Logger.Debugf("Listing all apt pins")
+
+	system := client.System()
+
+	files, err := system.Glob(path.Join(PreferencesDir, "*"))
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		// apt ignores files with an extension other than .pref and
+		// files with other characters in their name.
+		name := path.Base(file)
+		if ext := path.Ext(name); ext == ".pref" {
+			name = strings.TrimSuffix(name, ext)
+		} else if ext != "" {
+			continue
+		}
+
+		if !aptPinNameRe.MatchString(name) {
+			continue
+		}
+
+		var content []byte
+		content, err = system.ReadFile(file)
+		if err != nil {
+			return
+		}
+
+		var prefs []Preference
+		prefs, err = ParsePreferences(string(content))
+		if err != nil {
+			err = fmt.Errorf("Unable to read %s: %s", file, err)
+			return
+		}
+
+		for _, pref := range prefs {
+			aptPin := aptPinNew(name, pref)
+			aptPin.Stanzas = len(prefs)
+			aptPins = append(aptPins, aptPin)
+		}
+	}
+
+	return
+}
+
+// Create will create an apt pin.
+func Create(client client.Client, createOpts CreateOpts) (err error) {
+	client.Logger.Debugf("Creating apt pin")
+
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("AptPin Create Options: %#v", createOpts)
+
+	return aptPinWrite(client, resources.ActionCreate, createOpts)
+}
+
+// Update will update an existing apt pin.
+func Update(client client.Client, name string, updateOpts UpdateOpts) (err error) {
+	client.Logger.Debugf("Updating apt pin %s", name)
+
+	if err = utils.BuildRequest(&updateOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("AptPin Update Options: %#v", updateOpts)
+
+	createOpts := CreateOpts{
+		Name:        name,
+		Package:     updateOpts.Package,
+		Pin:         updateOpts.Pin,
+		Priority:    updateOpts.Priority,
+		Explanation: updateOpts.Explanation,
+	}
+
+	return aptPinWrite(client, resources.ActionUpdate, createOpts)
+}
+
+// Delete will delete an apt pin.
+func Delete(client client.Client, name string) (err error) {
+	client.Logger.Debugf("Deleting apt pin %s", name)
+
+	system := client.System()
+
+	// Both files are removed so that the pin does not linger under the
+	// other name.
+	var fileNames []string
+	for _, fileName := range aptPinFileNames(name) {
+		if _, err := system.Stat(fileName); err == nil {
+			fileNames = append(fileNames, fileName)
+		}
+	}
+
+	if len(fileNames) == 0 {
+		fileNames = aptPinFileNames(name)[:1]
+	}
+
+	if client.Pending(Type, name, resources.ActionDelete, fmt.Sprintf("rm %s", strings.Join(fileNames, " "))) {
+		return
+	}
+
+	for _, fileName := range fileNames {
+		err = system.Remove(fileName, false)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// aptPinNameRe matches the names of the files which apt reads from
+// /etc/apt/preferences.d.
+var aptPinNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// aptPinWrite is an internal function that will write the file of a pin.
+// The action is what is being done to the pin.
+func aptPinWrite(client client.Client, action string, createOpts CreateOpts) (err error) {
+	if !aptPinNameRe.MatchString(createOpts.Name) {
+		err = fmt.Errorf("Invalid name for apt pin %s: only letters, digits, underscores, hyphens, and dots are allowed", createOpts.Name)
+		return
+	}
+
+	priority, err := strconv.Atoi(strings.TrimSpace(createOpts.Priority))
+	if err != nil {
+		err = fmt.Errorf("Invalid priority for apt pin %s: %s", createOpts.Name, createOpts.Priority)
+		return
+	}
+
+	pref := Preference{
+		Package:     createOpts.Package,
+		Pin:         createOpts.Pin,
+		Priority:    priority,
+		Explanation: createOpts.Explanation,
+	}
+
+	fileName := aptPinFileName(client, createOpts.Name)
+	content := FormatPreferences([]Preference{pref})
+	detail := fmt.Sprintf("write %q to %s", content, fileName)
+	if client.Pending(Type, createOpts.Name, action, detail) {
+		return
+	}
+
+	return client.WriteFile(fileName, []byte(content), executor.WriteOpts{Mode: 0644})
+}
+
+// aptPinFileNames is an internal function that will return the names of
+// the files which may contain a pin. apt reads both name.pref and name.
+func aptPinFileNames(name string) []string {
+	return []string{
+		path.Join(PreferencesDir, name+".pref"),
+		path.Join(PreferencesDir, name),
+	}
+}
+
+// aptPinFileName is an internal function that will return the name of the
+// file which contains a pin. A pin is kept in name.pref unless only a file
+// without the extension exists, such as one which was written by hand.
+func aptPinFileName(client client.Client, name string) string {
+	fileNames := aptPinFileNames(name)
+	for _, fileName := range fileNames {
+		if _, err := client.System().Stat(fileName); err == nil {
+			return fileName
+		}
+	}
+
+	return fileNames[0]
+}
+
+// aptPinPriority is an internal function that will normalize a priority,
+// so that "+1001" and "01001" are read back as "1001". A priority which is
+// not a number is returned as is.
+func aptPinPriority(priority string) string {
+	if p, err := strconv.Atoi(strings.TrimSpace(priority)); err == nil {
+		return strconv.Itoa(p)
+	}
+
+	return priority
+}
+
+// aptPinNew is an internal function that will build a pin from a stanza
+// of a preferences file.
+func aptPinNew(name string, pref Preference) AptPin {
+	return AptPin{
+		Name:        name,
+		Package:     pref.Package,
+		Pin:         pref.Pin,
+		Priority:    strconv.Itoa(pref.Priority),
+		Explanation: pref.Explanation,
+	}
+}
+
+// Preference represents a stanza of an apt preferences file.
+type Preference struct {
+	// Package is the Package field.
+	Package string
+
+	// Pin is the Pin field.
+	Pin string
+
+	// Priority is the Pin-Priority field.
+	Priority int
+
+	// Explanation is the text of the Explanation fields, which are
+	// comments. Each field is a line of the text.
+	Explanation string
+}
+
+// ParsePreferences will parse an apt preferences file, as described by
+// apt_preferences(5). A file is made of stanzas separated by blank lines.
+// Each stanza has the fields Package, Pin, and Pin-Priority, and may have
+// Explanation fields. Lines starting with # are comments.
+func ParsePreferences(content string) (prefs []Preference, err error) {
+	var pref Preference
+	var fields map[string]bool
+	var start, last int
+	var lastField string
+
+	end := func() error {
+		if fields == nil {
+			return nil
+		}
+
+		for _, field := range []string{"Package", "Pin", "Pin-Priority"} {
+			if !fields[field] {
+				return fmt.Errorf("stanza at line %d has no %s field", start, field)
+			}
+		}
+
+		prefs = append(prefs, pref)
+		pref = Preference{}
+		fields = nil
+
+		return nil
+	}
+
+	lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+	for i, line := range lines {
+		last = i + 1
+
+		if strings.TrimSpace(line) == "" {
+			if err = end(); err != nil {
+				return
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// A line starting with whitespace continues the previous field.
+		if line[0] == ' ' || line[0] == '\t' {
+			if lastField == "" {
+				err = fmt.Errorf("line %d: continuation line without a field", last)
+				return
+			}
+
+			value := strings.TrimSpace(line)
+			switch lastField {
+			case "Explanation":
+				pref.Explanation += " " + value
+			case "Package":
+				pref.Package += " " + value
+			case "Pin":
+				pref.Pin += " " + value
+			default:
+				err = fmt.Errorf("line %d: %s cannot span several lines", last, lastField)
+				return
+			}
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			err = fmt.Errorf("line %d: expected a field, got %q", last, line)
+			return
+		}
+
+		if fields == nil {
+			fields = make(map[string]bool)
+			start = last
+		}
+
+		name, value := line[:i], strings.TrimSpace(line[i+1:])
+		switch {
+		case strings.EqualFold(name, "Explanation"):
+			lastField = "Explanation"
+			if fields[lastField] {
+				pref.Explanation += "\n"
+			}
+			pref.Explanation += value
+		case strings.EqualFold(name, "Package"):
+			lastField = "Package"
+			pref.Package = value
+		case strings.EqualFold(name, "Pin"):
+			lastField = "Pin"
+			pref.Pin = value
+		case strings.EqualFold(name, "Pin-Priority"):
+			lastField = "Pin-Priority"
+			if pref.Priority, err = strconv.Atoi(value); err != nil {
+				err = fmt.Errorf("line %d: invalid Pin-Priority %q", last, value)
+				return
+			}
+		default:
+			err = fmt.Errorf("line %d: unknown field %s", last, name)
+			return
+		}
+
+		if fields[lastField] && lastField != "Explanation" {
+			err = fmt.Errorf("line %d: duplicate field %s", last, lastField)
+			return
+		}
+		fields[lastField] = true
+	}
+
+	err = end()
+
+	return
+}
+
+// FormatPreferences will format stanzas as an apt preferences file. Each
+// line of an explanation is written as its own Explanation field.
+func FormatPreferences(prefs []Preference) string {
+	var b strings.Builder
+	for i, pref := range prefs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		if pref.Explanation != "" {
+			for _, line := range strings.Split(pref.Explanation, "\n") {
+				fmt.Fprintf(&b, "Explanation: %s\n", line)
+			}
+		}
+
+		fmt.Fprintf(&b, "Package: %s\n", pref.Package)
+		fmt.Fprintf(&b, "Pin: %s\n", pref.Pin)
+		fmt.Fprintf(&b, "Pin-Priority: %d\n", pref.Priority)
+	}
+
+	return b.String()
+}
+
+// Resource represents the desired state of an apt pin.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge an apt pin to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the pin.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the pin.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the pin exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff will compare an existing pin to the desired state. A file with
+// other stanzas than the pin is rewritten.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	aptPin, _ := current.(AptPin)
+
+	if r.Package != aptPin.Package {
+		diffs = append(diffs, resources.Diff{Field: "Package", Old: aptPin.Package, New: r.Package})
+	}
+
+	if r.Pin != aptPin.Pin {
+		diffs = append(diffs, resources.Diff{Field: "Pin", Old: aptPin.Pin, New: r.Pin})
+	}
+
+	if priority := aptPinPriority(r.Priority); priority != aptPin.Priority {
+		diffs = append(diffs, resources.Diff{Field: "Priority", Old: aptPin.Priority, New: priority})
+	}
+
+	if r.Explanation != aptPin.Explanation {
+		diffs = append(diffs, resources.Diff{Field: "Explanation", Old: aptPin.Explanation, New: r.Explanation})
+	}
+
+	if aptPin.Stanzas > 1 {
+		diffs = append(diffs, resources.Diff{Field: "Stanzas", Old: strconv.Itoa(aptPin.Stanzas), New: "1"})
+	}
+
+	return
+}
+
+// Create will create the pin.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will rewrite the file of the pin.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		Package:     r.Package,
+		Pin:         r.Pin,
+		Priority:    r.Priority,
+		Explanation: r.Explanation,
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will delete the pin.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// LockName returns the apt lock so that a pin is not changed while apt
+// chooses the versions of packages to install.
+func (r Resource) LockName() string {
+	return "apt"
+}
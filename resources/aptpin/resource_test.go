@@ -0,0 +1,280 @@
+package aptpin
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPreferences = `# Managed by hand.
+Explanation: Keep nginx at 1.18
+Explanation: until the sites are migrated.
+Package: nginx nginx-common
+Pin: version 1.18.*
+Pin-Priority: 1001
+
+package: *
+pin: release a=bookworm-backports,
+  n=bookworm-backports
+pin-priority: -10
+`
+
+func Test_ParsePreferences(t *testing.T) {
+	expected := []Preference{
+		{
+			Package:     "nginx nginx-common",
+			Pin:         "version 1.18.*",
+			Priority:    1001,
+			Explanation: "Keep nginx at 1.18\nuntil the sites are migrated.",
+		},
+		{
+			Package:  "*",
+			Pin:      "release a=bookworm-backports, n=bookworm-backports",
+			Priority: -10,
+		},
+	}
+
+	prefs, err := ParsePreferences(testPreferences)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, prefs, "should be equal")
+
+	prefs, err = ParsePreferences("\n# Nothing here.\n\n")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(prefs), "should be equal")
+
+	invalid := []string{
+		"Package: nginx\nPin: version 1.18.*\n",
+		"Package: nginx\nPin: version 1.18.*\nPin-Priority: high\n",
+		"Package: nginx\nPin: version 1.18.*\nPin-Priority: 1001\nPriority: 1\n",
+		"Package: nginx\nPackage: nginx-common\nPin: version 1.18.*\nPin-Priority: 1001\n",
+		"Package: nginx\nPin version 1.18.*\nPin-Priority: 1001\n",
+		" Package: nginx\n",
+	}
+
+	for _, content := range invalid {
+		_, err := ParsePreferences(content)
+		assert.NotNil(t, err, content)
+	}
+}
+
+func Test_FormatPreferences(t *testing.T) {
+	prefs := []Preference{
+		{
+			Package:     "nginx nginx-common",
+			Pin:         "version 1.18.*",
+			Priority:    1001,
+			Explanation: "Keep nginx at 1.18\nuntil the sites are migrated.",
+		},
+		{
+			Package:  "*",
+			Pin:      "release a=bookworm-backports",
+			Priority: -10,
+		},
+	}
+
+	expected := `Explanation: Keep nginx at 1.18
+Explanation: until the sites are migrated.
+Package: nginx nginx-common
+Pin: version 1.18.*
+Pin-Priority: 1001
+
+Package: *
+Pin: release a=bookworm-backports
+Pin-Priority: -10
+`
+
+	content := FormatPreferences(prefs)
+	assert.Equal(t, expected, content, "should be equal")
+
+	actual, err := ParsePreferences(content)
+	assert.Nil(t, err)
+	assert.Equal(t, prefs, actual, "should be equal")
+}
+
+func Test_AptPin_Fake(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile(PreferencesDir+"/backports", testPreferences, 0644)
+	fake.AddFile(PreferencesDir+"/backports.save", testPreferences, 0644)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	pins, err := List(c)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(pins), "should be equal")
+	assert.Equal(t, "backports", pins[1].Name, "should be equal")
+	assert.Equal(t, "-10", pins[1].Priority, "should be equal")
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:     "nginx",
+			Package:  "nginx",
+			Pin:      "version 1.18.*",
+			Priority: "1001",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "create", change.Action, "should be equal")
+
+	pin, err := Read(c, "nginx")
+	assert.Nil(t, err)
+	assert.Equal(t, AptPin{Name: "nginx", Package: "nginx", Pin: "version 1.18.*", Priority: "1001", Stanzas: 1}, pin, "should be equal")
+
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Priority = "990"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "update", change.Action, "should be equal")
+
+	content, err := c.System().ReadFile(PreferencesDir + "/nginx.pref")
+	assert.Nil(t, err)
+	assert.Equal(t, "Package: nginx\nPin: version 1.18.*\nPin-Priority: 990\n", string(content), "should be equal")
+
+	r.Name = "../nginx"
+	_, err = Apply(c, r)
+	assert.NotNil(t, err)
+
+	r.Name = "nginx"
+	r.Priority = "high"
+	_, err = Apply(c, r)
+	assert.NotNil(t, err)
+
+	err = Delete(c, "nginx")
+	assert.Nil(t, err)
+
+	exists, err := Exists(c, "nginx")
+	assert.Nil(t, err)
+	assert.Equal(t, false, exists, "should be equal")
+}
+
+func Test_AptPin_Stanzas(t *testing.T) {
+	// A stanza was added to the file of the pin by hand.
+	fake := executor.NewFake()
+	fake.AddFile(PreferencesDir+"/nginx.pref", "Package: nginx\nPin: version 1.18.*\nPin-Priority: 1001\n\nPackage: *\nPin: release a=unstable\nPin-Priority: 900\n", 0644)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:     "nginx",
+			Package:  "nginx",
+			Pin:      "version 1.18.*",
+			Priority: "1001",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "update", change.Action, "should be equal")
+	assert.Equal(t, []resources.Diff{{Field: "Stanzas", Old: "2", New: "1"}}, change.Diffs, "should be equal")
+
+	content, err := c.System().ReadFile(PreferencesDir + "/nginx.pref")
+	assert.Nil(t, err)
+	assert.Equal(t, "Package: nginx\nPin: version 1.18.*\nPin-Priority: 1001\n", string(content), "should be equal")
+
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+}
+
+func Test_AptPin_Priority(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile(PreferencesDir+"/nginx.pref", "Package: nginx\nPin: version 1.18.*\nPin-Priority: 1001\n", 0644)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	for _, priority := range []string{"+1001", "01001", " 1001"} {
+		r := Resource{
+			CreateOpts: CreateOpts{
+				Name:     "nginx",
+				Package:  "nginx",
+				Pin:      "version 1.18.*",
+				Priority: priority,
+			},
+		}
+
+		change, err := Apply(c, r)
+		assert.Nil(t, err)
+		assert.Equal(t, false, change.Changed(), "should be equal")
+	}
+}
+
+func Test_AptPin_NoExtension(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile(PreferencesDir+"/nginx", "Package: nginx\nPin: version 1.18.*\nPin-Priority: 1001\n", 0644)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	pins, err := List(c)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pins), "should be equal")
+	assert.Equal(t, "nginx", pins[0].Name, "should be equal")
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:     "nginx",
+			Package:  "nginx",
+			Pin:      "version 1.18.*",
+			Priority: "990",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "update", change.Action, "should be equal")
+
+	content, err := c.System().ReadFile(PreferencesDir + "/nginx")
+	assert.Nil(t, err)
+	assert.Equal(t, "Package: nginx\nPin: version 1.18.*\nPin-Priority: 990\n", string(content), "should be equal")
+
+	_, err = c.System().Stat(PreferencesDir + "/nginx.pref")
+	assert.NotNil(t, err)
+
+	r.Ensure = resources.Absent
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "delete", change.Action, "should be equal")
+
+	exists, err := Exists(c, "nginx")
+	assert.Nil(t, err)
+	assert.Equal(t, false, exists, "should be equal")
+}
+
+func Test_AptPin_Apply(t *testing.T) {
+	acc := os.Getenv("TEST_ACC")
+	if acc == "" {
+		t.Skip("TEST_ACC is not set. Skipping")
+	}
+
+	client := testhelper.TestClient()
+	name := "craft-test"
+
+	createOpts := CreateOpts{
+		Name:     name,
+		Package:  "sl",
+		Pin:      "version 3.*",
+		Priority: "1001",
+	}
+
+	err := Create(client, createOpts)
+	assert.Nil(t, err)
+
+	exists, err := Exists(client, name)
+	assert.Nil(t, err)
+	assert.Equal(t, true, exists, "should be equal")
+
+	err = Delete(client, name)
+	assert.Nil(t, err)
+}
@@ -1,6 +1,11 @@
 /*
 Package aptpkg manages a package via apt.
 
+The state of packages is read from the dpkg database,
+/var/lib/dpkg/status, or with dpkg-query if it cannot be read. Besides its
+version, a package reports its dpkg status, architecture, source package,
+and whether it is held.
+
 To see if a package is installed:
 
 	exists, err := aptpkg.Exists(client, "sl", "")
@@ -28,6 +33,63 @@ To update a package:
 		return err
 	}
 
+The version may also be a constraint, such as ">= 2.4, << 3" or
+"~> 1.18". A package which satisfies the constraint is left alone.
+Otherwise the highest version which satisfies it is installed:
+
+	createOpts := aptpkg.CreateOpts{
+		Name:    "nginx",
+		Version: "~> 1.18",
+	}
+
+Packages are installed noninteractively, so the questions they ask get their
+default answers. To answer them instead, preseed them in the format of
+debconf-set-selections. The answers are set right before the package is
+installed or upgraded:
+
+	createOpts := aptpkg.CreateOpts{
+		Name: "postfix",
+		Preseed: []string{
+			"postfix postfix/main_mailer_type select Internet Site",
+			"postfix postfix/mailname string mail.example.com",
+		},
+	}
+
+A preseed only applies when apt-get installs or upgrades the package. It is
+not compared with the answers of a package which is already installed, and
+changing it does not reconfigure the package. To manage the answers of an
+installed package, use the debconf resource and reconfigure the package
+with dpkg-reconfigure.
+
+Versions are compared by the rules of dpkg:
+
+	c, err := aptpkg.CompareVersions("1.0~rc1", "1.0")
+	// c is -1
+
+To install many packages in a single apt-get transaction:
+
+	createOpts := []aptpkg.CreateOpts{
+		{Name: "curl"},
+		{Name: "sl", Version: "latest"},
+	}
+
+	results, err := aptpkg.CreateMany(client, createOpts)
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("%s: %s\n", result.Change.Name, result.Err)
+		}
+	}
+
+Only the packages which are missing or not at their desired version are
+installed. A package which is held, such as with an apthold resource, is
+never upgraded or downgraded. If apt-get fails, the result of each package which was not
+installed reports whether it caused the failure.
+
+To read the version apt-get would install from the package lists which
+were last downloaded:
+
+	candidate, err := aptpkg.Candidate(client, "sl")
+
 To obtain a list of all packages installed:
 
 	pkgs, err := aptpkg.List(client)
@@ -2,17 +2,37 @@ package aptpkg
 
 import (
 	"fmt"
-	"os"
 	"regexp"
 	"strings"
 
 	"github.com/jtopjian/craft/client"
 	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/aptkey"
+	"github.com/jtopjian/craft/resources/aptpin"
+	"github.com/jtopjian/craft/resources/aptppa"
+	"github.com/jtopjian/craft/resources/aptsource"
+	"github.com/jtopjian/craft/resources/debconf"
 	"github.com/jtopjian/craft/utils"
 )
 
 const Type = "AptPkg"
 
+// StatusFile is the database of the packages known to dpkg.
+const StatusFile = "/var/lib/dpkg/status"
+
+// Valid values of AptPkg.Status. They are the states of a package in the
+// dpkg database.
+const (
+	StatusNotInstalled    = "not-installed"
+	StatusConfigFiles     = "config-files"
+	StatusHalfInstalled   = "half-installed"
+	StatusUnpacked        = "unpacked"
+	StatusHalfConfigured  = "half-configured"
+	StatusTriggersAwaited = "triggers-awaited"
+	StatusTriggersPending = "triggers-pending"
+	StatusInstalled       = "installed"
+)
+
 // AptPkg represents a package managed by apt.
 type AptPkg struct {
 	// Name is the name of the package.
@@ -23,6 +43,21 @@ type AptPkg struct {
 
 	// LatestVersion is the latest version of the package available.
 	LatestVersion string
+
+	// Status is the state of the package in the dpkg database, such as
+	// "installed" or "config-files".
+	Status string
+
+	// Architecture is the architecture of the package, such as "amd64"
+	// or "all".
+	Architecture string
+
+	// Source is the name of the source package the package was built
+	// from.
+	Source string
+
+	// Hold is set if the package is held at its version.
+	Hold bool
 }
 
 // CreateOpts represents options used to install a package vi apt-get.
@@ -31,38 +66,64 @@ type CreateOpts struct {
 	Name string `required:"true"`
 
 	// Version is the version of the package.
-	// The following values are valid: a specific version number and "latest".
+	// The following values are valid: a specific version number, "latest",
+	// "installed" for any version, and version constraints separated by
+	// commas, such as ">= 2.4, << 3" or "~> 1.18". See ParseConstraints.
 	Version string
+
+	// Preseed are answers to debconf questions which are set before the
+	// package is installed, in the format of debconf-set-selections,
+	// such as "postfix postfix/main_mailer_type select Internet Site".
+	// They are only set when the package is installed or upgraded and
+	// are not compared with the answers of an installed package.
+	Preseed []string
 }
 
 // UpdateOpts represents options used to update a package vi apt-get.
 type UpdateOpts struct {
 	// Version is the version of the package.
-	// The following values are valid: a specific version number and "latest".
+	// The following values are valid: a specific version number, "latest",
+	// "installed" for any version, and version constraints.
 	Version string
+
+	// Preseed are answers to debconf questions which are set before the
+	// package is upgraded.
+	Preseed []string
 }
 
 // Read will retrieve information about an installed apt package.
 func Read(client client.Client, pkgName string) (aptPkg AptPkg, err error) {
-	var eo utils.ExecOptions
-
 	client.Logger.Debugf("Reading package %s", pkgName)
 
-	eo.Command = fmt.Sprintf("apt-cache policy %s", pkgName)
-	execResult, err := utils.Exec(eo)
+	aptPkg, err = aptPkgRead(client, pkgName)
 	if err != nil {
 		return
 	}
 
-	if execResult.Stdout == "" {
-		err = resources.NotFoundError{Type: Type, Name: pkgName}
+	aptPkg.LatestVersion, err = Candidate(client, pkgName)
+
+	return
+}
+
+// Candidate will retrieve the version of a package which apt-get would
+// install. It is read from the package lists which were last downloaded,
+// so the lists are not updated and no network access is needed. An empty
+// string is returned if no version is available.
+func Candidate(client client.Client, pkgName string) (candidate string, err error) {
+	var eo utils.ExecOptions
+
+	client.Logger.Debugf("Reading candidate of package %s", pkgName)
+
+	eo.Args = []string{"apt-cache", "policy", pkgName}
+	execResult, err := client.Exec(eo)
+	if err != nil {
 		return
 	}
 
-	installedVersion, candidateVersion := aptPkgParseAptCache(execResult.Stdout)
-	aptPkg.Name = pkgName
-	aptPkg.Version = installedVersion
-	aptPkg.LatestVersion = candidateVersion
+	_, candidate = aptPkgParseAptCache(execResult.Stdout)
+	if candidate == "(none)" {
+		candidate = ""
+	}
 
 	return
 }
@@ -71,7 +132,7 @@ func Read(client client.Client, pkgName string) (aptPkg AptPkg, err error) {
 func Exists(client client.Client, pkgName string) (exists bool, err error) {
 	client.Logger.Debugf("Checking if package %s exists", pkgName)
 
-	_, err = Read(client, pkgName)
+	_, err = aptPkgRead(client, pkgName)
 	if err != nil {
 		if _, ok := err.(resources.NotFoundError); ok {
 			err = nil
@@ -84,85 +145,201 @@ func Exists(client client.Client, pkgName string) (exists bool, err error) {
 	return
 }
 
-// List will retrieve all apt managed packages on a system.
+// List will retrieve all apt managed packages on a system, including
+// packages which are not fully installed, such as packages which only
+// left their config files.
 func List(client client.Client) (aptPkgs []AptPkg, err error) {
-	var eo utils.ExecOptions
-
 	client.Logger.Debugf("Listing all packages")
 
-	eo.Command = "dpkg -l"
-	execResult, err := utils.Exec(eo)
-	if err != nil {
+	return aptPkgReadStatus(client)
+}
+
+// Create will install a package via apt-get.
+func Create(client client.Client, createOpts CreateOpts) (err error) {
+	client.Logger.Debugf("Installing package")
+
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("Package Create Options: %#v", createOpts)
+
+	return aptPkgInstall(client, resources.ActionCreate, createOpts)
+}
+
+// Update will update a package via apt-get.
+func Update(client client.Client, pkgName string, updateOpts UpdateOpts) (err error) {
+	client.Logger.Debugf("Upgrading package")
+
+	if err = utils.BuildRequest(&updateOpts); err != nil {
 		return
 	}
 
-	pkgs := aptPkgParseDpkgL(execResult.Stdout)
-	for k, v := range pkgs {
-		pkg := AptPkg{
-			Name:    k,
-			Version: v,
+	client.Logger.Debugf("Package Update Options: %#v", updateOpts)
+
+	createOpts := CreateOpts{
+		Name:    pkgName,
+		Version: updateOpts.Version,
+		Preseed: updateOpts.Preseed,
+	}
+
+	return aptPkgInstall(client, resources.ActionUpdate, createOpts)
+}
+
+// aptPkgInstall is an internal function that will install a package
+// via apt-get. The action is what is being done to the package.
+func aptPkgInstall(client client.Client, action string, createOpts CreateOpts) (err error) {
+	if aptPkgIsConstraint(createOpts.Version) {
+		var versions map[string][]string
+		if versions, err = aptPkgVersions(client, []string{createOpts.Name}); err != nil {
+			return
 		}
-		aptPkgs = append(aptPkgs, pkg)
+
+		createOpts.Version, err = aptPkgChoose(createOpts.Name, versions[createOpts.Name], createOpts.Version)
+		if err != nil {
+			return
+		}
+	}
+
+	preseed, err := aptPkgPreseed(client, createOpts)
+	if err != nil {
+		return
+	}
+
+	eo := aptPkgInstallOpts([]string{aptPkgSpec(createOpts)})
+
+	if client.Pending(Type, createOpts.Name, action, preseed+eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+	if err != nil {
+		return
 	}
 
 	return
 }
 
-// Create will install a package via apt-get.
-func Create(client client.Client, createOpts CreateOpts) (err error) {
-	var eo utils.ExecOptions
+// aptPkgPreseed is an internal function that will set the debconf
+// selections of a package before it is installed. In dry-run mode the
+// selections are not set. Instead, the questions which would be answered
+// are returned, so that they are reported with the install of the package.
+func aptPkgPreseed(client client.Client, createOpts CreateOpts) (detail string, err error) {
+	if len(createOpts.Preseed) == 0 {
+		return
+	}
 
-	client.Logger.Debugf("Installing package")
+	selections, err := debconf.ParseSelections(strings.Join(createOpts.Preseed, "\n"))
+	if err != nil {
+		err = fmt.Errorf("Unable to preseed package %s: %s", createOpts.Name, err)
+		return
+	}
 
-	if err = utils.BuildRequest(&createOpts); err != nil {
+	var questions []string
+	for _, s := range selections {
+		questions = append(questions, s.Question)
+	}
+
+	if client.DryRun {
+		detail = fmt.Sprintf("debconf-set-selections for %s; ", strings.Join(questions, ", "))
 		return
 	}
 
-	client.Logger.Debugf("Package Create Options: %#v", createOpts)
+	err = debconf.SetSelections(client, selections)
+	if err != nil {
+		err = fmt.Errorf("Unable to preseed package %s: %s", createOpts.Name, err)
+		return
+	}
 
+	return
+}
+
+// aptPkgInstallOpts is an internal function that will return the options
+// to install packages via apt-get in a single transaction.
+func aptPkgInstallOpts(pkgs []string) (eo utils.ExecOptions) {
 	eo.Env = []string{
 		"DEBIAN_FRONTEND=noninteractive",
 		"APT_LISTBUGS_FRONTEND=none",
 		"APT_LISTCHANGES_FRONTEND=none",
-		"PATH=" + os.Getenv("PATH"),
 	}
 
-	var createArgs string
-	if createOpts.Version != "" && createOpts.Version != "latest" {
-		createArgs = fmt.Sprintf("%s=%s", createOpts.Name, createOpts.Version)
-	} else {
-		createArgs = createOpts.Name
+	eo.Args = []string{
+		"apt-get", "install", "-y", "--allow-downgrades", "--allow-remove-essential",
+		"-o", "DPkg::Options::=--force-confold",
+	}
+	eo.Args = append(eo.Args, pkgs...)
+
+	return
+}
+
+// aptPkgSpec is an internal function that will return the argument which
+// installs the desired version of a package via apt-get. A version
+// constraint must have been resolved to a version.
+func aptPkgSpec(createOpts CreateOpts) string {
+	switch createOpts.Version {
+	case "", "latest", "installed":
+		return createOpts.Name
 	}
 
-	eo.Command = fmt.Sprintf(
-		"apt-get install -y --allow-downgrades --allow-remove-essential "+
-			"--allow-change-held-packages -o DPkg::Options::=--force-confold %s",
-		createArgs)
+	return fmt.Sprintf("%s=%s", createOpts.Name, createOpts.Version)
+}
+
+// aptPkgIsConstraint is an internal function that will report if a
+// desired version is a constraint, such as ">= 2.4", rather than a
+// version. A version always starts with a digit.
+func aptPkgIsConstraint(version string) bool {
+	return strings.IndexAny(version, "<>=!~") == 0 || strings.Contains(version, ",")
+}
+
+// aptPkgVersions is an internal function that will read the versions of
+// packages which are available or installed with a single apt-cache
+// policy.
+func aptPkgVersions(client client.Client, names []string) (versions map[string][]string, err error) {
+	var eo utils.ExecOptions
+
+	versions = make(map[string][]string)
+	if len(names) == 0 {
+		return
+	}
 
-	_, err = utils.Exec(eo)
+	eo.Args = append([]string{"apt-cache", "policy"}, names...)
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
 
+	for name, policy := range aptPkgSplitPolicy(execResult.Stdout) {
+		versions[name] = aptPkgParseVersionTable(policy)
+	}
+
 	return
 }
 
-// Update will update a package via apt-get.
-func Update(client client.Client, pkgName string, updateOpts UpdateOpts) (err error) {
-	client.Logger.Debugf("Upgrading package")
-
-	if err = utils.BuildRequest(&updateOpts); err != nil {
+// aptPkgChoose is an internal function that will return the highest of
+// the versions of a package which satisfies a constraint.
+func aptPkgChoose(name string, versions []string, constraint string) (version string, err error) {
+	constraints, err := ParseConstraints(constraint)
+	if err != nil {
 		return
 	}
 
-	client.Logger.Debugf("Package Update Options: %#v", updateOpts)
+	var highest Version
+	for _, s := range versions {
+		v, err := ParseVersion(s)
+		if err != nil || !Satisfies(s, constraints) {
+			continue
+		}
 
-	createOpts := CreateOpts{
-		Name:    pkgName,
-		Version: updateOpts.Version,
+		if version == "" || v.Compare(highest) > 0 {
+			version, highest = s, v
+		}
 	}
 
-	return Create(client, createOpts)
+	if version == "" {
+		err = fmt.Errorf("No version of package %s satisfies %s", name, constraint)
+	}
+
+	return
 }
 
 // Delete will uninstall a package via apt-get.
@@ -175,11 +352,14 @@ func Delete(client client.Client, pkgName string) (err error) {
 		"DEBIAN_FRONTEND=noninteractive",
 		"APT_LISTBUGS_FRONTEND=none",
 		"APT_LISTCHANGES_FRONTEND=none",
-		"PATH=" + os.Getenv("PATH"),
 	}
 
-	eo.Command = fmt.Sprintf("apt-get purge -q -y %s", pkgName)
-	_, err = utils.Exec(eo)
+	eo.Args = []string{"apt-get", "purge", "-q", "-y", pkgName}
+	if client.Pending(Type, pkgName, resources.ActionDelete, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -187,6 +367,272 @@ func Delete(client client.Client, pkgName string) (err error) {
 	return
 }
 
+// Result represents the outcome of a package which was applied in a
+// batch.
+type Result struct {
+	// Change describes what was done to the package.
+	Change resources.Change
+
+	// Err is the error which occurred when applying the package.
+	Err error
+}
+
+// CreateMany will install packages via apt-get in a single transaction.
+// Only the packages which are missing or not at their desired version
+// are installed.
+//
+// A result is returned for every package in the order they were given.
+// The error is the first error of any package.
+func CreateMany(client client.Client, createOpts []CreateOpts) (results []Result, err error) {
+	client.Logger.Debugf("Installing %d packages", len(createOpts))
+
+	var rs []Resource
+	for _, co := range createOpts {
+		rs = append(rs, Resource{CreateOpts: co})
+	}
+
+	return ApplyMany(client, rs)
+}
+
+// ApplyMany will converge packages to their desired state like Apply,
+// but reads the state of every package with a single read of the dpkg
+// database and installs the packages which should be present in a single apt-get
+// transaction. Packages which should be absent are applied one at a time.
+//
+// If apt-get fails, the packages which caused it, such as a package with
+// unmet dependencies, report the error of apt-get. The other packages
+// which were not installed report the packages which caused it.
+//
+// A result is returned for every package in the order they were given.
+// The error is the first error of any package.
+func ApplyMany(client client.Client, rs []Resource) (results []Result, err error) {
+	results = make([]Result, len(rs))
+
+	var index []int
+	for i, r := range rs {
+		results[i].Change = resources.Change{Type: Type, Name: r.Name}
+
+		switch r.Ensure {
+		case "", resources.Present:
+			if results[i].Err = utils.BuildRequest(&r.CreateOpts); results[i].Err == nil {
+				index = append(index, i)
+			}
+		default:
+			results[i].Change, results[i].Err = Apply(client, r)
+		}
+	}
+
+	if len(index) > 0 {
+		aptPkgInstallMany(client, rs, index, results)
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			err = result.Err
+			break
+		}
+	}
+
+	return
+}
+
+// aptPkgInstallMany is an internal function that will install the
+// resources at the given indexes in a single apt-get transaction and set
+// their results.
+func aptPkgInstallMany(client client.Client, rs []Resource, index []int, results []Result) {
+	var names, latest []string
+	for _, i := range index {
+		names = append(names, rs[i].Name)
+		if rs[i].Version == "latest" {
+			latest = append(latest, rs[i].Name)
+		}
+	}
+
+	installed, err := aptPkgQuery(client, names)
+	var candidates map[string]string
+	if err == nil {
+		candidates, err = aptPkgCandidates(client, latest)
+	}
+
+	if err != nil {
+		for _, i := range index {
+			results[i].Err = err
+		}
+		return
+	}
+
+	var pending []int
+	for _, i := range index {
+		r := rs[i]
+
+		if current, ok := aptPkgCurrent(r.Name, installed, candidates); !ok {
+			results[i].Change.Action = resources.ActionCreate
+			results[i].Change.Diffs = r.Diff(nil)
+		} else if diffs := r.Diff(current); len(diffs) > 0 {
+			results[i].Change.Action = resources.ActionUpdate
+			results[i].Change.Diffs = diffs
+		} else {
+			continue
+		}
+
+		pending = append(pending, i)
+	}
+
+	// The versions which satisfy constraints are read in a single pass.
+	var constrained []string
+	for _, i := range pending {
+		if aptPkgIsConstraint(rs[i].Version) {
+			constrained = append(constrained, rs[i].Name)
+		}
+	}
+
+	versions, err := aptPkgVersions(client, constrained)
+
+	var resolved []int
+	var pkgs []string
+	preseeds := make(map[int]string)
+	for _, i := range pending {
+		createOpts := rs[i].CreateOpts
+
+		var chooseErr error
+		if err != nil {
+			chooseErr = err
+		} else if aptPkgIsConstraint(createOpts.Version) {
+			createOpts.Version, chooseErr = aptPkgChoose(createOpts.Name, versions[createOpts.Name], createOpts.Version)
+		}
+
+		if chooseErr == nil {
+			preseeds[i], chooseErr = aptPkgPreseed(client, createOpts)
+		}
+
+		if chooseErr != nil {
+			results[i].Change.Action = ""
+			results[i].Change.Diffs = nil
+			results[i].Err = chooseErr
+			continue
+		}
+
+		resolved = append(resolved, i)
+		pkgs = append(pkgs, aptPkgSpec(createOpts))
+	}
+
+	pending = resolved
+	if len(pending) == 0 {
+		return
+	}
+
+	eo := aptPkgInstallOpts(pkgs)
+
+	var dryRun bool
+	for _, i := range pending {
+		if client.Pending(Type, rs[i].Name, results[i].Change.Action, preseeds[i]+eo.String()) {
+			dryRun = true
+		}
+	}
+
+	if dryRun {
+		return
+	}
+
+	execResult, err := client.Exec(eo)
+	if err == nil {
+		return
+	}
+
+	failed := aptPkgParseFailures(execResult.Stdout + "\n" + execResult.Stderr)
+
+	// dpkg may have installed some of the packages before it failed.
+	installed, _ = aptPkgQuery(client, names)
+
+	for _, i := range pending {
+		r := rs[i]
+
+		if current, ok := aptPkgCurrent(r.Name, installed, candidates); ok && len(r.Diff(current)) == 0 {
+			continue
+		}
+
+		results[i].Change.Action = ""
+		results[i].Change.Diffs = nil
+
+		if len(failed) == 0 || aptPkgFailed(r.Name, failed) {
+			results[i].Err = fmt.Errorf("Unable to install package %s: %s", r.Name, err)
+		} else {
+			results[i].Err = fmt.Errorf("Package %s was not installed since apt-get failed on %s",
+				r.Name, strings.Join(failed, ", "))
+		}
+	}
+}
+
+// aptPkgQuery is an internal function that will read the installed
+// packages from a single read of the dpkg database.
+func aptPkgQuery(client client.Client, names []string) (installed map[string]AptPkg, err error) {
+	pkgs, err := aptPkgReadStatus(client)
+	if err != nil {
+		return
+	}
+
+	installed = make(map[string]AptPkg)
+	for _, name := range names {
+		if pkg, ok := aptPkgFind(pkgs, name); ok && aptPkgInstalled(pkg) {
+			installed[name] = pkg
+		}
+	}
+
+	return
+}
+
+// aptPkgCandidates is an internal function that will read the candidate
+// versions of packages with a single apt-cache policy.
+func aptPkgCandidates(client client.Client, names []string) (candidates map[string]string, err error) {
+	var eo utils.ExecOptions
+
+	candidates = make(map[string]string)
+	if len(names) == 0 {
+		return
+	}
+
+	eo.Args = append([]string{"apt-cache", "policy"}, names...)
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	for name, policy := range aptPkgSplitPolicy(execResult.Stdout) {
+		if _, candidate := aptPkgParseAptCache(policy); candidate != "(none)" {
+			candidates[name] = candidate
+		}
+	}
+
+	return
+}
+
+// aptPkgCurrent is an internal function that will return the current
+// state of a package from its installed package and candidate version.
+func aptPkgCurrent(name string, installed map[string]AptPkg, candidates map[string]string) (pkg AptPkg, ok bool) {
+	pkg, ok = installed[name]
+	if !ok {
+		return
+	}
+
+	pkg.Name = name
+	pkg.LatestVersion = candidates[name]
+
+	return
+}
+
+// aptPkgFailed is an internal function that will report if a package is
+// one of the packages which apt-get failed on.
+func aptPkgFailed(name string, failed []string) bool {
+	name = strings.SplitN(name, ":", 2)[0]
+	for _, f := range failed {
+		if f == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 // apkgPkgParseAptCache is an internal function that will parse the
 // output of apt-cache policy and return the version information.
 func aptPkgParseAptCache(stdout string) (installed, candidate string) {
@@ -204,16 +650,339 @@ func aptPkgParseAptCache(stdout string) (installed, candidate string) {
 	return
 }
 
-// aptPkgParseDpkgL is an internal function that will parse the output
-// of dpkg -l and return a list of packages and their versions.
-func aptPkgParseDpkgL(stdout string) (pkgs map[string]string) {
-	pkgs = make(map[string]string)
-	pkgRe := regexp.MustCompile("^ii\\s+(\\S+)\\s+(\\S+)")
-	for _, pkg := range strings.Split(stdout, "\n") {
-		if v := pkgRe.FindStringSubmatch(pkg); v != nil {
-			pkgs[v[1]] = v[2]
+// aptPkgQueryFormat is the format used to list packages with dpkg-query
+// if the dpkg database cannot be read directly.
+const aptPkgQueryFormat = "${Package}\t${Version}\t${Architecture}\t${source:Package}\t${Status}\n"
+
+// aptPkgRead is an internal function that will read an installed package
+// from the dpkg database without its candidate version.
+func aptPkgRead(client client.Client, pkgName string) (aptPkg AptPkg, err error) {
+	pkgs, err := aptPkgReadStatus(client)
+	if err != nil {
+		return
+	}
+
+	aptPkg, ok := aptPkgFind(pkgs, pkgName)
+	if !ok || !aptPkgInstalled(aptPkg) {
+		err = resources.NotFoundError{Type: Type, Name: pkgName}
+		return
+	}
+
+	aptPkg.Name = pkgName
+
+	return
+}
+
+// aptPkgReadStatus is an internal function that will read every package
+// in the dpkg database. If the database cannot be read, such as when
+// dpkg uses another admin directory, the packages are listed with
+// dpkg-query.
+func aptPkgReadStatus(client client.Client) (pkgs []AptPkg, err error) {
+	var eo utils.ExecOptions
+
+	content, err := client.System().ReadFile(StatusFile)
+	if err == nil {
+		pkgs = aptPkgParseStatus(string(content))
+		return
+	}
+
+	client.Logger.Debugf("Unable to read %s, using dpkg-query: %s", StatusFile, err)
+
+	eo.Args = []string{"dpkg-query", "-W", "-f", aptPkgQueryFormat}
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	pkgs = aptPkgParseDpkgQuery(execResult.Stdout)
+
+	return
+}
+
+// aptPkgFind is an internal function that will find a package by its
+// name, which may be qualified with an architecture, such as libc6:i386.
+// If a package is known for several architectures, an installed one is
+// preferred.
+func aptPkgFind(pkgs []AptPkg, name string) (pkg AptPkg, found bool) {
+	pkgName, arch := name, ""
+	if i := strings.Index(name, ":"); i >= 0 {
+		pkgName, arch = name[:i], name[i+1:]
+	}
+
+	for _, p := range pkgs {
+		if p.Name != pkgName || (arch != "" && p.Architecture != arch) {
+			continue
+		}
+
+		if !found || (!aptPkgInstalled(pkg) && aptPkgInstalled(p)) {
+			pkg, found = p, true
 		}
 	}
 
 	return
 }
+
+// aptPkgInstalled is an internal function that will report if a package
+// is installed. A package which waits for triggers is installed since
+// its files are in place and configured.
+func aptPkgInstalled(pkg AptPkg) bool {
+	switch pkg.Status {
+	case StatusInstalled, StatusTriggersAwaited, StatusTriggersPending:
+		return true
+	}
+
+	return false
+}
+
+// aptPkgParseStatus is an internal function that will parse the dpkg
+// database and return the packages in it. Each package is a paragraph of
+// fields, such as "Package: sl", separated by a blank line. Fields which
+// span several lines, such as Description, are ignored.
+func aptPkgParseStatus(content string) (pkgs []AptPkg) {
+	content = strings.Replace(content, "\r\n", "\n", -1)
+
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		fields := make(map[string]string)
+		for _, line := range strings.Split(paragraph, "\n") {
+			if line == "" || line[0] == ' ' || line[0] == '\t' {
+				continue
+			}
+
+			if i := strings.Index(line, ":"); i > 0 {
+				fields[line[:i]] = strings.TrimSpace(line[i+1:])
+			}
+		}
+
+		if fields["Package"] == "" {
+			continue
+		}
+
+		pkgs = append(pkgs, aptPkgNew(fields["Package"], fields["Version"],
+			fields["Architecture"], fields["Source"], fields["Status"]))
+	}
+
+	return
+}
+
+// aptPkgParseDpkgQuery is an internal function that will parse the
+// output of dpkg-query -W with aptPkgQueryFormat and return the packages
+// in it.
+func aptPkgParseDpkgQuery(stdout string) (pkgs []AptPkg) {
+	for _, line := range strings.Split(stdout, "\n") {
+		v := strings.Split(line, "\t")
+		if len(v) != 5 || v[0] == "" {
+			continue
+		}
+
+		pkgs = append(pkgs, aptPkgNew(v[0], v[1], v[2], v[3], v[4]))
+	}
+
+	return
+}
+
+// aptPkgNew is an internal function that will build a package from the
+// fields of the dpkg database. The source may be followed by its version,
+// such as "glibc (2.35-0ubuntu3)", and is the name of the package if it
+// is empty. The status is the wanted state, the error flag, and the state
+// of the package, such as "hold ok installed".
+func aptPkgNew(name, version, arch, source, status string) (pkg AptPkg) {
+	pkg.Name = name
+	pkg.Version = version
+	pkg.Architecture = arch
+
+	pkg.Source = name
+	if v := strings.Fields(source); len(v) > 0 {
+		pkg.Source = v[0]
+	}
+
+	if v := strings.Fields(status); len(v) == 3 {
+		pkg.Hold = v[0] == "hold"
+		pkg.Status = v[2]
+	}
+
+	return
+}
+
+// aptPkgSplitPolicy is an internal function that will split the output
+// of apt-cache policy for several packages into the output for each
+// package.
+func aptPkgSplitPolicy(stdout string) (policies map[string]string) {
+	policies = make(map[string]string)
+
+	var name string
+	for _, line := range strings.Split(stdout, "\n") {
+		if line != "" && line[0] != ' ' && line[0] != '\t' && strings.HasSuffix(line, ":") {
+			name = strings.TrimSuffix(line, ":")
+			continue
+		}
+
+		if name != "" {
+			policies[name] += line + "\n"
+		}
+	}
+
+	return
+}
+
+// aptPkgVersionRe matches a version in the version table of apt-cache
+// policy, such as " *** 5.02-1 500", as opposed to the sources of the
+// version, such as "        500 http://archive.ubuntu.com/ubuntu ...".
+var aptPkgVersionRe = regexp.MustCompile(`^\s+(?:\*\*\* )?(\S+) -?\d+$`)
+
+// aptPkgParseVersionTable is an internal function that will parse the
+// output of apt-cache policy for a package and return the versions in
+// its version table.
+func aptPkgParseVersionTable(stdout string) (versions []string) {
+	var table bool
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.TrimSpace(line) == "Version table:" {
+			table = true
+			continue
+		}
+
+		if !table {
+			continue
+		}
+
+		if v := aptPkgVersionRe.FindStringSubmatch(line); v != nil {
+			versions = append(versions, v[1])
+		}
+	}
+
+	return
+}
+
+// aptPkgFailureRes match the messages of apt-get and dpkg which name a
+// package that caused apt-get to fail.
+var aptPkgFailureRes = []*regexp.Regexp{
+	regexp.MustCompile(`E: Unable to locate package (\S+)`),
+	regexp.MustCompile(`E: Version '[^']*' for '([^']+)' was not found`),
+	regexp.MustCompile(`E: Package '([^']+)' has no installation candidate`),
+	regexp.MustCompile(`(?m)^ +(\S+) : (?:Depends|PreDepends|Breaks|Conflicts):`),
+	regexp.MustCompile(`dpkg: error processing package (\S+) \(`),
+	regexp.MustCompile(`dpkg: error processing archive \S*/([^/_\s]+)_\S* \(`),
+}
+
+// aptPkgParseFailures is an internal function that will parse the output
+// of a failed apt-get and return the packages which caused it to fail.
+func aptPkgParseFailures(output string) (pkgs []string) {
+	seen := make(map[string]bool)
+	for _, re := range aptPkgFailureRes {
+		for _, v := range re.FindAllStringSubmatch(output, -1) {
+			name := strings.SplitN(v[1], ":", 2)[0]
+			if !seen[name] {
+				seen[name] = true
+				pkgs = append(pkgs, name)
+			}
+		}
+	}
+
+	return
+}
+
+// Resource represents the desired state of an apt package.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge a package to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the package.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the package. The candidate version is only read if the
+// latest version is desired.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	if r.Version != "latest" {
+		return aptPkgRead(client, r.Name)
+	}
+
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the package is installed.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff will compare an installed package to the desired version. A
+// package which is held is left at its version.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	pkg, _ := current.(AptPkg)
+
+	if pkg.Hold {
+		return
+	}
+
+	switch r.Version {
+	case "", "installed":
+	case "latest":
+		if pkg.LatestVersion != "" && pkg.Version != pkg.LatestVersion {
+			diffs = append(diffs, resources.Diff{Field: "Version", Old: pkg.Version, New: pkg.LatestVersion})
+		}
+	default:
+		// An invalid constraint is reported when the package is updated.
+		constraints, err := ParseConstraints(r.Version)
+		if err != nil || !Satisfies(pkg.Version, constraints) {
+			diffs = append(diffs, resources.Diff{Field: "Version", Old: pkg.Version, New: r.Version})
+		}
+	}
+
+	return
+}
+
+// Create will install the package.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will update the package to the desired version.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		Version: r.Version,
+		Preseed: r.Preseed,
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will uninstall the package.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// AutoRequires returns every apt key, source, and PPA since a package
+// may be installed from any of them, every apt pin since pins choose
+// the version which is installed, and every debconf question since
+// questions are answered when the package is configured.
+func (r Resource) AutoRequires() []resources.Ref {
+	return []resources.Ref{
+		{Type: aptkey.Type, ID: "*"},
+		{Type: aptpin.Type, ID: "*"},
+		{Type: debconf.Type, ID: "*"},
+		{Type: aptsource.Type, ID: "*"},
+		{Type: aptppa.Type, ID: "*"},
+	}
+}
+
+// LockName returns the apt lock since dpkg can only install one
+// package at a time.
+func (r Resource) LockName() string {
+	return "apt"
+}
@@ -1,10 +1,18 @@
 package aptpkg
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
 	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,6 +32,461 @@ func Test_aptPkgParseAptCache(t *testing.T) {
 	assert.Equal(t, candidate, "3.03-17build1", "should be equal")
 }
 
+const testAptCachePolicy = `curl:
+  Installed: 7.81.0-1ubuntu1.15
+  Candidate: 7.81.0-1ubuntu1.16
+  Version table:
+     7.81.0-1ubuntu1.16 500
+        500 http://archive.ubuntu.com/ubuntu jammy-updates/main amd64 Packages
+ *** 7.81.0-1ubuntu1.15 100
+        100 /var/lib/dpkg/status
+sl:
+  Installed: (none)
+  Candidate: 5.02-1
+  Version table:
+     5.02-1 500
+        500 http://archive.ubuntu.com/ubuntu jammy/universe amd64 Packages
+`
+
+const testAptGetUnmet = `Reading package lists...
+Building dependency tree...
+Reading state information...
+Some packages could not be installed. This may mean that you have
+requested an impossible situation or if you are using the unstable
+distribution that some required packages have not yet been created
+or been moved out of Incoming.
+The following information may help to resolve the situation:
+
+The following packages have unmet dependencies:
+ app : Depends: libfoo (>= 2.0) but 1.0-1 is to be installed
+       Depends: libbar but it is not installable
+E: Unable to correct problems, you have held broken packages.
+`
+
+const testDpkgError = `Setting up nginx-common (1.18.0-6ubuntu14.4) ...
+Job for nginx.service failed because the control process exited with error code.
+invoke-rc.d: initscript nginx, action "start" failed.
+dpkg: error processing package nginx-core (--configure):
+ installed nginx-core package post-installation script subprocess returned error exit status 1
+dpkg: dependency problems prevent configuration of nginx:amd64:
+ nginx depends on nginx-core (<< 1.18.0-6ubuntu14.4.1~); however:
+  Package nginx-core is not configured yet.
+
+dpkg: error processing package nginx:amd64 (--configure):
+ dependency problems - leaving unconfigured
+Errors were encountered while processing:
+ nginx-core
+ nginx
+E: Sub-process /usr/bin/dpkg returned an error code (1)
+`
+
+// testStatus returns the packages in the dpkg database fixture.
+func testStatus() []AptPkg {
+	return []AptPkg{
+		{Name: "curl", Version: "7.81.0-1ubuntu1.15", Status: "installed", Architecture: "amd64", Source: "curl"},
+		{Name: "libc6", Version: "2.35-0ubuntu3.6", Status: "installed", Architecture: "amd64", Source: "glibc"},
+		{Name: "libc6", Version: "2.35-0ubuntu3.5", Status: "installed", Architecture: "i386", Source: "glibc"},
+		{Name: "nginx", Version: "1.18.0-6ubuntu14.4", Status: "config-files", Architecture: "amd64", Source: "nginx"},
+		{Name: "openssl", Version: "3.0.2-0ubuntu1.12", Status: "installed", Architecture: "amd64", Source: "openssl", Hold: true},
+		{Name: "python3-yaml", Version: "5.4.1-1ubuntu1", Status: "half-installed", Architecture: "amd64", Source: "pyyaml"},
+		{Name: "sl", Version: "5.02-1", Status: "installed", Architecture: "amd64", Source: "sl"},
+		{Name: "man-db", Version: "2.10.2-1", Status: "triggers-pending", Architecture: "amd64", Source: "man-db"},
+	}
+}
+
+// testFixture returns the content of a file in test-fixtures.
+func testFixture(t *testing.T, name string) string {
+	content, err := ioutil.ReadFile(filepath.Join("test-fixtures", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func Test_aptPkgParseStatus(t *testing.T) {
+	content := testFixture(t, "status")
+	assert.Equal(t, testStatus(), aptPkgParseStatus(content), "should be equal")
+
+	content = strings.Replace(content, "\n", "\r\n", -1)
+	assert.Equal(t, testStatus(), aptPkgParseStatus(content), "should be equal")
+
+	assert.Nil(t, aptPkgParseStatus(""))
+}
+
+func Test_aptPkgParseDpkgQuery(t *testing.T) {
+	content := testFixture(t, "dpkg-query")
+	assert.Equal(t, testStatus(), aptPkgParseDpkgQuery(content), "should be equal")
+}
+
+func Test_aptPkgFind(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		found     bool
+		installed bool
+	}{
+		{"sl", "5.02-1", true, true},
+		{"libc6", "2.35-0ubuntu3.6", true, true},
+		{"libc6:i386", "2.35-0ubuntu3.5", true, true},
+		{"libc6:arm64", "", false, false},
+		{"nginx", "1.18.0-6ubuntu14.4", true, false},
+		{"python3-yaml", "5.4.1-1ubuntu1", true, false},
+		{"man-db", "2.10.2-1", true, true},
+		{"tree", "", false, false},
+	}
+
+	for _, test := range tests {
+		pkg, found := aptPkgFind(testStatus(), test.name)
+		assert.Equal(t, test.found, found, test.name)
+		assert.Equal(t, test.version, pkg.Version, test.name)
+		assert.Equal(t, test.installed, aptPkgInstalled(pkg), test.name)
+	}
+}
+
+func Test_AptPkg_Read(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile(StatusFile, testFixture(t, "status"), 0644)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch eo.Args[0] {
+		case "apt-cache":
+			er.Stdout = aptPkgSplitPolicy(testAptCachePolicy)[eo.Args[2]]
+		case "dpkg-query":
+			er.Stdout = testFixture(t, "dpkg-query")
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	pkg, err := Read(c, "openssl")
+	assert.Nil(t, err)
+	assert.Equal(t, true, pkg.Hold, "should be equal")
+	assert.Equal(t, "", pkg.LatestVersion, "should be equal")
+
+	pkg, err = Read(c, "curl")
+	assert.Nil(t, err)
+	assert.Equal(t, "7.81.0-1ubuntu1.16", pkg.LatestVersion, "should be equal")
+
+	_, err = Read(c, "nginx")
+	assert.Equal(t, "AptPkg nginx not found", err.Error(), "should be equal")
+
+	// Only the latest version needs the candidate.
+	fake.Commands = nil
+	r := Resource{CreateOpts: CreateOpts{Name: "sl", Version: "5.02-1"}}
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+	assert.Equal(t, 0, len(fake.Commands), "should be equal")
+
+	// dpkg-query is used if the database cannot be read.
+	delete(fake.Files, StatusFile)
+	pkgs, err := List(c)
+	assert.Nil(t, err)
+	assert.Equal(t, testStatus(), pkgs, "should be equal")
+	assert.Contains(t, fake.Commands[0], "dpkg-query -W -f")
+}
+
+func Test_AptPkg_Hold(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile(StatusFile, testFixture(t, "status"), 0644)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		if eo.Args[0] == "apt-cache" {
+			er.Stdout = "openssl:\n  Installed: 3.0.2-0ubuntu1.12\n  Candidate: 3.0.2-0ubuntu1.15\n"
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	// openssl is held, so it is left at its version.
+	for _, version := range []string{"latest", "3.0.2-0ubuntu1.15", ">= 3.0.2-0ubuntu1.15"} {
+		r := Resource{CreateOpts: CreateOpts{Name: "openssl", Version: version}}
+
+		change, err := Apply(c, r)
+		assert.Nil(t, err)
+		assert.Equal(t, false, change.Changed(), version)
+
+		results, err := ApplyMany(c, []Resource{r})
+		assert.Nil(t, err)
+		assert.Equal(t, false, results[0].Change.Changed(), version)
+	}
+
+	for _, command := range fake.Commands {
+		assert.False(t, strings.HasPrefix(command, "apt-get install"), command)
+	}
+
+	eo := aptPkgInstallOpts([]string{"sl"})
+	assert.NotContains(t, eo.Args, "--allow-change-held-packages")
+}
+
+func Test_aptPkgSplitPolicy(t *testing.T) {
+	policies := aptPkgSplitPolicy(testAptCachePolicy)
+	assert.Equal(t, 2, len(policies), "should be equal")
+
+	installed, candidate := aptPkgParseAptCache(policies["curl"])
+	assert.Equal(t, "7.81.0-1ubuntu1.15", installed, "should be equal")
+	assert.Equal(t, "7.81.0-1ubuntu1.16", candidate, "should be equal")
+
+	installed, candidate = aptPkgParseAptCache(policies["sl"])
+	assert.Equal(t, "(none)", installed, "should be equal")
+	assert.Equal(t, "5.02-1", candidate, "should be equal")
+}
+
+const testAptCachePolicyTable = `nginx:
+  Installed: (none)
+  Candidate: 1.24.0-2~jammy
+  Version table:
+     1.24.0-2~jammy 500
+        500 https://nginx.org/packages/ubuntu jammy/nginx amd64 Packages
+     1.22.1-1~jammy 500
+        500 https://nginx.org/packages/ubuntu jammy/nginx amd64 Packages
+ *** 1.18.0-6ubuntu14.4 100
+        100 /var/lib/dpkg/status
+     1.18.0-6ubuntu14 500
+        500 http://archive.ubuntu.com/ubuntu jammy/main amd64 Packages
+`
+
+func Test_aptPkgParseVersionTable(t *testing.T) {
+	expected := []string{"1.24.0-2~jammy", "1.22.1-1~jammy", "1.18.0-6ubuntu14.4", "1.18.0-6ubuntu14"}
+	assert.Equal(t, expected, aptPkgParseVersionTable(testAptCachePolicyTable), "should be equal")
+}
+
+func Test_aptPkgChoose(t *testing.T) {
+	versions := aptPkgParseVersionTable(testAptCachePolicyTable)
+
+	tests := []struct {
+		constraint string
+		version    string
+	}{
+		{">= 1.18", "1.24.0-2~jammy"},
+		{"~> 1.18.0", "1.18.0-6ubuntu14.4"},
+		{">= 1.20, << 1.24", "1.22.1-1~jammy"},
+		{"<< 1.18.0-6ubuntu14.4", "1.18.0-6ubuntu14"},
+	}
+
+	for _, test := range tests {
+		version, err := aptPkgChoose("nginx", versions, test.constraint)
+		assert.Nil(t, err)
+		assert.Equal(t, test.version, version, test.constraint)
+	}
+
+	_, err := aptPkgChoose("nginx", versions, ">= 2")
+	assert.Equal(t, "No version of package nginx satisfies >= 2", err.Error(), "should be equal")
+}
+
+func Test_AptPkg_Constraint(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile(StatusFile, testFixture(t, "status"), 0644)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		if eo.Args[0] == "apt-cache" {
+			er.Stdout = testAptCachePolicyTable
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	// nginx only left its config files.
+	r := Resource{CreateOpts: CreateOpts{Name: "nginx", Version: "~> 1.22.0"}}
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "create", change.Action, "should be equal")
+	assert.Contains(t, fake.Commands[len(fake.Commands)-1], "nginx=1.22.1-1~jammy")
+
+	// curl 7.81.0-1ubuntu1.15 is installed.
+	fake.Commands = nil
+	r = Resource{CreateOpts: CreateOpts{Name: "curl", Version: ">= 7.81, << 8"}}
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+	assert.Equal(t, 0, len(fake.Commands), "should be equal")
+
+	r.Version = "installed"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Version = ">= a"
+	_, err = Apply(c, r)
+	assert.Contains(t, err.Error(), "Invalid constraint")
+
+	results, err := ApplyMany(c, []Resource{
+		{CreateOpts: CreateOpts{Name: "nginx", Version: ">= 1.20"}},
+		{CreateOpts: CreateOpts{Name: "tree", Version: ">= 3"}},
+	})
+	assert.Equal(t, "create", results[0].Change.Action, "should be equal")
+	assert.Equal(t, "No version of package tree satisfies >= 3", err.Error(), "should be equal")
+	assert.Contains(t, fake.Commands[len(fake.Commands)-1], "nginx=1.24.0-2~jammy")
+}
+
+func Test_aptPkgParseFailures(t *testing.T) {
+	tests := []struct {
+		output string
+		pkgs   []string
+	}{
+		{"E: Unable to locate package nope\n", []string{"nope"}},
+		{"E: Version '9.9' for 'sl' was not found\n", []string{"sl"}},
+		{"E: Package 'python' has no installation candidate\n", []string{"python"}},
+		{testAptGetUnmet, []string{"app"}},
+		{testDpkgError, []string{"nginx-core", "nginx"}},
+		{"E: Could not get lock /var/lib/dpkg/lock-frontend\n", nil},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.pkgs, aptPkgParseFailures(test.output), "should be equal")
+	}
+}
+
+func Test_AptPkg_ApplyMany(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile(StatusFile, testFixture(t, "status"), 0644)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch eo.Args[0] {
+		case "apt-cache":
+			er.Stdout = testAptCachePolicy
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	rs := []Resource{
+		{CreateOpts: CreateOpts{Name: "curl", Version: "latest"}},
+		{CreateOpts: CreateOpts{Name: "sl"}},
+		{CreateOpts: CreateOpts{Name: "app", Version: "1.0"}},
+		{CreateOpts: CreateOpts{Name: "libc6"}},
+		{CreateOpts: CreateOpts{Name: "nginx"}},
+	}
+
+	results, err := ApplyMany(c, rs)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(results), "should be equal")
+
+	var actions []string
+	for _, result := range results {
+		assert.Nil(t, result.Err)
+		actions = append(actions, result.Change.Action)
+	}
+
+	assert.Equal(t, []string{"update", "", "create", "", "create"}, actions, "should be equal")
+	assert.Equal(t, []resources.Diff{{Field: "Version", Old: "7.81.0-1ubuntu1.15", New: "7.81.0-1ubuntu1.16"}}, results[0].Change.Diffs, "should be equal")
+
+	var installs []string
+	for _, command := range fake.Commands {
+		if strings.HasPrefix(command, "apt-get install") {
+			installs = append(installs, command)
+		}
+	}
+
+	assert.Equal(t, 1, len(installs), "should be equal")
+	assert.True(t, strings.HasSuffix(installs[0], " curl app=1.0 nginx"), installs[0])
+}
+
+func Test_AptPkg_ApplyMany_Failure(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile(StatusFile, testFixture(t, "status"), 0644)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch eo.Args[0] {
+		case "apt-get":
+			er.Stdout = testAptGetUnmet
+			er.ExitStatus = 100
+			err = fmt.Errorf("apt-get install: exit status 100")
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	createOpts := []CreateOpts{
+		{Name: "app"},
+		{Name: "sl"},
+		{Name: "tree"},
+	}
+
+	results, err := CreateMany(c, createOpts)
+	assert.Equal(t, "Unable to install package app: apt-get install: exit status 100", err.Error(), "should be equal")
+	assert.Equal(t, err, results[0].Err, "should be equal")
+	assert.Nil(t, results[1].Err)
+	assert.Equal(t, "Package tree was not installed since apt-get failed on app", results[2].Err.Error(), "should be equal")
+	assert.Equal(t, "", results[2].Change.Action, "should be equal")
+
+	results, err = CreateMany(c, []CreateOpts{{Name: "app"}, {}})
+	assert.NotNil(t, err)
+	assert.Equal(t, utils.MissingInputError{Field: "Name"}, results[1].Err, "should be equal")
+}
+
+func Test_AptPkg_Preseed(t *testing.T) {
+	var preseeded []string
+
+	fake := executor.NewFake()
+	fake.AddFile(StatusFile, testFixture(t, "status"), 0644)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		if eo.Args[0] == "debconf-set-selections" {
+			b, _ := ioutil.ReadAll(eo.Stdin)
+			preseeded = append(preseeded, string(b))
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name: "postfix",
+			Preseed: []string{
+				"postfix postfix/main_mailer_type select Internet Site",
+				"postfix postfix/mailname string mail.example.com",
+			},
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "create", change.Action, "should be equal")
+	assert.Equal(t, []string{"postfix postfix/main_mailer_type select Internet Site\npostfix postfix/mailname string mail.example.com\n"}, preseeded, "should be equal")
+
+	n := len(fake.Commands)
+	assert.Equal(t, "debconf-set-selections", fake.Commands[n-2], "should be equal")
+	assert.Contains(t, fake.Commands[n-1], "apt-get install")
+
+	// Preseeding happens before the packages of a batch are installed.
+	preseeded = nil
+	results, err := ApplyMany(c, []Resource{r, {CreateOpts: CreateOpts{Name: "tree", Preseed: []string{"tree tree/q choice a"}}}})
+	assert.Nil(t, results[0].Err)
+	assert.Contains(t, err.Error(), "Unable to preseed package tree")
+	assert.Equal(t, 1, len(preseeded), "should be equal")
+	assert.True(t, strings.HasSuffix(fake.Commands[len(fake.Commands)-1], " postfix"), fake.Commands[len(fake.Commands)-1])
+
+	// In dry-run mode, the questions are reported with the install.
+	fake.Commands = nil
+	preseeded = nil
+	c.DryRun = true
+	c.Plan = &client.Plan{}
+	r.Preseed = []string{"postfix postfix/mailname string mail.example.com"}
+	_, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Nil(t, preseeded)
+	assert.Equal(t, 1, len(c.Plan.Steps()), "should be equal")
+	assert.True(t, strings.HasPrefix(c.Plan.Steps()[0].Detail, "debconf-set-selections for postfix/mailname; "), c.Plan.Steps()[0].Detail)
+	assert.Contains(t, c.Plan.Steps()[0].Detail, "apt-get install")
+	c.DryRun = false
+	c.Plan = nil
+
+	// Nothing is installed if the preseed is invalid.
+	fake.Commands = nil
+	r.Preseed = []string{"postfix postfix/mailname"}
+	_, err = Apply(c, r)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(fake.Commands), "should be equal")
+}
+
 func Test_AptPkg_Apply(t *testing.T) {
 	acc := os.Getenv("TEST_ACC")
 	if acc == "" {
@@ -0,0 +1,367 @@
+package aptpkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version represents a Debian package version in the form
+// [epoch:]upstream[-revision].
+type Version struct {
+	// Epoch is the epoch of the version. It is 0 if not set.
+	Epoch int
+
+	// Upstream is the upstream version.
+	Upstream string
+
+	// Revision is the Debian revision. It is empty if not set.
+	Revision string
+}
+
+// ParseVersion will parse a Debian package version, following the rules
+// of dpkg. The epoch is the part before the first colon and the revision
+// is the part after the last hyphen.
+func ParseVersion(s string) (v Version, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		err = fmt.Errorf("Invalid version: version is empty")
+		return
+	}
+
+	upstream := s
+	if i := strings.Index(s, ":"); i >= 0 {
+		v.Epoch, err = strconv.Atoi(s[:i])
+		if err != nil || v.Epoch < 0 {
+			err = fmt.Errorf("Invalid version %s: epoch is not a number", s)
+			return
+		}
+		upstream = s[i+1:]
+	}
+
+	if i := strings.LastIndex(upstream, "-"); i >= 0 {
+		v.Revision = upstream[i+1:]
+		upstream = upstream[:i]
+
+		if v.Revision == "" {
+			err = fmt.Errorf("Invalid version %s: revision is empty", s)
+			return
+		}
+	}
+
+	v.Upstream = upstream
+
+	if upstream == "" {
+		err = fmt.Errorf("Invalid version %s: upstream version is empty", s)
+		return
+	}
+
+	if upstream[0] < '0' || upstream[0] > '9' {
+		err = fmt.Errorf("Invalid version %s: upstream version does not start with a digit", s)
+		return
+	}
+
+	if !versionValid(upstream, ".+~-:") || !versionValid(v.Revision, ".+~") {
+		err = fmt.Errorf("Invalid version %s: invalid character in version", s)
+		return
+	}
+
+	return
+}
+
+// String returns the version in the form [epoch:]upstream[-revision].
+// The epoch is only included if it is not 0.
+func (v Version) String() string {
+	s := v.Upstream
+	if v.Epoch != 0 {
+		s = fmt.Sprintf("%d:%s", v.Epoch, s)
+	}
+
+	if v.Revision != "" {
+		s = fmt.Sprintf("%s-%s", s, v.Revision)
+	}
+
+	return s
+}
+
+// Compare compares two versions like dpkg --compare-versions. It returns
+// -1 if v is lower than o, 0 if they are equal, and 1 if v is higher
+// than o.
+func (v Version) Compare(o Version) int {
+	if v.Epoch != o.Epoch {
+		return versionSign(v.Epoch - o.Epoch)
+	}
+
+	if c := versionCompare(v.Upstream, o.Upstream); c != 0 {
+		return versionSign(c)
+	}
+
+	return versionSign(versionCompare(v.Revision, o.Revision))
+}
+
+// CompareVersions compares two Debian package versions. It returns -1 if
+// a is lower than b, 0 if they are equal, and 1 if a is higher than b.
+func CompareVersions(a, b string) (int, error) {
+	va, err := ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+
+	vb, err := ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	return va.Compare(vb), nil
+}
+
+// Valid operators of a Constraint. They are the relations of Debian
+// package dependencies, with "!=" added.
+const (
+	OpEqual        = "="
+	OpNotEqual     = "!="
+	OpLess         = "<<"
+	OpLessEqual    = "<="
+	OpGreater      = ">>"
+	OpGreaterEqual = ">="
+)
+
+// Constraint represents a requirement on the version of a package, such
+// as ">= 2.4".
+type Constraint struct {
+	// Op is the operator, such as ">=".
+	Op string
+
+	// Version is the version the operator compares to.
+	Version Version
+}
+
+// String returns the constraint in the form "op version".
+func (c Constraint) String() string {
+	return fmt.Sprintf("%s %s", c.Op, c.Version)
+}
+
+// Allows reports whether a version satisfies the constraint.
+func (c Constraint) Allows(v Version) bool {
+	cmp := v.Compare(c.Version)
+
+	switch c.Op {
+	case OpEqual:
+		return cmp == 0
+	case OpNotEqual:
+		return cmp != 0
+	case OpLess:
+		return cmp < 0
+	case OpLessEqual:
+		return cmp <= 0
+	case OpGreater:
+		return cmp > 0
+	case OpGreaterEqual:
+		return cmp >= 0
+	}
+
+	return false
+}
+
+// ParseConstraints will parse version constraints separated by commas,
+// such as ">= 2.4, << 3". A version without an operator must be equal.
+//
+// The operators of Debian dependencies are accepted, as well as "<" and
+// ">" for "<<" and ">>", "!=", and "~>", which allows a version and
+// later versions up to the next release of the part before its last
+// component. For example, "~> 1.18" is ">= 1.18, << 2~" and "~> 1.18.0"
+// is ">= 1.18.0, << 1.19~", so that pre-releases of the next release are
+// not allowed either.
+func ParseConstraints(s string) (constraints []Constraint, err error) {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+
+		var op string
+		for _, o := range []string{"~>", "<<", "<=", ">>", ">=", "!=", "=", "<", ">"} {
+			if strings.HasPrefix(part, o) {
+				op = o
+				part = strings.TrimSpace(part[len(o):])
+				break
+			}
+		}
+
+		var v Version
+		if v, err = ParseVersion(part); err != nil {
+			err = fmt.Errorf("Invalid constraint %q: %s", s, err)
+			return
+		}
+
+		switch op {
+		case "", OpEqual:
+			constraints = append(constraints, Constraint{Op: OpEqual, Version: v})
+		case "<":
+			constraints = append(constraints, Constraint{Op: OpLess, Version: v})
+		case ">":
+			constraints = append(constraints, Constraint{Op: OpGreater, Version: v})
+		case "~>":
+			var next Version
+			if next, err = versionNextRelease(v); err != nil {
+				err = fmt.Errorf("Invalid constraint %q: %s", s, err)
+				return
+			}
+
+			constraints = append(constraints,
+				Constraint{Op: OpGreaterEqual, Version: v},
+				Constraint{Op: OpLess, Version: next})
+		default:
+			constraints = append(constraints, Constraint{Op: op, Version: v})
+		}
+	}
+
+	return
+}
+
+// Satisfies reports whether a version satisfies every constraint. An
+// invalid version satisfies none.
+func Satisfies(version string, constraints []Constraint) bool {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false
+	}
+
+	for _, c := range constraints {
+		if !c.Allows(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// versionNextRelease is an internal function that will return the lowest
+// version of the release after the part of a version before its last
+// component, including pre-releases, such as 2~ for 1.18.
+func versionNextRelease(v Version) (next Version, err error) {
+	parts := strings.Split(v.Upstream, ".")
+	if len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+
+	last := len(parts) - 1
+	n, err := strconv.Atoi(parts[last])
+	if err != nil {
+		err = fmt.Errorf("~> needs a version of numbers separated by dots")
+		return
+	}
+
+	parts[last] = strconv.Itoa(n + 1)
+
+	next.Epoch = v.Epoch
+	next.Upstream = strings.Join(parts, ".") + "~"
+
+	return
+}
+
+// versionValid is an internal function that will report if every
+// character of a part of a version is a letter, a digit, or one of the
+// given characters.
+func versionValid(s, chars string) bool {
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case strings.ContainsRune(chars, c):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// versionCompare is an internal function that will compare two parts of
+// a version like the verrevcmp function of dpkg. The parts are compared
+// as alternating runs of non-digits and digits. Non-digits compare by
+// versionOrder and digits compare as numbers.
+func versionCompare(a, b string) int {
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !versionIsDigit(a[i])) || (j < len(b) && !versionIsDigit(b[j])) {
+			ac, bc := versionOrder(a, i), versionOrder(b, j)
+			if ac != bc {
+				return ac - bc
+			}
+
+			i++
+			j++
+		}
+
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+
+		firstDiff := 0
+		for i < len(a) && versionIsDigit(a[i]) && j < len(b) && versionIsDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+
+			i++
+			j++
+		}
+
+		if i < len(a) && versionIsDigit(a[i]) {
+			return 1
+		}
+
+		if j < len(b) && versionIsDigit(b[j]) {
+			return -1
+		}
+
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+
+	return 0
+}
+
+// versionOrder is an internal function that will return the weight of
+// the character at a position of a part of a version. A tilde sorts
+// before anything, even the end of the part, and letters sort before
+// other characters.
+func versionOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+
+	c := s[i]
+	switch {
+	case versionIsDigit(c):
+		return 0
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	}
+
+	return int(c) + 256
+}
+
+// versionIsDigit is an internal function that will report if a character
+// is a digit.
+func versionIsDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// versionSign is an internal function that will return the sign of a
+// comparison as -1, 0, or 1.
+func versionSign(c int) int {
+	switch {
+	case c < 0:
+		return -1
+	case c > 0:
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,217 @@
+package aptpkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected Version
+	}{
+		{"1.0", Version{Upstream: "1.0"}},
+		{"1.0-1", Version{Upstream: "1.0", Revision: "1"}},
+		{"1:1.0-1", Version{Epoch: 1, Upstream: "1.0", Revision: "1"}},
+		{"0:1.0", Version{Upstream: "1.0"}},
+		{"1.0-beta-2ubuntu1", Version{Upstream: "1.0-beta", Revision: "2ubuntu1"}},
+		{"2:1.0:2-1", Version{Epoch: 2, Upstream: "1.0:2", Revision: "1"}},
+		{"1.0~rc1+dfsg-1~bpo11+1", Version{Upstream: "1.0~rc1+dfsg", Revision: "1~bpo11+1"}},
+		{" 1.0 ", Version{Upstream: "1.0"}},
+	}
+
+	for _, test := range tests {
+		v, err := ParseVersion(test.version)
+		assert.Nil(t, err, test.version)
+		assert.Equal(t, test.expected, v, test.version)
+	}
+
+	invalid := []string{
+		"",
+		"a1.0",
+		"-1:1.0",
+		"x:1.0",
+		":1.0",
+		"1.0-",
+		"1:",
+		"1.0 beta",
+		"1.0_1",
+		"1.0-1:2",
+	}
+
+	for _, version := range invalid {
+		_, err := ParseVersion(version)
+		assert.NotNil(t, err, version)
+	}
+}
+
+func Test_Version_String(t *testing.T) {
+	tests := []string{"1.0", "1.0-1", "1:1.0-1", "1.0~rc1+dfsg-1~bpo11+1"}
+	for _, test := range tests {
+		v, err := ParseVersion(test)
+		assert.Nil(t, err)
+		assert.Equal(t, test, v.String(), "should be equal")
+	}
+
+	v, _ := ParseVersion("0:1.0")
+	assert.Equal(t, "1.0", v.String(), "should be equal")
+}
+
+func Test_CompareVersions(t *testing.T) {
+	// Each pair was checked with dpkg --compare-versions.
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		// Equal versions.
+		{"1.0", "1.0", 0},
+		{"1.0-1", "1.0-1", 0},
+		{"0:1.0", "1.0", 0},
+		{"1.0", "1.0-0", 0},
+		{"1.002", "1.2", 0},
+		{"1.0-01", "1.0-1", 0},
+		{"0", "00", 0},
+
+		// Epochs take precedence.
+		{"1:0", "0:9.9", 1},
+		{"2:1.0", "1:9.9", 1},
+		{"1:1.0-1", "1.0-1", 1},
+		{"10:1", "9:1", 1},
+
+		// Numbers compare as numbers.
+		{"1.0", "1.1", -1},
+		{"1.2.3", "1.2.10", -1},
+		{"1.9", "1.10", -1},
+		{"10", "9", 1},
+		{"1.0.1", "1.0", 1},
+		{"1.0", "1", 1},
+
+		// Revisions compare after the upstream version.
+		{"1.0-1", "1.0-2", -1},
+		{"1.0-2", "1.0-10", -1},
+		{"1.0-1ubuntu1", "1.0-1", 1},
+		{"1.0-1ubuntu1", "1.0-1ubuntu2", -1},
+		{"1.0-1", "1.0", 1},
+		{"1.1-1", "1.0-9", 1},
+		{"1.0-beta-2", "1.0-beta-10", -1},
+
+		// A tilde sorts before anything, even the end of the version.
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~~", "1.0~~a", -1},
+		{"1.0~~a", "1.0~", -1},
+		{"1.0~", "1.0", -1},
+		{"1.0-1~bpo1", "1.0-1", -1},
+		{"1.0~rc1-1", "1.0-1", -1},
+		{"2~", "1.99", 1},
+		{"2~", "2~rc1", -1},
+		{"2~rc1", "2", -1},
+
+		// Letters sort before other characters.
+		{"1.0", "1.0a", -1},
+		{"1.0a", "1.0b", -1},
+		{"1.0a", "1.0.", -1},
+		{"1.0a", "1.0+", -1},
+		{"1.0Z", "1.0a", -1},
+		{"1.0+1", "1.0", 1},
+		{"1.0+a", "1.0.a", -1},
+		{"1.0+dfsg", "1.0", 1},
+		{"1.0+dfsg-1", "1.0-2", 1},
+		{"1.0a", "1.0-1", 1},
+
+		// Real versions.
+		{"7.81.0-1ubuntu1.15", "7.81.0-1ubuntu1.16", -1},
+		{"2.35-0ubuntu3.6", "2.35-0ubuntu3.5", 1},
+		{"1:9.4p1-1ubuntu0.1", "1:9.4p1-1", 1},
+		{"3.0.2-0ubuntu1.12", "3.0.2-0ubuntu1.9", 1},
+		{"5.4.1-1ubuntu1", "5.4.1-1ubuntu1", 0},
+		{"1.18.0-6ubuntu14.4", "1.18.0-6ubuntu14.4.1~", -1},
+	}
+
+	for _, test := range tests {
+		c, err := CompareVersions(test.a, test.b)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, c, "%s %s", test.a, test.b)
+
+		c, err = CompareVersions(test.b, test.a)
+		assert.Nil(t, err)
+		assert.Equal(t, -test.expected, c, "%s %s", test.b, test.a)
+	}
+
+	_, err := CompareVersions("1.0", "a")
+	assert.NotNil(t, err)
+}
+
+func Test_ParseConstraints(t *testing.T) {
+	tests := []struct {
+		constraint string
+		expected   string
+	}{
+		{"1.0-1", "= 1.0-1"},
+		{"= 1.0", "= 1.0"},
+		{">= 2.4", ">= 2.4"},
+		{">=2.4", ">= 2.4"},
+		{"> 2.4", ">> 2.4"},
+		{">> 2.4", ">> 2.4"},
+		{"< 3", "<< 3"},
+		{"<= 3", "<= 3"},
+		{"!= 2.5-1", "!= 2.5-1"},
+		{">= 2.4, << 3", ">= 2.4, << 3"},
+		{"~> 1.18", ">= 1.18, << 2~"},
+		{"~> 1.18.0", ">= 1.18.0, << 1.19~"},
+		{"~> 2", ">= 2, << 3~"},
+		{"~> 1:1.18-1", ">= 1:1.18-1, << 1:2~"},
+	}
+
+	for _, test := range tests {
+		constraints, err := ParseConstraints(test.constraint)
+		assert.Nil(t, err, test.constraint)
+
+		var s []string
+		for _, c := range constraints {
+			s = append(s, c.String())
+		}
+		assert.Equal(t, test.expected, strings.Join(s, ", "), test.constraint)
+	}
+
+	invalid := []string{"", ">=", ">= a", "1.0,", "~> 1a", "=> 1.0"}
+	for _, constraint := range invalid {
+		_, err := ParseConstraints(constraint)
+		assert.NotNil(t, err, constraint)
+	}
+}
+
+func Test_Satisfies(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		expected   bool
+	}{
+		{"2.4", ">= 2.4", true},
+		{"2.4-1", ">= 2.4", true},
+		{"2.4~rc1", ">= 2.4", false},
+		{"2.3.9", ">= 2.4", false},
+		{"2.4", "> 2.4", false},
+		{"2.4-1", "> 2.4", true},
+		{"3.0", ">= 2.4, << 3", false},
+		{"2.99", ">= 2.4, << 3", true},
+		{"1.18.0-6ubuntu14.4", "~> 1.18", true},
+		{"1.25.3", "~> 1.18", true},
+		{"2.0", "~> 1.18", false},
+		{"2.0~rc1", "~> 1.18", false},
+		{"1.19.0", "~> 1.18.0", false},
+		{"1.18.9", "~> 1.18.0", true},
+		{"1:1.0", "1.0", false},
+		{"0:1.0", "1.0", true},
+		{"1.0-1", "!= 1.0-1", false},
+		{"not-a-version", ">= 1.0", false},
+	}
+
+	for _, test := range tests {
+		constraints, err := ParseConstraints(test.constraint)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, Satisfies(test.version, constraints), "%s %s", test.version, test.constraint)
+	}
+}
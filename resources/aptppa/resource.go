@@ -2,12 +2,12 @@ package aptppa
 
 import (
 	"fmt"
-	"os"
 	"path"
-	"path/filepath"
 	"strings"
 
 	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/facts"
 	"github.com/jtopjian/craft/resources"
 	"github.com/jtopjian/craft/utils"
 )
@@ -33,15 +33,19 @@ type CreateOpts struct {
 func Read(client client.Client, ppa string) (aptPPA AptPPA, err error) {
 	client.Logger.Debugf("Reading PPA %s", ppa)
 
-	sourceFileName, err := aptPPASourceFileName(ppa)
+	osInfo, err := facts.ReadOS(client)
 	if err != nil {
 		return
 	}
 
-	v := "/etc/apt/sources.list.d/" + sourceFileName
+	v := "/etc/apt/sources.list.d/" + aptPPASourceFileName(osInfo, ppa)
 	client.Logger.Debugf("PPA file: %s", v)
-	_, err = os.Stat(v)
-	if os.IsNotExist(err) {
+	exists, err := executor.Exists(client.System(), v)
+	if err != nil {
+		return
+	}
+
+	if !exists {
 		err = resources.NotFoundError{Type: Type, Name: ppa}
 		return
 	}
@@ -76,18 +80,18 @@ func Exists(client client.Client, ppa string) (exists bool, err error) {
 func List(client client.Client) (aptPPAs []AptPPA, err error) {
 	client.Logger.Debugf("Listing all PPAs")
 
-	files, err := filepath.Glob("/etc/apt/sources.list.d/*.list")
+	files, err := client.System().Glob("/etc/apt/sources.list.d/*.list")
 	if err != nil {
 		return
 	}
 
-	lsbInfo, err := utils.GetLSBInfo()
+	osInfo, err := facts.ReadOS(client)
 	if err != nil {
 		return
 	}
 
-	distro := fmt.Sprintf("-%s-", strings.ToLower(lsbInfo.DistributorID))
-	release := fmt.Sprintf("-%s", strings.ToLower(lsbInfo.Codename))
+	distro := fmt.Sprintf("-%s-", osInfo.ID)
+	release := fmt.Sprintf("-%s", osInfo.Codename)
 
 	for _, file := range files {
 		ppa := path.Base(file)
@@ -118,17 +122,21 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 
 	client.Logger.Debugf("PPA Create Options: %#v", createOpts)
 
-	eo.Command = fmt.Sprintf("apt-add-repository -y ppa:%s", createOpts.Name)
-	_, err = utils.Exec(eo)
-	if err != nil {
-		return
+	eo.Args = []string{"apt-add-repository", "-y", "ppa:" + createOpts.Name}
+	if !client.Pending(Type, createOpts.Name, resources.ActionCreate, eo.String()) {
+		_, err = client.Exec(eo)
+		if err != nil {
+			return
+		}
 	}
 
 	if createOpts.Refresh {
-		eo.Command = fmt.Sprintf("apt-get update -qq")
-		_, err = utils.Exec(eo)
-		if err != nil {
-			return
+		eo.Args = []string{"apt-get", "update", "-qq"}
+		if !client.Pending(Type, createOpts.Name, resources.ActionCreate, eo.String()) {
+			_, err = client.Exec(eo)
+			if err != nil {
+				return
+			}
 		}
 	}
 
@@ -145,25 +153,30 @@ func Delete(client client.Client, ppa string) (err error) {
 	var eo utils.ExecOptions
 	client.Logger.Debugf("Deleting PPA %s", ppa)
 
-	eo.Command = fmt.Sprintf("apt-add-repository -y -r ppa:%s", ppa)
-	_, err = utils.Exec(eo)
+	eo.Args = []string{"apt-add-repository", "-y", "-r", "ppa:" + ppa}
+	if client.Pending(Type, ppa, resources.ActionDelete, eo.String()) {
+		client.Pending(Type, ppa, resources.ActionDelete, "apt-get update -qq")
+		return
+	}
+
+	_, err = client.Exec(eo)
 	if err != nil {
 		return
 	}
 
-	sourceFileName, err := aptPPASourceFileName(ppa)
+	osInfo, err := facts.ReadOS(client)
 	if err != nil {
 		return
 	}
 
-	v := "/etc/apt/sources.list.d/" + sourceFileName
-	err = os.Remove(v)
+	v := "/etc/apt/sources.list.d/" + aptPPASourceFileName(osInfo, ppa)
+	err = client.System().Remove(v, true)
 	if err != nil {
 		return
 	}
 
-	eo.Command = fmt.Sprintf("apt-get update -qq")
-	_, err = utils.Exec(eo)
+	eo.Args = []string{"apt-get", "update", "-qq"}
+	_, err = client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -171,14 +184,11 @@ func Delete(client client.Client, ppa string) (err error) {
 	return
 }
 
-func aptPPASourceFileName(name string) (string, error) {
-	lsbInfo, err := utils.GetLSBInfo()
-	if err != nil {
-		return "", nil
-	}
-
-	distro := fmt.Sprintf("-%s-", strings.ToLower(lsbInfo.DistributorID))
-	release := strings.ToLower(lsbInfo.Codename)
+// aptPPASourceFileName is an internal function that will determine the
+// name of the apt source file of a PPA.
+func aptPPASourceFileName(osInfo facts.OS, name string) string {
+	distro := fmt.Sprintf("-%s-", osInfo.ID)
+	release := osInfo.Codename
 
 	name = strings.Replace(name, "/", distro, -1)
 	name = strings.Replace(name, ":", "-", -1)
@@ -186,5 +196,67 @@ func aptPPASourceFileName(name string) (string, error) {
 
 	name = fmt.Sprintf("%s-%s.list", name, release)
 
-	return name, nil
+	return name
+}
+
+// Resource represents the desired state of a PPA.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge a PPA to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the PPA.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the PPA.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the PPA is installed.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff always reports no differences since a PPA is either installed
+// or not.
+func (r Resource) Diff(current interface{}) []resources.Diff {
+	return nil
+}
+
+// Create will install the PPA.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update does nothing since a PPA cannot be updated.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	return nil
+}
+
+// Delete will remove the PPA.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// LockName returns the apt lock since add-apt-repository refreshes the
+// package lists.
+func (r Resource) LockName() string {
+	return "apt"
 }
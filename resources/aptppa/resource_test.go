@@ -4,14 +4,19 @@ import (
 	"os"
 	"testing"
 
+	"github.com/jtopjian/craft/facts"
 	"github.com/jtopjian/craft/testhelper"
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_aptPPASourceFileName(t *testing.T) {
+	osInfo := facts.OS{
+		ID:       "ubuntu",
+		Codename: "xenial",
+	}
+
 	ppa := "chris-lea/redis-server"
-	name, err := aptPPASourceFileName(ppa)
-	assert.Nil(t, err)
+	name := aptPPASourceFileName(osInfo, ppa)
 
 	assert.Equal(t, name, "chris-lea-ubuntu-redis-server-xenial.list", "should be equal")
 }
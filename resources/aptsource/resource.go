@@ -2,15 +2,17 @@ package aptsource
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
-	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/facts"
 	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/aptkey"
 	"github.com/jtopjian/craft/utils"
 )
 
@@ -45,7 +47,8 @@ type CreateOpts struct {
 	URI string `required:"true"`
 
 	// Distribution is the distribution of the apt source entry.
-	Distribution string `required:"true"`
+	// If not set, the codename of the system, such as "jammy", is used.
+	Distribution string
 
 	// Component is the component of the apt source entry.
 	Component string
@@ -62,8 +65,10 @@ type CreateOpts struct {
 func Read(client client.Client, name string) (aptSource AptSource, err error) {
 	client.Logger.Debugf("Reading apt source entry %s", name)
 
+	system := client.System()
+
 	path := fmt.Sprintf("/etc/apt/sources.list.d/%s.list", name)
-	_, err = os.Stat(path)
+	content, err := system.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			err = resources.NotFoundError{Type: Type, Name: name}
@@ -71,8 +76,7 @@ func Read(client client.Client, name string) (aptSource AptSource, err error) {
 		return
 	}
 
-	content, err := ioutil.ReadFile(path)
-	entry, err := aptSourceParseEntry(string(content))
+	entry, err := aptSourceParseEntry(strings.TrimSpace(string(content)))
 	if err != nil {
 		return
 	}
@@ -83,10 +87,7 @@ func Read(client client.Client, name string) (aptSource AptSource, err error) {
 	aptSource.Component = entry.Component
 
 	path = fmt.Sprintf("/etc/apt/sources.list.d/%s-src.list", name)
-	_, err = os.Stat(path)
-	if err == nil {
-		aptSource.IncludeSrc = true
-	}
+	aptSource.IncludeSrc, err = executor.Exists(system, path)
 
 	return
 }
@@ -121,7 +122,9 @@ func Exists(client client.Client, name string, includeSrc bool) (exists bool, er
 func List(client client.Client) (aptSources []AptSource, err error) {
 	client.Logger.Debugf("Listing all apt source entries")
 
-	files, err := filepath.Glob("/etc/apt/sources.list.d/*.list")
+	system := client.System()
+
+	files, err := system.Glob("/etc/apt/sources.list.d/*.list")
 	if err != nil {
 		return
 	}
@@ -132,7 +135,7 @@ func List(client client.Client) (aptSources []AptSource, err error) {
 		name = strings.Replace(name, ".list", "", -1)
 
 		var content []byte
-		content, err = ioutil.ReadFile(file)
+		content, err = system.ReadFile(file)
 		if err != nil {
 			return
 		}
@@ -178,33 +181,73 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 
 	client.Logger.Debugf("AptSource Create Options: %#v", createOpts)
 
+	if createOpts.Distribution == "" {
+		var osInfo facts.OS
+		osInfo, err = facts.ReadOS(client)
+		if err != nil {
+			return
+		}
+
+		if osInfo.Codename == "" {
+			err = fmt.Errorf("Unable to determine the distribution of apt source %s: %s has no codename",
+				createOpts.Name, osInfo.PrettyName)
+			return
+		}
+
+		createOpts.Distribution = osInfo.Codename
+	}
+
 	e := entry{
 		URI:          createOpts.URI,
 		Distribution: createOpts.Distribution,
 		Component:    createOpts.Component,
 	}
 
+	system := client.System()
+
 	path := fmt.Sprintf("/etc/apt/sources.list.d/%s.list", createOpts.Name)
 	content := aptSourceBuildEntry(e, false)
-	err = ioutil.WriteFile(path, []byte(content+"\n"), 0644)
-	if err != nil {
-		return
+	detail := fmt.Sprintf("write \"%s\" to %s", content, path)
+	if !client.Pending(Type, createOpts.Name, resources.ActionCreate, detail) {
+		err = client.WriteFile(path, []byte(content+"\n"), executor.WriteOpts{Mode: 0644})
+		if err != nil {
+			return
+		}
 	}
 
+	path = fmt.Sprintf("/etc/apt/sources.list.d/%s-src.list", createOpts.Name)
 	if createOpts.IncludeSrc {
-		path = fmt.Sprintf("/etc/apt/sources.list.d/%s-src.list", createOpts.Name)
 		content = aptSourceBuildEntry(e, true)
-		err = ioutil.WriteFile(path, []byte(content+"\n"), 0644)
+		detail = fmt.Sprintf("write \"%s\" to %s", content, path)
+		if !client.Pending(Type, createOpts.Name, resources.ActionCreate, detail) {
+			err = client.WriteFile(path, []byte(content+"\n"), executor.WriteOpts{Mode: 0644})
+			if err != nil {
+				return
+			}
+		}
+	} else {
+		var exists bool
+		exists, err = executor.Exists(system, path)
 		if err != nil {
 			return
 		}
+
+		// A source entry from a previous definition is no longer wanted.
+		if exists && !client.Pending(Type, createOpts.Name, resources.ActionCreate, fmt.Sprintf("rm %s", path)) {
+			err = system.Remove(path, false)
+			if err != nil {
+				return
+			}
+		}
 	}
 
 	if createOpts.Refresh {
-		eo.Command = fmt.Sprintf("apt-get update -qq")
-		_, err = utils.Exec(eo)
-		if err != nil {
-			return
+		eo.Args = []string{"apt-get", "update", "-qq"}
+		if !client.Pending(Type, createOpts.Name, resources.ActionCreate, eo.String()) {
+			_, err = client.Exec(eo)
+			if err != nil {
+				return
+			}
 		}
 	}
 
@@ -223,22 +266,27 @@ func Delete(client client.Client, name string) (err error) {
 	client.Logger.Debugf("Deleting apt source entry %s", name)
 
 	path := fmt.Sprintf("/etc/apt/sources.list.d/%s.list", name)
-	err = os.Remove(path)
+	if client.Pending(Type, name, resources.ActionDelete, fmt.Sprintf("rm %s", path)) {
+		client.Pending(Type, name, resources.ActionDelete, "apt-get update -qq")
+		return
+	}
+
+	system := client.System()
+
+	err = system.Remove(path, false)
 	if err != nil {
 		return
 	}
 
+	// The source entry may not exist, so it is removed like rm -f.
 	path = fmt.Sprintf("/etc/apt/sources.list.d/%s-src.list", name)
-	_, err = os.Stat(path)
-	if err == nil {
-		err = os.Remove(path)
-		if err != nil {
-			return
-		}
+	err = system.Remove(path, true)
+	if err != nil {
+		return
 	}
 
-	eo.Command = fmt.Sprintf("apt-get update -qq")
-	_, err = utils.Exec(eo)
+	eo.Args = []string{"apt-get", "update", "-qq"}
+	_, err = client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -266,8 +314,8 @@ func aptSourceBuildEntry(e entry, source bool) string {
 
 // aptSourceParseEntry is an internal function that will parse an apt source entry.
 func aptSourceParseEntry(e string) (entry entry, err error) {
-	v := strings.Split(e, " ")
-	if len(v) != 4 {
+	v := strings.Fields(e)
+	if len(v) < 3 {
 		err = fmt.Errorf("Unable to parse %s", v)
 		return
 	}
@@ -278,7 +326,98 @@ func aptSourceParseEntry(e string) (entry entry, err error) {
 
 	entry.URI = v[1]
 	entry.Distribution = v[2]
-	entry.Component = v[3]
+	entry.Component = strings.Join(v[3:], " ")
 
 	return
 }
+
+// Resource represents the desired state of an apt source entry.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge an apt source entry to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the apt source entry.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the apt source entry.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the apt source entry exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name, false)
+}
+
+// Diff will compare an existing apt source entry to the desired state.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	aptSource, _ := current.(AptSource)
+
+	if r.URI != aptSource.URI {
+		diffs = append(diffs, resources.Diff{Field: "URI", Old: aptSource.URI, New: r.URI})
+	}
+
+	if r.Distribution != "" && r.Distribution != aptSource.Distribution {
+		diffs = append(diffs, resources.Diff{Field: "Distribution", Old: aptSource.Distribution, New: r.Distribution})
+	}
+
+	if r.Component != aptSource.Component {
+		diffs = append(diffs, resources.Diff{Field: "Component", Old: aptSource.Component, New: r.Component})
+	}
+
+	if r.IncludeSrc != aptSource.IncludeSrc {
+		diffs = append(diffs, resources.Diff{
+			Field: "IncludeSrc",
+			Old:   strconv.FormatBool(aptSource.IncludeSrc),
+			New:   strconv.FormatBool(r.IncludeSrc),
+		})
+	}
+
+	return
+}
+
+// Create will create the apt source entry.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will rewrite the apt source entry since entries are
+// entirely described by their create options.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Delete will delete the apt source entry.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// AutoRequires returns every apt key so that the source can be refreshed
+// once the keys which sign it have been added.
+func (r Resource) AutoRequires() []resources.Ref {
+	return []resources.Ref{{Type: aptkey.Type, ID: "*"}}
+}
+
+// LockName returns the apt lock since the package lists are refreshed
+// when a source is changed.
+func (r Resource) LockName() string {
+	return "apt"
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/jtopjian/craft/executor"
 	"github.com/jtopjian/craft/testhelper"
 	"github.com/stretchr/testify/assert"
 )
@@ -49,6 +50,30 @@ func Test_aptSourceParseFile(t *testing.T) {
 	assert.Equal(t, expected, actual, "should be equal")
 }
 
+func Test_aptSourceParseEntry_Fields(t *testing.T) {
+	expected := entry{
+		URI:          "http://archive.ubuntu.com/ubuntu",
+		Distribution: "jammy",
+		Component:    "main restricted",
+	}
+
+	actual, err := aptSourceParseEntry("deb  http://archive.ubuntu.com/ubuntu\tjammy main restricted")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual, "should be equal")
+
+	expected = entry{
+		URI:          "http://example.com/repo",
+		Distribution: "./",
+	}
+
+	actual, err = aptSourceParseEntry("deb http://example.com/repo ./")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual, "should be equal")
+
+	_, err = aptSourceParseEntry("deb http://example.com/repo")
+	assert.NotNil(t, err)
+}
+
 func Test_AptSource_Apply(t *testing.T) {
 	acc := os.Getenv("TEST_ACC")
 	if acc == "" {
@@ -99,3 +124,25 @@ func Test_AptSource_Test(t *testing.T) {
 		t.Logf("%#v", source)
 	}
 }
+
+func Test_AptSource_DefaultDistribution(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/os-release", "ID=ubuntu\nVERSION_CODENAME=jammy\n", 0644)
+	fake.Mkdir("/etc/apt/sources.list.d", 0755, true)
+
+	client := testhelper.TestClient()
+	client.Executor = fake
+
+	createOpts := CreateOpts{
+		Name:      "rabbitmq",
+		URI:       "http://www.rabbitmq.com/debian/",
+		Component: "main",
+	}
+
+	err := Create(client, createOpts)
+	assert.Nil(t, err)
+
+	content, err := fake.ReadFile("/etc/apt/sources.list.d/rabbitmq.list")
+	assert.Nil(t, err)
+	assert.Equal(t, "deb http://www.rabbitmq.com/debian/ jammy main\n", string(content), "should be equal")
+}
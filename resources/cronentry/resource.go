@@ -2,13 +2,13 @@ package cronentry
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/jtopjian/craft/client"
 	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/useradd"
 	"github.com/jtopjian/craft/utils"
 )
 
@@ -87,7 +87,7 @@ type UpdateOpts struct {
 func Read(client client.Client, user, name string) (entry CronEntry, err error) {
 	client.Logger.Debugf("Reading cron entry %s for user %s", name, user)
 
-	entries, err := cronEntryGetEntries(user)
+	entries, err := cronEntryGetEntries(client, user)
 	if err != nil {
 		return
 	}
@@ -130,7 +130,7 @@ func Exists(client client.Client, user, name string) (exists bool, err error) {
 func List(client client.Client, user string) (entries []CronEntry, err error) {
 	client.Logger.Debugf("Listing all cron entries for user %s", user)
 
-	e, err := cronEntryGetEntries(user)
+	e, err := cronEntryGetEntries(client, user)
 	if err != nil {
 		return
 	}
@@ -148,9 +148,8 @@ func List(client client.Client, user string) (entries []CronEntry, err error) {
 	return
 }
 
+// Create will create a cron entry in a user's crontab.
 func Create(client client.Client, user string, createOpts CreateOpts) (err error) {
-	var eo utils.ExecOptions
-
 	client.Logger.Debugf("Creating cron entry for user %s", user)
 
 	if err = utils.BuildRequest(&createOpts); err != nil {
@@ -159,39 +158,23 @@ func Create(client client.Client, user string, createOpts CreateOpts) (err error
 
 	client.Logger.Debugf("Cron Entry Create Options: %#v", createOpts)
 
-	entries, err := cronEntryGetEntries(user)
+	entries, err := cronEntryGetEntries(client, user)
 	if err != nil {
 		return
 	}
 
-	newEntry := fmt.Sprintf("%s %s %s %s %s %s # %s",
-		createOpts.Minute, createOpts.Hour, createOpts.DayOfMonth, createOpts.Month,
-		createOpts.DayOfWeek, createOpts.Command, createOpts.Name)
+	newEntry := cronEntryBuildLine(createOpts)
 	entries = append(entries, newEntry)
 
-	var tmpfile *os.File
-	tmpfile, err = ioutil.TempFile("/tmp", "cron-entry")
-	if err != nil {
-		return
-	}
-	defer os.Remove(tmpfile.Name())
-
-	newEntries := strings.Join(entries, "\n")
-	newEntries = fmt.Sprintf("%s\n", newEntries)
-
-	if _, err = tmpfile.Write([]byte(newEntries)); err != nil {
+	detail := fmt.Sprintf("add \"%s\" to crontab of %s", newEntry, user)
+	if client.Pending(Type, createOpts.Name, resources.ActionCreate, detail) {
 		return
 	}
 
-	eo.Command = fmt.Sprintf("crontab -u %s %s", user, tmpfile.Name())
-	_, err = utils.Exec(eo)
-	if err != nil {
-		return
-	}
-
-	return
+	return cronEntryWriteEntries(client, user, entries)
 }
 
+// Update will replace an existing cron entry in a user's crontab.
 func Update(client client.Client, user, name string, updateOpts UpdateOpts) (err error) {
 	client.Logger.Debugf("Updating cron entry %s for user %s", name, user)
 
@@ -201,11 +184,6 @@ func Update(client client.Client, user, name string, updateOpts UpdateOpts) (err
 
 	client.Logger.Debugf("Cron Entry Update Options: %#v", updateOpts)
 
-	err = Delete(client, user, name)
-	if err != nil {
-		return
-	}
-
 	createOpts := CreateOpts{
 		Name:       name,
 		Command:    updateOpts.Command,
@@ -216,15 +194,39 @@ func Update(client client.Client, user, name string, updateOpts UpdateOpts) (err
 		DayOfWeek:  updateOpts.DayOfWeek,
 	}
 
-	return Create(client, user, createOpts)
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	oldEntries, err := cronEntryGetEntries(client, user)
+	if err != nil {
+		return
+	}
+
+	newEntry := cronEntryBuildLine(createOpts)
+
+	var newEntries []string
+	for _, entry := range oldEntries {
+		if strings.Contains(entry, fmt.Sprintf("# %s", name)) {
+			continue
+		}
+		newEntries = append(newEntries, entry)
+	}
+	newEntries = append(newEntries, newEntry)
+
+	detail := fmt.Sprintf("replace entry %s in crontab of %s with \"%s\"", name, user, newEntry)
+	if client.Pending(Type, name, resources.ActionUpdate, detail) {
+		return
+	}
+
+	return cronEntryWriteEntries(client, user, newEntries)
 }
 
+// Delete will delete a cron entry from a user's crontab.
 func Delete(client client.Client, user, name string) (err error) {
-	var eo utils.ExecOptions
-
 	client.Logger.Debugf("Deleting cron entry %s for user %s", name, user)
 
-	oldEntries, err := cronEntryGetEntries(user)
+	oldEntries, err := cronEntryGetEntries(client, user)
 	if err != nil {
 		return
 	}
@@ -237,21 +239,40 @@ func Delete(client client.Client, user, name string) (err error) {
 		newEntries = append(newEntries, entry)
 	}
 
-	var tmpfile *os.File
-	tmpfile, err = ioutil.TempFile("/tmp", "cron-entry")
-	if err != nil {
+	detail := fmt.Sprintf("remove entry %s from crontab of %s", name, user)
+	if client.Pending(Type, name, resources.ActionDelete, detail) {
 		return
 	}
-	defer os.Remove(tmpfile.Name())
 
-	v := strings.Join(newEntries, "\n")
+	return cronEntryWriteEntries(client, user, newEntries)
+}
+
+// cronEntryBuildLine is an internal function that will build a
+// crontab line from a set of create options.
+func cronEntryBuildLine(createOpts CreateOpts) string {
+	return fmt.Sprintf("%s %s %s %s %s %s # %s",
+		createOpts.Minute, createOpts.Hour, createOpts.DayOfMonth, createOpts.Month,
+		createOpts.DayOfWeek, createOpts.Command, createOpts.Name)
+}
+
+// cronEntryWriteEntries is an internal function that will replace the
+// crontab of a user with the given entries.
+func cronEntryWriteEntries(client client.Client, user string, entries []string) (err error) {
+	var eo utils.ExecOptions
+
+	system := client.System()
+	tmpfile := fmt.Sprintf("/tmp/craft-cron-entry-%d", time.Now().UnixNano())
+
+	v := strings.Join(entries, "\n")
 	v = fmt.Sprintf("%s\n", v)
-	if _, err = tmpfile.Write([]byte(v)); err != nil {
+	err = system.WriteFile(tmpfile, []byte(v), 0600)
+	if err != nil {
 		return
 	}
+	defer system.Remove(tmpfile, false)
 
-	eo.Command = fmt.Sprintf("crontab -u %s %s", user, tmpfile.Name())
-	_, err = utils.Exec(eo)
+	eo.Args = []string{"crontab", "-u", user, tmpfile}
+	_, err = client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -259,12 +280,16 @@ func Delete(client client.Client, user, name string) (err error) {
 	return
 }
 
-func cronEntryGetEntries(user string) (entries []string, err error) {
+func cronEntryGetEntries(client client.Client, user string) (entries []string, err error) {
 	var eo utils.ExecOptions
 
-	eo.Command = fmt.Sprintf("crontab -u %s -l", user)
-	execResult, err := utils.Exec(eo)
+	eo.Args = []string{"crontab", "-u", user, "-l"}
+	execResult, err := client.Exec(eo)
 	if err != nil {
+		// A user without a crontab has no entries.
+		if strings.Contains(execResult.Stderr, "no crontab for") {
+			err = nil
+		}
 		return
 	}
 
@@ -323,3 +348,109 @@ func cronEntryParseLine(line string) (entry CronEntry, err error) {
 
 	return
 }
+
+// Resource represents the desired state of a cron entry.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+
+	// User is the user whose crontab holds the entry.
+	User string `required:"true"`
+
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge a cron entry to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the user and name of the cron entry.
+func (r Resource) ID() string {
+	return fmt.Sprintf("%s/%s", r.User, r.Name)
+}
+
+// Read will read the cron entry.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.User, r.Name)
+}
+
+// Exists will determine if the cron entry exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.User, r.Name)
+}
+
+// Diff will compare an existing cron entry to the desired state.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	entry, _ := current.(CronEntry)
+
+	desired := r.CreateOpts
+	if invalid := resources.Desired(&desired); invalid != nil {
+		return invalid
+	}
+
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"Command", entry.Command, desired.Command},
+		{"Minute", entry.Minute, desired.Minute},
+		{"Hour", entry.Hour, desired.Hour},
+		{"DayOfMonth", entry.DayOfMonth, desired.DayOfMonth},
+		{"Month", entry.Month, desired.Month},
+		{"DayOfWeek", entry.DayOfWeek, desired.DayOfWeek},
+	}
+
+	for _, f := range fields {
+		if f.old != f.new {
+			diffs = append(diffs, resources.Diff{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+
+	return
+}
+
+// Create will create the cron entry.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.User, r.CreateOpts)
+}
+
+// Update will replace the cron entry. Since an entry is replaced as a
+// whole, all fields are used regardless of which differ.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		Command:    r.Command,
+		Minute:     r.Minute,
+		Hour:       r.Hour,
+		DayOfMonth: r.DayOfMonth,
+		Month:      r.Month,
+		DayOfWeek:  r.DayOfWeek,
+	}
+
+	return Update(client, r.User, r.Name, updateOpts)
+}
+
+// Delete will delete the cron entry.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.User, r.Name)
+}
+
+// AutoRequires returns the user whose crontab holds the entry.
+func (r Resource) AutoRequires() []resources.Ref {
+	return []resources.Ref{{Type: useradd.Type, ID: r.User}}
+}
+
+// LockName returns a lock for the crontab of the user so that entries
+// of the same crontab are not edited concurrently.
+func (r Resource) LockName() string {
+	return "crontab/" + r.User
+}
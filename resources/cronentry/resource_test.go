@@ -18,6 +18,19 @@ func Test_cronEntryExists(t *testing.T) {
 	assert.Equal(t, exists, true, "should be equal")
 }
 
+func Test_CronEntry_Diff_Invalid(t *testing.T) {
+	r := Resource{
+		User: "root",
+		CreateOpts: CreateOpts{
+			Name: "backup",
+		},
+	}
+
+	diffs := r.Diff(CronEntry{Name: "backup"})
+	assert.Equal(t, 1, len(diffs), "should be equal")
+	assert.Equal(t, "Options", diffs[0].Field, "should be equal")
+}
+
 func Test_CronEntry_Apply(t *testing.T) {
 	acc := os.Getenv("TEST_ACC")
 	if acc == "" {
@@ -0,0 +1,51 @@
+/*
+Package debconf manages the answers to debconf questions.
+
+Packages such as postfix, mysql-server, and tzdata ask questions when they are
+configured. Since packages are installed noninteractively, the default answers
+are used unless the questions were preseeded. Answers are set via
+debconf-set-selections, read via debconf-show, and listed via
+debconf-get-selections, which is part of the debconf-utils package.
+
+A question is referenced by its owner, which is usually the package which asks
+it, and its name.
+
+To see if a question was answered:
+
+	exists, err := debconf.Exists(client, "postfix", "postfix/main_mailer_type")
+
+To preseed a question:
+
+	createOpts := debconf.CreateOpts{
+		Owner:        "postfix",
+		Question:     "postfix/main_mailer_type",
+		QuestionType: "select",
+		Value:        "Internet Site",
+	}
+
+	err := debconf.Create(client, createOpts)
+
+To reset a question to its default:
+
+	err := debconf.Delete(client, "postfix", "postfix/main_mailer_type")
+
+To get a list of all questions:
+
+	questions, err := debconf.List(client)
+
+The answers to passwords cannot be read back, so they are only set when the
+question was not answered yet.
+
+Questions can also be preseeded when a package is installed with the Preseed
+option of aptpkg.CreateOpts. That option only applies when the package is
+installed or upgraded, so use this package to manage the answers of a package
+which is already installed.
+
+Changing an answer does not reconfigure the package which asked the question.
+To apply it, run dpkg-reconfigure, for example with an exec resource which is
+only run when it is notified by the question:
+
+	dpkg-reconfigure -f noninteractive postfix
+
+*/
+package debconf
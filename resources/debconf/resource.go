@@ -0,0 +1,474 @@
+package debconf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/utils"
+)
+
+const Type = "Debconf"
+
+// PasswordOmitted is the value debconf-show reports for a password.
+const PasswordOmitted = "(password omitted)"
+
+// Valid types of a question.
+var Types = []string{
+	"boolean", "error", "multiselect", "note", "password", "select", "string", "text", "title",
+}
+
+// Debconf represents a question in the debconf database.
+type Debconf struct {
+	// Owner is the package which owns the question.
+	Owner string
+
+	// Question is the name of the question, such as
+	// "postfix/main_mailer_type".
+	Question string
+
+	// Type is the type of the question, such as "select".
+	// It is only known when the questions are listed.
+	Type string
+
+	// Value is the answer to the question. The value of a password is
+	// PasswordOmitted.
+	Value string
+
+	// Seen is set if the question was asked or preseeded.
+	Seen bool
+}
+
+// CreateOpts represents options used to preseed a question via
+// debconf-set-selections.
+type CreateOpts struct {
+	// Owner is the package which owns the question.
+	Owner string `required:"true"`
+
+	// Question is the name of the question, such as
+	// "postfix/main_mailer_type".
+	Question string `required:"true"`
+
+	// QuestionType is the type of the question, such as "select" or
+	// "boolean". See Types.
+	QuestionType string `default:"string"`
+
+	// Value is the answer to the question.
+	Value string
+}
+
+// UpdateOpts represents options used to change the answer to a question
+// via debconf-set-selections.
+type UpdateOpts struct {
+	// Owner is the package which owns the question.
+	Owner string `required:"true"`
+
+	// QuestionType is the type of the question.
+	QuestionType string `default:"string"`
+
+	// Value is the answer to the question.
+	Value string
+}
+
+// Read will retrieve the answer to a question via debconf-show.
+func Read(client client.Client, owner, question string) (debconf Debconf, err error) {
+	var eo utils.ExecOptions
+
+	client.Logger.Debugf("Reading debconf question %s", question)
+
+	eo.Args = []string{"debconf-show", owner}
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	for _, d := range debconfParseShow(owner, execResult.Stdout) {
+		if d.Question == question {
+			debconf = d
+			return
+		}
+	}
+
+	err = resources.NotFoundError{Type: Type, Name: question}
+
+	return
+}
+
+// Exists will report if a question was answered. A question which was
+// never asked or preseeded only has the default of its template, so it
+// does not exist.
+func Exists(client client.Client, owner, question string) (exists bool, err error) {
+	client.Logger.Debugf("Checking if debconf question %s exists", question)
+
+	debconf, err := Read(client, owner, question)
+	if err != nil {
+		if _, ok := err.(resources.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	exists = debconf.Seen
+
+	return
+}
+
+// List will retrieve every question in the debconf database via
+// debconf-get-selections, which is part of the debconf-utils package.
+func List(client client.Client) (debconfs []Debconf, err error) {
+	var eo utils.ExecOptions
+
+	client.Logger.Debugf("Listing all debconf questions")
+
+	eo.Args = []string{"debconf-get-selections"}
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	selections, err := ParseSelections(execResult.Stdout)
+	if err != nil {
+		return
+	}
+
+	for _, s := range selections {
+		debconf := Debconf{
+			Owner:    s.Owner,
+			Question: s.Question,
+			Type:     s.Type,
+			Value:    s.Value,
+		}
+
+		debconfs = append(debconfs, debconf)
+	}
+
+	return
+}
+
+// Create will preseed the answer to a question via debconf-set-selections.
+func Create(client client.Client, createOpts CreateOpts) (err error) {
+	client.Logger.Debugf("Creating debconf question")
+
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	s := Selection{
+		Owner:    createOpts.Owner,
+		Question: createOpts.Question,
+		Type:     createOpts.QuestionType,
+		Value:    createOpts.Value,
+	}
+
+	client.Logger.Debugf("Debconf Create Options: %s", debconfRedact(s))
+
+	return debconfSet(client, resources.ActionCreate, s)
+}
+
+// Update will change the answer to a question via debconf-set-selections.
+func Update(client client.Client, question string, updateOpts UpdateOpts) (err error) {
+	client.Logger.Debugf("Updating debconf question %s", question)
+
+	if err = utils.BuildRequest(&updateOpts); err != nil {
+		return
+	}
+
+	s := Selection{
+		Owner:    updateOpts.Owner,
+		Question: question,
+		Type:     updateOpts.QuestionType,
+		Value:    updateOpts.Value,
+	}
+
+	client.Logger.Debugf("Debconf Update Options: %s", debconfRedact(s))
+
+	return debconfSet(client, resources.ActionUpdate, s)
+}
+
+// Delete will reset a question to the default of its template and mark
+// it as not seen, so that it is asked again.
+func Delete(client client.Client, owner, question string) (err error) {
+	var eo utils.ExecOptions
+
+	client.Logger.Debugf("Deleting debconf question %s", question)
+
+	commands := fmt.Sprintf("RESET %s\nFSET %s seen false\n", question, question)
+
+	eo.Args = []string{"debconf-communicate", owner}
+	eo.Stdin = strings.NewReader(commands)
+
+	detail := fmt.Sprintf("%s <<< %q", eo.String(), commands)
+	if client.Pending(Type, question, resources.ActionDelete, detail) {
+		return
+	}
+
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	// Each command reports a status code, which is 0 on success.
+	for _, line := range strings.Split(strings.TrimSpace(execResult.Stdout), "\n") {
+		if line != "0" && !strings.HasPrefix(line, "0 ") {
+			err = fmt.Errorf("Unable to reset debconf question %s: %s", question, line)
+			return
+		}
+	}
+
+	return
+}
+
+// Selection represents a line of debconf-set-selections.
+type Selection struct {
+	// Owner is the package which owns the question.
+	Owner string
+
+	// Question is the name of the question.
+	Question string
+
+	// Type is the type of the question.
+	Type string
+
+	// Value is the answer to the question.
+	Value string
+}
+
+// String returns the selection in the format of debconf-set-selections.
+func (s Selection) String() string {
+	return fmt.Sprintf("%s %s %s %s", s.Owner, s.Question, s.Type, s.Value)
+}
+
+// debconfSelectionRe matches a line of debconf-set-selections. Like
+// debconf-set-selections, the value is what follows the single
+// whitespace after the type.
+var debconfSelectionRe = regexp.MustCompile(`^\s*(\S+)\s+(\S+)\s+(\S+)(?:\s(.*))?$`)
+
+// ParseSelections will parse selections in the format of
+// debconf-set-selections and debconf-get-selections. Each line has the
+// owner, the question, the type, and the value, which may be empty or
+// contain whitespace. Lines starting with # are comments and a line
+// ending with a backslash continues on the next line.
+func ParseSelections(content string) (selections []Selection, err error) {
+	content = strings.Replace(content, "\r\n", "\n", -1)
+	content = strings.Replace(content, "\\\n", "", -1)
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		m := debconfSelectionRe.FindStringSubmatch(line)
+		if m == nil {
+			err = fmt.Errorf("Invalid debconf selection at line %d: %s", i+1, trimmed)
+			return
+		}
+
+		s := Selection{
+			Owner:    m[1],
+			Question: m[2],
+			Type:     m[3],
+			Value:    m[4],
+		}
+
+		if !debconfValidType(s.Type) {
+			err = fmt.Errorf("Invalid debconf selection at line %d: unknown type %s", i+1, s.Type)
+			return
+		}
+
+		selections = append(selections, s)
+	}
+
+	return
+}
+
+// SetSelections will set selections via debconf-set-selections. The
+// selections are passed on standard input so that passwords are not
+// shown in the process list. Dry runs are not handled.
+func SetSelections(client client.Client, selections []Selection) (err error) {
+	var eo utils.ExecOptions
+
+	var b strings.Builder
+	for _, s := range selections {
+		if !debconfValidType(s.Type) {
+			err = fmt.Errorf("Invalid type for debconf question %s: %s", s.Question, s.Type)
+			return
+		}
+
+		if strings.ContainsAny(s.Value, "\r\n") {
+			err = fmt.Errorf("Invalid value for debconf question %s: a value cannot span several lines", s.Question)
+			return
+		}
+
+		fmt.Fprintf(&b, "%s\n", s)
+	}
+
+	eo.Args = []string{"debconf-set-selections"}
+	eo.Stdin = strings.NewReader(b.String())
+
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	// debconf-set-selections only warns about a line it skips.
+	for _, line := range strings.Split(execResult.Stderr, "\n") {
+		if strings.Contains(line, "skipping line") {
+			err = fmt.Errorf("Unable to set debconf selections: %s", strings.TrimSpace(line))
+			return
+		}
+	}
+
+	return
+}
+
+// debconfSet is an internal function that will set the answer to a
+// question. The action is what is being done to the question.
+func debconfSet(client client.Client, action string, s Selection) (err error) {
+	detail := fmt.Sprintf("debconf-set-selections <<< %q", debconfRedact(s).String())
+	if client.Pending(Type, s.Question, action, detail) {
+		return
+	}
+
+	return SetSelections(client, []Selection{s})
+}
+
+// debconfRedact is an internal function that will hide the value of a
+// password so that it can be logged.
+func debconfRedact(s Selection) Selection {
+	if s.Type == "password" && s.Value != "" {
+		s.Value = "********"
+	}
+
+	return s
+}
+
+// debconfValidType is an internal function that will report if a type of
+// question is known to debconf.
+func debconfValidType(t string) bool {
+	for _, v := range Types {
+		if t == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// debconfShowRe matches a line of debconf-show. Questions which were
+// seen are marked with an asterisk.
+var debconfShowRe = regexp.MustCompile(`^([* ]) ([^:\s]+):(?: (.*))?$`)
+
+// debconfParseShow is an internal function that will parse the output of
+// debconf-show.
+func debconfParseShow(owner, stdout string) (debconfs []Debconf) {
+	for _, line := range strings.Split(stdout, "\n") {
+		m := debconfShowRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+
+		debconf := Debconf{
+			Owner:    owner,
+			Question: m[2],
+			Value:    m[3],
+			Seen:     m[1] == "*",
+		}
+
+		debconfs = append(debconfs, debconf)
+	}
+
+	return
+}
+
+// Resource represents the desired answer to a debconf question.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge a debconf question to its desired answer.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the question.
+func (r Resource) ID() string {
+	return r.Question
+}
+
+// Read will read the answer to the question.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Owner, r.Question)
+}
+
+// Exists will determine if the question was answered.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Owner, r.Question)
+}
+
+// Diff will compare the answer to the question to the desired answer.
+// A question which is not answered yet always has a diff, even when the
+// desired answer is empty. The answer to a password cannot be read, so
+// it is never different once it was answered.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	debconf, ok := current.(Debconf)
+
+	if !ok {
+		value := r.Value
+		if r.QuestionType == "password" {
+			value = PasswordOmitted
+		}
+
+		diffs = append(diffs, resources.Diff{Field: "Value", New: value})
+		return
+	}
+
+	if r.QuestionType == "password" || debconf.Value == PasswordOmitted {
+		return
+	}
+
+	if r.Value != debconf.Value {
+		diffs = append(diffs, resources.Diff{Field: "Value", Old: debconf.Value, New: r.Value})
+	}
+
+	return
+}
+
+// Create will preseed the answer to the question.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will change the answer to the question.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		Owner:        r.Owner,
+		QuestionType: r.QuestionType,
+		Value:        r.Value,
+	}
+
+	return Update(client, r.Question, updateOpts)
+}
+
+// Delete will reset the question.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Owner, r.Question)
+}
+
+// LockName returns the apt lock since the debconf database is locked
+// while packages are configured.
+func (r Resource) LockName() string {
+	return "apt"
+}
@@ -0,0 +1,190 @@
+package debconf
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testShow = `  postfix/mailname: mail.example.com
+* postfix/main_mailer_type: Internet Site
+  postfix/root_address:
+* postfix/sasl_password: (password omitted)
+`
+
+const testGetSelections = `# General type of mail configuration:
+# Choices: No configuration, Internet Site, Internet with smarthost, Satellite system, Local only
+postfix	postfix/main_mailer_type	select	Internet Site
+# System mail name:
+postfix	postfix/mailname	string	mail.example.com
+postfix	postfix/root_address	string	
+tzdata	tzdata/Areas	select	Etc
+`
+
+func Test_ParseSelections(t *testing.T) {
+	expected := []Selection{
+		{Owner: "postfix", Question: "postfix/main_mailer_type", Type: "select", Value: "Internet Site"},
+		{Owner: "postfix", Question: "postfix/mailname", Type: "string", Value: "mail.example.com"},
+		{Owner: "postfix", Question: "postfix/root_address", Type: "string", Value: ""},
+		{Owner: "tzdata", Question: "tzdata/Areas", Type: "select", Value: "Etc"},
+	}
+
+	selections, err := ParseSelections(testGetSelections)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, selections, "should be equal")
+
+	selections, err = ParseSelections("mysql-server mysql-server/root_password password \\\n  s3cret\n")
+	assert.Nil(t, err)
+	assert.Equal(t, []Selection{{Owner: "mysql-server", Question: "mysql-server/root_password", Type: "password", Value: "  s3cret"}}, selections, "should be equal")
+
+	selections, err = ParseSelections("tzdata tzdata/Areas select")
+	assert.Nil(t, err)
+	assert.Equal(t, []Selection{{Owner: "tzdata", Question: "tzdata/Areas", Type: "select"}}, selections, "should be equal")
+
+	invalid := []string{
+		"tzdata tzdata/Areas",
+		"tzdata tzdata/Areas choice Etc",
+	}
+
+	for _, content := range invalid {
+		_, err := ParseSelections(content)
+		assert.NotNil(t, err, content)
+	}
+
+	s := Selection{Owner: "postfix", Question: "postfix/main_mailer_type", Type: "select", Value: "Internet Site"}
+	actual, err := ParseSelections(s.String())
+	assert.Nil(t, err)
+	assert.Equal(t, []Selection{s}, actual, "should be equal")
+}
+
+func Test_debconfParseShow(t *testing.T) {
+	expected := []Debconf{
+		{Owner: "postfix", Question: "postfix/mailname", Value: "mail.example.com"},
+		{Owner: "postfix", Question: "postfix/main_mailer_type", Value: "Internet Site", Seen: true},
+		{Owner: "postfix", Question: "postfix/root_address"},
+		{Owner: "postfix", Question: "postfix/sasl_password", Value: PasswordOmitted, Seen: true},
+	}
+
+	debconfs := debconfParseShow("postfix", testShow)
+	assert.Equal(t, expected, debconfs, "should be equal")
+}
+
+func Test_Debconf_Fake(t *testing.T) {
+	var stdin []string
+
+	fake := executor.NewFake()
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch eo.Args[0] {
+		case "debconf-show":
+			er.Stdout = testShow
+		case "debconf-get-selections":
+			er.Stdout = testGetSelections
+		case "debconf-set-selections", "debconf-communicate":
+			b, _ := ioutil.ReadAll(eo.Stdin)
+			stdin = append(stdin, string(b))
+			er.Stdout = "0\n0 false\n"
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	questions, err := List(c)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(questions), "should be equal")
+	assert.Equal(t, "select", questions[0].Type, "should be equal")
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Owner:        "postfix",
+			Question:     "postfix/main_mailer_type",
+			QuestionType: "select",
+			Value:        "Internet Site",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Value = "Satellite system"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "update", change.Action, "should be equal")
+	assert.Equal(t, []string{"postfix postfix/main_mailer_type select Satellite system\n"}, stdin, "should be equal")
+
+	// A question which was not seen is preseeded.
+	r.Question = "postfix/mailname"
+	r.QuestionType = ""
+	r.Value = "mx.example.com"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "create", change.Action, "should be equal")
+	assert.Equal(t, "postfix postfix/mailname string mx.example.com\n", stdin[1], "should be equal")
+
+	// A question which is not answered yet is always different.
+	diffs := r.Diff(nil)
+	assert.Equal(t, []resources.Diff{{Field: "Value", New: "mx.example.com"}}, diffs, "should be equal")
+
+	r.Value = ""
+	diffs = r.Diff(nil)
+	assert.Equal(t, []resources.Diff{{Field: "Value"}}, diffs, "should be equal")
+
+	// A password cannot be read back.
+	r.Question = "postfix/sasl_password"
+	r.QuestionType = "password"
+	r.Value = "s3cret"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	diffs = r.Diff(nil)
+	assert.Equal(t, []resources.Diff{{Field: "Value", New: PasswordOmitted}}, diffs, "should be equal")
+
+	r.QuestionType = "choice"
+	r.Question = "postfix/root_address"
+	_, err = Apply(c, r)
+	assert.NotNil(t, err)
+
+	err = Delete(c, "postfix", "postfix/mailname")
+	assert.Nil(t, err)
+	assert.Equal(t, "RESET postfix/mailname\nFSET postfix/mailname seen false\n", stdin[2], "should be equal")
+}
+
+func Test_Debconf_Apply(t *testing.T) {
+	acc := os.Getenv("TEST_ACC")
+	if acc == "" {
+		t.Skip("TEST_ACC is not set. Skipping")
+	}
+
+	client := testhelper.TestClient()
+	owner := "craft-test"
+	question := "craft-test/answer"
+
+	createOpts := CreateOpts{
+		Owner:    owner,
+		Question: question,
+		Value:    "42",
+	}
+
+	err := Create(client, createOpts)
+	assert.Nil(t, err)
+
+	exists, err := Exists(client, owner, question)
+	assert.Nil(t, err)
+	assert.Equal(t, true, exists, "should be equal")
+
+	err = Delete(client, owner, question)
+	assert.Nil(t, err)
+
+	exists, err = Exists(client, owner, question)
+	assert.Nil(t, err)
+	assert.Equal(t, false, exists, "should be equal")
+}
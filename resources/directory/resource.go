@@ -3,9 +3,12 @@ package directory
 import (
 	"fmt"
 	"os"
+	"path"
 
 	"github.com/jtopjian/craft/client"
 	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/groupadd"
+	"github.com/jtopjian/craft/resources/useradd"
 	"github.com/jtopjian/craft/utils"
 )
 
@@ -57,7 +60,7 @@ type UpdateOpts struct {
 func Read(client client.Client, dirName string) (dir Directory, err error) {
 	client.Logger.Debugf("Reading directory %s", dirName)
 
-	file, err := os.Stat(dirName)
+	fi, err := client.System().Stat(dirName)
 	if err != nil {
 		if os.IsNotExist(err) {
 			err = resources.NotFoundError{Type: Type, Name: dirName}
@@ -65,31 +68,15 @@ func Read(client client.Client, dirName string) (dir Directory, err error) {
 		return
 	}
 
-	mode := file.Mode()
-	if !mode.IsDir() {
+	if !fi.IsDir() {
 		err = fmt.Errorf("%s is not a directory", dirName)
 		return
 	}
 
-	uid, gid, err := utils.GetFileOwner(dirName)
-	if err != nil {
-		return
-	}
-
-	owner, err := utils.UIDToName(uid)
-	if err != nil {
-		return
-	}
-
-	group, err := utils.GIDToName(gid)
-	if err != nil {
-		return
-	}
-
 	dir.Name = dirName
-	dir.Mode = mode.String()
-	dir.Owner = owner
-	dir.Group = group
+	dir.Mode = utils.ModeToString(fi.Mode)
+	dir.Owner = fi.Owner
+	dir.Group = fi.Group
 
 	return
 }
@@ -131,34 +118,31 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 		return
 	}
 
+	detail := fmt.Sprintf("mkdir %s with owner %s, group %s and mode %s",
+		createOpts.Name, createOpts.Owner, createOpts.Group, createOpts.Mode)
 	if createOpts.Parents {
-		err = os.MkdirAll(createOpts.Name, mode)
-		if err != nil {
-			return
-		}
-	} else {
-		err = os.Mkdir(createOpts.Name, mode)
-		if err != nil {
-			return
-		}
+		detail = fmt.Sprintf("mkdir -p %s with owner %s, group %s and mode %s",
+			createOpts.Name, createOpts.Owner, createOpts.Group, createOpts.Mode)
+	}
+
+	if client.Pending(Type, createOpts.Name, resources.ActionCreate, detail) {
+		return
 	}
 
-	uid, gid, err := utils.GetUIDGID(createOpts.Owner, createOpts.Group)
+	system := client.System()
+
+	err = system.Mkdir(createOpts.Name, mode, createOpts.Parents)
+	if err != nil {
+		return
+	}
+
+	err = system.Chown(createOpts.Name, createOpts.Owner, createOpts.Group, createOpts.Parents)
 	if err != nil {
 		return
 	}
 
 	if createOpts.Parents {
-		err = utils.ChownR(createOpts.Name, uid, gid)
-		if err != nil {
-			return
-		}
-		err = utils.ChmodR(createOpts.Name, mode)
-		if err != nil {
-			return
-		}
-	} else {
-		err = os.Chown(createOpts.Name, uid, gid)
+		err = system.Chmod(createOpts.Name, mode, true)
 		if err != nil {
 			return
 		}
@@ -177,6 +161,8 @@ func Update(client client.Client, dirName string, updateOpts UpdateOpts) (err er
 
 	client.Logger.Debugf("Directory Update Options: %#v", updateOpts)
 
+	system := client.System()
+
 	if updateOpts.Mode != "" {
 		var mode os.FileMode
 		mode, err = utils.StringToMode(updateOpts.Mode)
@@ -184,13 +170,13 @@ func Update(client client.Client, dirName string, updateOpts UpdateOpts) (err er
 			return
 		}
 
+		detail := fmt.Sprintf("chmod %s %s", updateOpts.Mode, dirName)
 		if updateOpts.Recurse {
-			err = utils.ChmodR(dirName, mode)
-			if err != nil {
-				return
-			}
-		} else {
-			err = os.Chmod(dirName, mode)
+			detail = fmt.Sprintf("chmod -R %s %s", updateOpts.Mode, dirName)
+		}
+
+		if !client.Pending(Type, dirName, resources.ActionUpdate, detail) {
+			err = system.Chmod(dirName, mode, updateOpts.Recurse)
 			if err != nil {
 				return
 			}
@@ -198,35 +184,13 @@ func Update(client client.Client, dirName string, updateOpts UpdateOpts) (err er
 	}
 
 	if updateOpts.Owner != "" || updateOpts.Group != "" {
-		var uid int
-		var gid int
-
-		uid, gid, err = utils.GetFileOwner(dirName)
-		if err != nil {
-			return
-		}
-
-		if updateOpts.Owner != "" {
-			uid, err = utils.UsernameToID(updateOpts.Owner)
-			if err != nil {
-				return
-			}
+		detail := fmt.Sprintf("chown %s:%s %s", updateOpts.Owner, updateOpts.Group, dirName)
+		if updateOpts.Recurse {
+			detail = fmt.Sprintf("chown -R %s:%s %s", updateOpts.Owner, updateOpts.Group, dirName)
 		}
 
-		if updateOpts.Group != "" {
-			gid, err = utils.GroupToID(updateOpts.Group)
-			if err != nil {
-				return
-			}
-		}
-
-		if updateOpts.Recurse {
-			err = utils.ChownR(dirName, uid, gid)
-			if err != nil {
-				return
-			}
-		} else {
-			err = os.Chown(dirName, uid, gid)
+		if !client.Pending(Type, dirName, resources.ActionUpdate, detail) {
+			err = system.Chown(dirName, updateOpts.Owner, updateOpts.Group, updateOpts.Recurse)
 			if err != nil {
 				return
 			}
@@ -240,12 +204,136 @@ func Update(client client.Client, dirName string, updateOpts UpdateOpts) (err er
 func Delete(client client.Client, dirName string, recurse bool) (err error) {
 	client.Logger.Debugf("Deleting directory %s (Recurse: %t)", dirName, recurse)
 
+	detail := fmt.Sprintf("rmdir %s", dirName)
 	if recurse {
-		err = os.RemoveAll(dirName)
+		detail = fmt.Sprintf("rm -r %s", dirName)
+	}
+
+	if client.Pending(Type, dirName, resources.ActionDelete, detail) {
 		return
 	}
 
-	err = os.Remove(dirName)
+	err = client.System().Remove(dirName, recurse)
+
+	return
+}
+
+// Resource represents the desired state of a directory.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+
+	// Recurse determines if updates and deletions should be applied to
+	// all files and directories within the directory.
+	Recurse bool
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
+
+// Apply will converge a directory to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the directory.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the directory.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the directory exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff will compare an existing directory to the desired state.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	dir, _ := current.(Directory)
+
+	desired := r.CreateOpts
+	if invalid := resources.Desired(&desired); invalid != nil {
+		return invalid
+	}
+
+	if desired.Owner != "" && desired.Owner != dir.Owner {
+		diffs = append(diffs, resources.Diff{Field: "Owner", Old: dir.Owner, New: desired.Owner})
+	}
+
+	if desired.Group != "" && desired.Group != dir.Group {
+		diffs = append(diffs, resources.Diff{Field: "Group", Old: dir.Group, New: desired.Group})
+	}
+
+	if desired.Mode != "" {
+		if mode, err := utils.StringToMode(desired.Mode); err == nil {
+			if v := utils.ModeToString(mode); v != dir.Mode {
+				diffs = append(diffs, resources.Diff{Field: "Mode", Old: dir.Mode, New: v})
+			}
+		}
+	}
+
+	return
+}
+
+// Create will create the directory.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will update the differing fields of the directory.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		Recurse: r.Recurse,
+	}
+
+	desired := r.CreateOpts
+	if err := utils.BuildRequest(&desired); err != nil {
+		return err
+	}
+
+	for _, diff := range diffs {
+		switch diff.Field {
+		case "Owner":
+			updateOpts.Owner = desired.Owner
+		case "Group":
+			updateOpts.Group = desired.Group
+		case "Mode":
+			updateOpts.Mode = desired.Mode
+		}
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will delete the directory.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name, r.Recurse)
+}
+
+// AutoRequires returns the owner, group, and parent directory of the
+// directory so that they are applied before it when they are managed.
+func (r Resource) AutoRequires() (refs []resources.Ref) {
+	if parent := path.Dir(r.Name); parent != r.Name {
+		refs = append(refs, resources.Ref{Type: Type, ID: parent})
+	}
+
+	if r.Owner != "" {
+		refs = append(refs, resources.Ref{Type: useradd.Type, ID: r.Owner})
+	}
+
+	if r.Group != "" {
+		refs = append(refs, resources.Ref{Type: groupadd.Type, ID: r.Group})
+	}
 
 	return
 }
@@ -0,0 +1,38 @@
+/*
+Package dnfpkg manages a package via dnf.
+
+To see if a package is installed:
+
+	exists, err := dnfpkg.Exists(client, "sl")
+
+To install a package:
+
+	createOpts := dnfpkg.CreateOpts{
+		Name: "sl",
+	}
+
+	err := dnfpkg.Create(client, createOpts)
+	if err != nil {
+		return err
+	}
+
+To update a package:
+
+	updateOpts := dnfpkg.UpdateOpts{
+		Version: "latest",
+	}
+
+	err := dnfpkg.Update(client, "sl", updateOpts)
+	if err != nil {
+		return err
+	}
+
+A specific version may be given with or without its release and epoch,
+such as "5.02" or "1:5.02-1.el8".
+
+To obtain a list of all packages installed:
+
+	pkgs, err := dnfpkg.List(client)
+
+*/
+package dnfpkg
@@ -0,0 +1,389 @@
+package dnfpkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/utils"
+)
+
+const Type = "DnfPkg"
+
+// rpmQueryFormat is the format used to query installed packages with rpm.
+// The epoch is "(none)" for packages without one.
+const rpmQueryFormat = "%{NAME} %{EPOCH} %{VERSION} %{RELEASE} %{ARCH}\n"
+
+// DnfPkg represents a package managed by dnf.
+type DnfPkg struct {
+	// Name is the name of the package.
+	Name string
+
+	// Version is the version of the package in the form
+	// [epoch:]version-release.
+	Version string
+
+	// LatestVersion is the latest version of the package available.
+	LatestVersion string
+}
+
+// CreateOpts represents options used to install a package via dnf.
+type CreateOpts struct {
+	// Name is the name of the package.
+	Name string `required:"true"`
+
+	// Version is the version of the package.
+	// The following values are valid: a specific version number and "latest".
+	// A version without a release, such as "5.02", matches any release.
+	Version string
+}
+
+// UpdateOpts represents options used to update a package via dnf.
+type UpdateOpts struct {
+	// Version is the version of the package.
+	// The following values are valid: a specific version number and "latest".
+	Version string
+}
+
+// Read will retrieve information about an installed dnf package.
+func Read(client client.Client, pkgName string) (dnfPkg DnfPkg, err error) {
+	var eo utils.ExecOptions
+
+	client.Logger.Debugf("Reading package %s", pkgName)
+
+	// rpm exits with a status of 1 if the package is not installed.
+	eo.Args = []string{"rpm", "-q", "--qf", rpmQueryFormat, pkgName}
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		if execResult.ExitStatus == 1 {
+			err = resources.NotFoundError{Type: Type, Name: pkgName}
+		}
+		return
+	}
+
+	installedVersion := dnfPkgParseRpmQ(execResult.Stdout)[pkgName]
+	if installedVersion == "" {
+		err = resources.NotFoundError{Type: Type, Name: pkgName}
+		return
+	}
+
+	// dnf exits with a status of 1 if no package matches, such as when
+	// the package was installed from a repository which was removed.
+	eo.Args = []string{"dnf", "-q", "list", pkgName}
+	execResult, err = client.Exec(eo)
+	if err != nil && execResult.ExitStatus != 1 {
+		return
+	}
+	err = nil
+
+	latestVersion := dnfPkgParseDnfList(execResult.Stdout, pkgName)
+	if latestVersion == "" {
+		latestVersion = installedVersion
+	}
+
+	dnfPkg.Name = pkgName
+	dnfPkg.Version = installedVersion
+	dnfPkg.LatestVersion = latestVersion
+
+	return
+}
+
+// Exists will report if a given package exists on a system.
+func Exists(client client.Client, pkgName string) (exists bool, err error) {
+	client.Logger.Debugf("Checking if package %s exists", pkgName)
+
+	_, err = Read(client, pkgName)
+	if err != nil {
+		if _, ok := err.(resources.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	exists = true
+
+	return
+}
+
+// List will retrieve all rpm packages on a system.
+func List(client client.Client) (dnfPkgs []DnfPkg, err error) {
+	var eo utils.ExecOptions
+
+	client.Logger.Debugf("Listing all packages")
+
+	eo.Args = []string{"rpm", "-qa", "--qf", rpmQueryFormat}
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	pkgs := dnfPkgParseRpmQ(execResult.Stdout)
+	for k, v := range pkgs {
+		pkg := DnfPkg{
+			Name:    k,
+			Version: v,
+		}
+		dnfPkgs = append(dnfPkgs, pkg)
+	}
+
+	return
+}
+
+// Create will install a package via dnf.
+func Create(client client.Client, createOpts CreateOpts) (err error) {
+	client.Logger.Debugf("Installing package")
+
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("Package Create Options: %#v", createOpts)
+
+	return dnfPkgInstall(client, resources.ActionCreate, createOpts)
+}
+
+// Update will update a package via dnf.
+func Update(client client.Client, pkgName string, updateOpts UpdateOpts) (err error) {
+	client.Logger.Debugf("Upgrading package")
+
+	if err = utils.BuildRequest(&updateOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("Package Update Options: %#v", updateOpts)
+
+	createOpts := CreateOpts{
+		Name:    pkgName,
+		Version: updateOpts.Version,
+	}
+
+	return dnfPkgInstall(client, resources.ActionUpdate, createOpts)
+}
+
+// dnfPkgInstall is an internal function that will install a package
+// via dnf. The action is what is being done to the package.
+func dnfPkgInstall(client client.Client, action string, createOpts CreateOpts) (err error) {
+	var eo utils.ExecOptions
+
+	// dnf install upgrades or downgrades an installed package to a
+	// specific version, but does nothing for a package which is already
+	// installed without one.
+	command := "install"
+	pkg := createOpts.Name
+	switch createOpts.Version {
+	case "", "latest":
+		if action == resources.ActionUpdate {
+			command = "upgrade"
+		}
+	default:
+		pkg = fmt.Sprintf("%s-%s", createOpts.Name, createOpts.Version)
+	}
+
+	eo.Args = []string{"dnf", command, "-y", pkg}
+
+	if client.Pending(Type, createOpts.Name, action, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Delete will uninstall a package via dnf.
+func Delete(client client.Client, pkgName string) (err error) {
+	var eo utils.ExecOptions
+
+	client.Logger.Debugf("Deleting package %s", pkgName)
+
+	eo.Args = []string{"dnf", "remove", "-q", "-y", pkgName}
+	if client.Pending(Type, pkgName, resources.ActionDelete, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// dnfPkgParseRpmQ is an internal function that will parse the output of
+// rpm -q with rpmQueryFormat and return a list of packages and their
+// versions. If a package is installed for more than one architecture,
+// the first version is used.
+func dnfPkgParseRpmQ(stdout string) (pkgs map[string]string) {
+	pkgs = make(map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		v := strings.Fields(line)
+		if len(v) != 5 {
+			continue
+		}
+
+		if _, ok := pkgs[v[0]]; ok {
+			continue
+		}
+
+		version := fmt.Sprintf("%s-%s", v[2], v[3])
+		if v[1] != "(none)" && v[1] != "0" {
+			version = fmt.Sprintf("%s:%s", v[1], version)
+		}
+
+		pkgs[v[0]] = version
+	}
+
+	return
+}
+
+// dnfPkgParseDnfList is an internal function that will parse the output
+// of dnf list and return the latest version of a package. Only newer
+// versions than the installed one are listed as available, so the
+// installed version is returned if there is no newer version.
+func dnfPkgParseDnfList(stdout, pkgName string) (latest string) {
+	var installed, available bool
+	var fields []string
+
+	for _, line := range strings.Split(stdout, "\n") {
+		// The headings are capitalized differently by dnf 4 and 5.
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "installed packages":
+			installed, available = true, false
+			fields = nil
+			continue
+		case "available packages", "available upgrades":
+			installed, available = false, true
+			fields = nil
+			continue
+		}
+
+		if !installed && !available {
+			continue
+		}
+
+		// A long package name is wrapped onto its own line, so the
+		// name, version, and repository are collected across lines.
+		fields = append(fields, strings.Fields(line)...)
+		for len(fields) >= 3 {
+			name := fields[0]
+			if i := strings.LastIndex(name, "."); i > 0 {
+				name = name[:i]
+			}
+
+			if name == pkgName && (available || latest == "") {
+				latest = fields[1]
+			}
+
+			fields = fields[3:]
+		}
+	}
+
+	return
+}
+
+// dnfPkgVersionMatch is an internal function that will report if an
+// installed version is the desired version. A desired version without a
+// release matches any release, and one without an epoch matches any
+// epoch.
+func dnfPkgVersionMatch(installed, version string) bool {
+	if installed == version {
+		return true
+	}
+
+	if !strings.Contains(version, ":") {
+		if i := strings.Index(installed, ":"); i >= 0 {
+			installed = installed[i+1:]
+		}
+	}
+
+	if !strings.Contains(version, "-") {
+		if i := strings.LastIndex(installed, "-"); i >= 0 {
+			installed = installed[:i]
+		}
+	}
+
+	return installed == version
+}
+
+// Resource represents the desired state of a dnf package.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.Locker = Resource{}
+
+// Apply will converge a package to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the package.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the package.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the package is installed.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff will compare an installed package to the desired version.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	pkg, _ := current.(DnfPkg)
+
+	switch r.Version {
+	case "":
+	case "latest":
+		if pkg.Version != pkg.LatestVersion {
+			diffs = append(diffs, resources.Diff{Field: "Version", Old: pkg.Version, New: pkg.LatestVersion})
+		}
+	default:
+		if !dnfPkgVersionMatch(pkg.Version, r.Version) {
+			diffs = append(diffs, resources.Diff{Field: "Version", Old: pkg.Version, New: r.Version})
+		}
+	}
+
+	return
+}
+
+// Create will install the package.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will update the package to the desired version.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		Version: r.Version,
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will uninstall the package.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// LockName returns the dnf lock since rpm can only install one package
+// at a time.
+func (r Resource) LockName() string {
+	return "dnf"
+}
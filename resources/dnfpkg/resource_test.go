@@ -0,0 +1,169 @@
+package dnfpkg
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRpmQ = `sl (none) 5.02 1.el8 x86_64
+openssl-libs 1 1.1.1k 7.el8_6 x86_64
+openssl-libs 1 1.1.1k 7.el8_6 i686
+bash 0 4.4.20 4.el8_6 x86_64
+`
+
+const testDnfList = `Installed Packages
+openssl-libs.x86_64                 1:1.1.1k-7.el8_6                  @baseos
+Available Packages
+openssl-libs.i686                   1:1.1.1k-9.el8_7                  baseos
+openssl-libs.x86_64                 1:1.1.1k-9.el8_7                  baseos
+`
+
+const testDnfListWrapped = `Installed Packages
+python3-setuptools-wheel.noarch
+                              39.2.0-6.el8                 @System
+Available Packages
+python3-setuptools-wheel.noarch
+                              39.2.0-7.el8                 baseos
+`
+
+const testDnfListInstalled = `Installed packages
+sl.x86_64 5.02-1.el8 epel
+`
+
+func Test_dnfPkgParseRpmQ(t *testing.T) {
+	pkgs := dnfPkgParseRpmQ(testRpmQ)
+
+	expected := map[string]string{
+		"sl":           "5.02-1.el8",
+		"openssl-libs": "1:1.1.1k-7.el8_6",
+		"bash":         "4.4.20-4.el8_6",
+	}
+
+	assert.Equal(t, expected, pkgs, "should be equal")
+}
+
+func Test_dnfPkgParseDnfList(t *testing.T) {
+	tests := []struct {
+		stdout  string
+		pkgName string
+		latest  string
+	}{
+		{testDnfList, "openssl-libs", "1:1.1.1k-9.el8_7"},
+		{testDnfList, "openssl", ""},
+		{testDnfListWrapped, "python3-setuptools-wheel", "39.2.0-7.el8"},
+		{testDnfListInstalled, "sl", "5.02-1.el8"},
+		{"", "sl", ""},
+	}
+
+	for _, test := range tests {
+		latest := dnfPkgParseDnfList(test.stdout, test.pkgName)
+		assert.Equal(t, test.latest, latest, "should be equal")
+	}
+}
+
+func Test_dnfPkgVersionMatch(t *testing.T) {
+	tests := []struct {
+		installed string
+		version   string
+		match     bool
+	}{
+		{"5.02-1.el8", "5.02-1.el8", true},
+		{"5.02-1.el8", "5.02", true},
+		{"5.02-1.el8", "5.03", false},
+		{"1:1.1.1k-7.el8_6", "1.1.1k", true},
+		{"1:1.1.1k-7.el8_6", "1.1.1k-7.el8_6", true},
+		{"1:1.1.1k-7.el8_6", "2:1.1.1k-7.el8_6", false},
+		{"5.02-1.el8", "5.02-2.el8", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.match, dnfPkgVersionMatch(test.installed, test.version), "should be equal")
+	}
+}
+
+func Test_DnfPkg_Fake(t *testing.T) {
+	installed := ""
+
+	fake := executor.NewFake()
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch eo.Args[0] {
+		case "rpm":
+			if installed == "" {
+				er.Stdout = "package sl is not installed\n"
+				er.ExitStatus = 1
+				err = fmt.Errorf("%s: exit status 1", eo.String())
+				return
+			}
+			er.Stdout = fmt.Sprintf("sl (none) %s x86_64\n", installed)
+		case "dnf":
+			switch eo.Args[1] {
+			case "-q":
+				er.Stdout = "Available Packages\nsl.x86_64 5.02-2.el8 epel\n"
+			case "install":
+				installed = "5.02 1.el8"
+			case "upgrade":
+				installed = "5.02 2.el8"
+			}
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:    "sl",
+			Version: "5.02-1.el8",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "create", change.Action, "should be equal")
+	assert.Contains(t, fake.Commands, "dnf install -y sl-5.02-1.el8")
+
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Version = "latest"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "update", change.Action, "should be equal")
+	assert.Contains(t, fake.Commands, "dnf upgrade -y sl")
+
+	pkg, err := Read(c, "sl")
+	assert.Nil(t, err)
+	assert.Equal(t, DnfPkg{Name: "sl", Version: "5.02-2.el8", LatestVersion: "5.02-2.el8"}, pkg, "should be equal")
+}
+
+func Test_DnfPkg_Apply(t *testing.T) {
+	acc := os.Getenv("TEST_ACC")
+	if acc == "" {
+		t.Skip("TEST_ACC is not set. Skipping")
+	}
+
+	client := testhelper.TestClient()
+	pkgName := "sl"
+
+	createOpts := CreateOpts{
+		Name: "sl",
+	}
+
+	err := Create(client, createOpts)
+	assert.Nil(t, err)
+
+	exists, err := Exists(client, pkgName)
+	assert.Nil(t, err)
+	assert.Equal(t, true, exists, "should be equal")
+
+	err = Delete(client, pkgName)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,73 @@
+/*
+Package resources contains types shared by all resources.
+
+Each resource package provides a Resource type which implements the
+Resource interface. This allows a resource to be managed without knowing
+its type:
+
+	var rs = []resources.Resource{
+		useradd.Resource{
+			CreateOpts: useradd.CreateOpts{
+				Name: "foobar",
+			},
+		},
+		file.Resource{
+			CreateOpts: file.CreateOpts{
+				Name:    "/tmp/foo",
+				Owner:   "foobar",
+				Content: "Hello, World!\n",
+			},
+		},
+	}
+
+	for _, r := range rs {
+		change, err := resources.Apply(client, r)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s %s changed: %t\n", change.Type, change.Name, change.Changed())
+	}
+
+The returned Change lists the fields which were set or changed. For a file
+whose content changed, it also contains a unified diff of the content:
+
+	for _, diff := range change.Diffs {
+		fmt.Println(diff) // Mode: 0640 -> 0600
+	}
+
+	fmt.Print(change.ContentDiff)
+
+Apply creates a resource which does not exist, updates only the fields of
+an existing resource which differ from the desired state, and deletes a
+resource whose Ensure parameter is set to "absent":
+
+	r := useradd.Resource{
+		Meta: resources.Meta{
+			Ensure: resources.Absent,
+		},
+		CreateOpts: useradd.CreateOpts{
+			Name: "foobar",
+		},
+	}
+
+	change, err := useradd.Apply(client, r)
+
+To preview the changes which would be made, enable dry-run mode on the
+client. Resources will then log and record the changes in the client's
+plan instead of making them:
+
+	plan := &client.Plan{}
+	c := client.Client{
+		Logger: logger,
+		DryRun: true,
+		Plan:   plan,
+	}
+
+	change, err := resources.Apply(c, r)
+
+	for _, step := range plan.Steps() {
+		fmt.Printf("%s %s %s: %s\n", step.Action, step.Type, step.Name, step.Detail)
+	}
+*/
+package resources
@@ -0,0 +1,53 @@
+/*
+Package exec runs a command on a system.
+
+A command must be guarded so that it is only run when it needs to be. It
+is not run if it creates a file which already exists:
+
+	createOpts := exec.CreateOpts{
+		Name:    "unpack-app",
+		Command: "tar -xzf /tmp/app.tar.gz -C /srv",
+		Creates: "/srv/app",
+	}
+
+	err := exec.Create(client, createOpts)
+
+or if another command, which is run by sh -c, exits with a status of 0:
+
+	createOpts := exec.CreateOpts{
+		Name:    "migrate-app",
+		Command: "/srv/app/bin/migrate",
+		Unless:  "test -f /srv/app/.migrated",
+	}
+
+A command cannot be undone, so a command which should be absent is left
+alone.
+
+A command can also be a handler which only runs when a resource which
+notifies it has changed, such as reloading a service after its
+configuration was written:
+
+	rs := []resources.Resource{
+		file.Resource{
+			Meta: resources.Meta{
+				Notify: []string{"Exec[reload-nginx]"},
+			},
+			CreateOpts: file.CreateOpts{
+				Name:    "/etc/nginx/conf.d/app.conf",
+				Content: config,
+			},
+		},
+		exec.Resource{
+			CreateOpts: exec.CreateOpts{
+				Name:         "reload-nginx",
+				Command:      "nginx -s reload",
+				OnlyNotified: true,
+			},
+		},
+	}
+
+The handler runs once at the end of the run, however many resources
+notify it. A command which was run when it was applied is not run again
+as a handler in the same run.
+*/
+package exec
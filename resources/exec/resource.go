@@ -0,0 +1,214 @@
+package exec
+
+import (
+	"fmt"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/utils"
+)
+
+const Type = "Exec"
+
+// Exec represents a command which has been run.
+type Exec struct {
+	// Name is the name of the command.
+	Name string
+
+	// Command is the command.
+	Command string
+}
+
+// CreateOpts represents options used to run a command.
+type CreateOpts struct {
+	// Name is the name of the command, such as "reload-nginx".
+	Name string `required:"true"`
+
+	// Command is the command to run. It is run by sh -c.
+	Command string `required:"true"`
+
+	// Dir is the directory the command is run in.
+	Dir string
+
+	// Creates is a file which the command creates. If the file exists,
+	// the command is not run.
+	Creates string
+
+	// Unless is a command which is run by sh -c to check if the command
+	// needs to run. If it exits with a status of 0, the command is not
+	// run. It is also run in dry-run mode.
+	Unless string
+
+	// OnlyNotified means the command is only run when a resource which
+	// notifies it has changed.
+	OnlyNotified bool
+}
+
+// Exists will determine if a command does not need to be run. A command
+// must be guarded by Creates, Unless, or OnlyNotified, so that it is not
+// run every time it is applied.
+func Exists(client client.Client, createOpts CreateOpts) (exists bool, err error) {
+	client.Logger.Debugf("Checking if command %s needs to run", createOpts.Name)
+
+	if err = execGuarded(createOpts); err != nil {
+		return
+	}
+
+	if createOpts.OnlyNotified {
+		exists = true
+		return
+	}
+
+	if createOpts.Creates != "" {
+		if exists, err = executor.Exists(client.System(), createOpts.Creates); err != nil || exists {
+			return
+		}
+	}
+
+	if createOpts.Unless != "" {
+		var eo utils.ExecOptions
+		eo.Args = []string{"sh", "-c", createOpts.Unless}
+		eo.Dir = createOpts.Dir
+
+		// A status other than 0 means the command needs to run.
+		execResult, execErr := client.Exec(eo)
+		if execErr != nil && execResult.ExitStatus == 0 {
+			err = execErr
+			return
+		}
+
+		exists = execErr == nil
+	}
+
+	return
+}
+
+// Create will run a command.
+func Create(client client.Client, createOpts CreateOpts) (err error) {
+	client.Logger.Debugf("Running command")
+
+	return execRun(client, createOpts, resources.ActionCreate)
+}
+
+// Update is not implemented.
+func Update() (err error) {
+	return
+}
+
+// Delete is not implemented since a command cannot be undone.
+func Delete(client client.Client, name string) (err error) {
+	err = fmt.Errorf("Unable to delete Exec %s: a command cannot be undone", name)
+	return
+}
+
+// execRun is an internal function that will run a command as part of an
+// action.
+func execRun(client client.Client, createOpts CreateOpts, action string) (err error) {
+	var eo utils.ExecOptions
+
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("Exec Create Options: %#v", createOpts)
+
+	if err = execGuarded(createOpts); err != nil {
+		return
+	}
+
+	eo.Args = []string{"sh", "-c", createOpts.Command}
+	eo.Dir = createOpts.Dir
+	if client.Pending(Type, createOpts.Name, action, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// execGuarded is an internal function that will return an error if a
+// command would be run every time it is applied.
+func execGuarded(createOpts CreateOpts) error {
+	if createOpts.Creates == "" && createOpts.Unless == "" && !createOpts.OnlyNotified {
+		return fmt.Errorf("Exec %s must set Creates, Unless, or OnlyNotified", createOpts.Name)
+	}
+
+	return nil
+}
+
+// Resource represents a command which is run.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.Handler = Resource{}
+
+// Apply will run the command if it needs to be run.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the command.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will return the command, since a command has no state of its own.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Exec{Name: r.Name, Command: r.Command}, nil
+}
+
+// Exists will determine if the command does not need to be run. A
+// command which should be absent never exists, since a command cannot be
+// undone, so there is nothing to delete.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	if r.Ensure == resources.Absent {
+		return false, nil
+	}
+
+	return Exists(client, r.CreateOpts)
+}
+
+// Diff reports the command when it is run and nothing otherwise.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	if current == nil {
+		diffs = append(diffs, resources.Diff{Field: "Command", New: r.Command})
+	}
+
+	return
+}
+
+// Create will run the command.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update does nothing since a command which has run is up to date.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	return nil
+}
+
+// Delete will return an error since a command cannot be undone.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// Handle will run the command when the resource is notified.
+func (r Resource) Handle(client client.Client) error {
+	client.Logger.Debugf("Running command %s since it was notified", r.Name)
+
+	return execRun(client, r.CreateOpts, resources.ActionNotify)
+}
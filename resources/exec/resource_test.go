@@ -0,0 +1,120 @@
+package exec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Exec(t *testing.T) {
+	fake := executor.NewFake()
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:    "unpack-app",
+			Command: "tar -xzf /tmp/app.tar.gz -C /srv",
+			Creates: "/srv/app",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, resources.ActionCreate, change.Action, "should be equal")
+	assert.Equal(t, []string{"sh -c 'tar -xzf /tmp/app.tar.gz -C /srv'"}, fake.Commands, "should be equal")
+
+	fake.Mkdir("/srv/app", 0755, true)
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+	assert.Equal(t, 1, len(fake.Commands), "should be equal")
+}
+
+func Test_Exec_Handle(t *testing.T) {
+	fake := executor.NewFake()
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:         "reload-nginx",
+			Command:      "nginx -s reload",
+			OnlyNotified: true,
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+	assert.Equal(t, 0, len(fake.Commands), "should be equal")
+
+	c.DryRun = true
+	c.Plan = &client.Plan{}
+	err = r.Handle(c)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(fake.Commands), "should be equal")
+	assert.Equal(t, resources.ActionNotify, c.Plan.Steps()[0].Action, "should be equal")
+
+	c.DryRun = false
+	err = r.Handle(c)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sh -c 'nginx -s reload'"}, fake.Commands, "should be equal")
+}
+
+func Test_Exec_Guard(t *testing.T) {
+	var status int
+
+	fake := executor.NewFake()
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		if eo.Args[2] == "test -f /srv/app/.migrated" && status != 0 {
+			er.ExitStatus = status
+			err = fmt.Errorf("exit status %d", status)
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:    "migrate",
+			Command: "/srv/app/migrate",
+		},
+	}
+
+	_, err := Apply(c, r)
+	assert.Equal(t, "Exec migrate must set Creates, Unless, or OnlyNotified", err.Error(), "should be equal")
+	assert.Equal(t, 0, len(fake.Commands), "should be equal")
+
+	r.Unless = "test -f /srv/app/.migrated"
+	status = 1
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, resources.ActionCreate, change.Action, "should be equal")
+	assert.Equal(t, "sh -c /srv/app/migrate", fake.Commands[len(fake.Commands)-1], "should be equal")
+
+	fake.Commands = nil
+	status = 0
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+	assert.Equal(t, []string{"sh -c 'test -f /srv/app/.migrated'"}, fake.Commands, "should be equal")
+
+	// A command cannot be undone, so absent is left alone.
+	fake.Commands = nil
+	r.Ensure = resources.Absent
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+	assert.Equal(t, 0, len(fake.Commands), "should be equal")
+}
@@ -17,6 +17,68 @@ To create a file:
 
 	err := file.Create(client, CreateOpts)
 
+To create a file from a template, which can use variables and the facts
+of the system:
+
+	createOpts := file.CreateOpts{
+		Name:     "/etc/app.conf",
+		Template: "listen {{ .Vars.port }}\nrelease {{ .Facts.OS.Codename }}\n",
+		Vars: map[string]interface{}{
+			"port": 8080,
+		},
+	}
+
+	err := file.Create(client, createOpts)
+
+Templates use text/template with additional functions such as join,
+indent, default, and toJSON. An error in a template reports its line:
+
+	/etc/app.conf:1: <.Vars.port>: map has no entry for key "port"
+
+To copy a file from a URL, a path on the system being managed, or an fs.FS
+such as an embed.FS, and only write it if its SHA-256 checksum matches:
+
+	createOpts := file.CreateOpts{
+		Name:   "/usr/local/bin/app",
+		Mode:   "0755",
+		Source: "https://example.com/app-1.0.0-linux-amd64",
+		SHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+
+	err := file.Create(client, createOpts)
+
+	//go:embed files
+	var bundle embed.FS
+
+	createOpts = file.CreateOpts{
+		Name:   "/etc/app.conf",
+		Source: "files/app.conf",
+		FS:     bundle,
+	}
+
+A file on the system running craft is only copied with LocalSource:
+
+	createOpts = file.CreateOpts{
+		Name:        "/etc/app.conf",
+		LocalSource: "files/app.conf",
+	}
+
+In dry-run mode, a URL is downloaded for at most 30 seconds, so that a plan
+does not wait on a slow download.
+
+To check a file with a command before it replaces the existing file, so
+that a broken configuration is never written:
+
+	createOpts := file.CreateOpts{
+		Name:        "/etc/sudoers.d/deploy",
+		Mode:        "0440",
+		Content:     "deploy ALL=(ALL) NOPASSWD: ALL\n",
+		ValidateCmd: "visudo -cf %s",
+	}
+
+The command is run by sh -c, and the %s is replaced with the quoted name of
+a staged copy of the file.
+
 To update a file:
 
 	updateOpts := file.UpdateOpts{
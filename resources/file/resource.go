@@ -1,14 +1,26 @@
 package file
 
 import (
+	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
 	"fmt"
-	"io"
+	"io/fs"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/facts"
 	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/directory"
+	"github.com/jtopjian/craft/resources/groupadd"
+	"github.com/jtopjian/craft/resources/useradd"
 	"github.com/jtopjian/craft/utils"
 )
 
@@ -48,6 +60,39 @@ type CreateOpts struct {
 
 	// Content is the file contents.
 	Content string
+
+	// Template is a text/template which is rendered to produce the file
+	// contents. It cannot be used with Content. See Render for the data
+	// available to the template.
+	Template string
+
+	// Vars are variables which are available to the template.
+	Vars map[string]interface{}
+
+	// Source is where the file contents are copied from. It cannot be
+	// used with Content, Template, or LocalSource. It is either an
+	// http:// or https:// URL, or the name of a file on the system being
+	// managed. If FS is set, Source is the name of a file in FS.
+	Source string
+
+	// FS is a filesystem, such as an embed.FS, which Source is read from.
+	FS fs.FS `manifest:"-"`
+
+	// LocalSource is the name of a file on the system running craft,
+	// which may differ from the system being managed, which the file
+	// contents are copied from. It cannot be used with Content, Template,
+	// or Source.
+	LocalSource string
+
+	// SHA256 is the expected SHA-256 checksum of the file contents in
+	// hex. If it is set, the file is only written if the contents match.
+	SHA256 string
+
+	// ValidateCmd is a command which checks the file contents before they
+	// are written, such as "visudo -cf %s". The %s is replaced with the
+	// name of a staged copy of the file. If the command fails, the file
+	// is left untouched.
+	ValidateCmd string
 }
 
 // UpdateOpts represents options used to update a file on a system.
@@ -63,60 +108,112 @@ type UpdateOpts struct {
 
 	// Content is the file contents.
 	Content string
+
+	// Template is a text/template which is rendered to produce the file
+	// contents. It cannot be used with Content.
+	Template string
+
+	// Vars are variables which are available to the template.
+	Vars map[string]interface{}
+
+	// Source is where the file contents are copied from. See CreateOpts
+	// for the supported sources.
+	Source string
+
+	// FS is a filesystem which Source is read from.
+	FS fs.FS
+
+	// LocalSource is the name of a file on the system running craft
+	// which the file contents are copied from.
+	LocalSource string
+
+	// SHA256 is the expected SHA-256 checksum of the file contents.
+	SHA256 string
+
+	// ValidateCmd is a command which checks the file contents before they
+	// are written. See CreateOpts for details.
+	ValidateCmd string
 }
 
-// Read will read an existing file on a system.
-func Read(client client.Client, fileName string) (file File, err error) {
-	client.Logger.Debugf("Reading file %s", fileName)
+// fileContentOpts is an internal type that holds the options which
+// determine the contents of a file.
+type fileContentOpts struct {
+	Content     string
+	Template    string
+	Vars        map[string]interface{}
+	Source      string
+	FS          fs.FS
+	LocalSource string
+	SHA256      string
+}
 
-	fi, err := os.Stat(fileName)
-	if err != nil {
-		if os.IsNotExist(err) {
-			err = resources.NotFoundError{Type: Type, Name: fileName}
-		}
-		return
-	}
+// TemplateData is the data which a template is rendered with.
+type TemplateData struct {
+	// Vars are the variables of the file.
+	Vars map[string]interface{}
 
-	mode := fi.Mode()
-	if !mode.IsRegular() {
-		err = fmt.Errorf("%s is not a file", fileName)
-		return
+	// Facts are the facts of the system. They are only gathered if the
+	// template refers to them.
+	Facts facts.Facts
+}
+
+// Render will render the template of a file. The template can refer to
+// the variables of the file as .Vars and to the facts of the system as
+// .Facts, such as {{ .Facts.OS.Codename }}. The functions of
+// utils.TemplateFuncs are also available.
+//
+// A utils.TemplateError, which includes the line of the template, is
+// returned if the template is invalid.
+func Render(client client.Client, fileName, template string, vars map[string]interface{}) (content string, err error) {
+	client.Logger.Debugf("Rendering template of file %s", fileName)
+
+	data := TemplateData{
+		Vars: vars,
 	}
 
-	uid, gid, err := utils.GetFileOwner(fileName)
-	if err != nil {
-		return
+	if data.Vars == nil {
+		data.Vars = make(map[string]interface{})
 	}
 
-	owner, err := utils.UIDToName(uid)
-	if err != nil {
-		return
+	if strings.Contains(template, ".Facts") {
+		data.Facts, err = facts.Gather(client)
+		if err != nil {
+			return
+		}
 	}
 
-	group, err := utils.GIDToName(gid)
+	return utils.RenderTemplate(fileName, template, data)
+}
+
+// Read will read an existing file on a system.
+func Read(client client.Client, fileName string) (file File, err error) {
+	client.Logger.Debugf("Reading file %s", fileName)
+
+	system := client.System()
+
+	fi, err := system.Stat(fileName)
 	if err != nil {
+		if os.IsNotExist(err) {
+			err = resources.NotFoundError{Type: Type, Name: fileName}
+		}
 		return
 	}
 
-	var f *os.File
-	f, err = os.Open(fileName)
-	if err != nil {
+	if !fi.IsRegular() {
+		err = fmt.Errorf("%s is not a file", fileName)
 		return
 	}
-	defer f.Close()
 
-	h := md5.New()
-	_, err = io.Copy(h, f)
+	content, err := system.ReadFile(fileName)
 	if err != nil {
 		return
 	}
-	fileSum := string(h.Sum(nil))
 
 	file.Name = fileName
-	file.Owner = owner
-	file.Group = group
-	file.Mode = mode.String()
-	file.MD5 = fileSum
+	file.Owner = fi.Owner
+	file.Group = fi.Group
+	file.Mode = utils.ModeToString(fi.Mode)
+	file.MD5 = fmt.Sprintf("%x", md5.Sum(content))
 
 	return
 }
@@ -150,34 +247,43 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 
 	client.Logger.Debugf("File Create Options: %#v", createOpts)
 
+	createOpts.Content, err = fileContent(client, createOpts.Name, fileContentOpts{
+		Content:     createOpts.Content,
+		Template:    createOpts.Template,
+		Vars:        createOpts.Vars,
+		Source:      createOpts.Source,
+		FS:          createOpts.FS,
+		LocalSource: createOpts.LocalSource,
+		SHA256:      createOpts.SHA256,
+	})
+	if err != nil {
+		return
+	}
+
 	mode, err := utils.StringToMode(createOpts.Mode)
 	if err != nil {
 		return
 	}
 
-	if createOpts.Content == "" {
-		var f *os.File
-		f, err = os.OpenFile(createOpts.Name, os.O_RDONLY|os.O_CREATE, mode)
-		if err != nil {
-			return
-		}
-		err = f.Close()
-		if err != nil {
-			return
-		}
-	} else {
-		err = ioutil.WriteFile(createOpts.Name, []byte(createOpts.Content), mode)
-		if err != nil {
-			return
-		}
+	detail := fmt.Sprintf("create %s (%d bytes) with owner %s, group %s and mode %s",
+		createOpts.Name, len(createOpts.Content), createOpts.Owner, createOpts.Group, createOpts.Mode)
+	if client.Pending(Type, createOpts.Name, resources.ActionCreate, detail) {
+		return
 	}
 
-	uid, gid, err := utils.GetUIDGID(createOpts.Owner, createOpts.Group)
+	system := client.System()
+
+	writeOpts := executor.WriteOpts{
+		Mode:        mode,
+		ValidateCmd: createOpts.ValidateCmd,
+	}
+
+	err = client.WriteFile(createOpts.Name, []byte(createOpts.Content), writeOpts)
 	if err != nil {
 		return
 	}
 
-	err = os.Chown(createOpts.Name, uid, gid)
+	err = system.Chown(createOpts.Name, createOpts.Owner, createOpts.Group, false)
 	if err != nil {
 		return
 	}
@@ -195,59 +301,70 @@ func Update(client client.Client, fileName string, updateOpts UpdateOpts) (err e
 
 	client.Logger.Debugf("File Update Options: %#v", updateOpts)
 
+	updateOpts.Content, err = fileContent(client, fileName, fileContentOpts{
+		Content:     updateOpts.Content,
+		Template:    updateOpts.Template,
+		Vars:        updateOpts.Vars,
+		Source:      updateOpts.Source,
+		FS:          updateOpts.FS,
+		LocalSource: updateOpts.LocalSource,
+		SHA256:      updateOpts.SHA256,
+	})
+	if err != nil {
+		return
+	}
+
+	var mode os.FileMode
 	if updateOpts.Mode != "" {
-		var mode os.FileMode
 		mode, err = utils.StringToMode(updateOpts.Mode)
 		if err != nil {
 			return
 		}
-
-		err = os.Chmod(fileName, mode)
-		if err != nil {
-			return
-		}
 	}
 
-	if updateOpts.Content != "" {
-		var fi os.FileInfo
-		fi, err = os.Stat(fileName)
-		if os.IsNotExist(err) {
-			err = nil
-			return
-		}
+	system := client.System()
 
-		mode := fi.Mode()
-		err = ioutil.WriteFile(fileName, []byte(updateOpts.Content), mode)
+	if updateOpts.Content != "" {
+		var fi executor.FileInfo
+		// The file may have been removed since it was read.
+		fi, err = system.Stat(fileName)
 		if err != nil {
 			return
 		}
-	}
 
-	if updateOpts.Owner != "" || updateOpts.Group != "" {
-		var uid int
-		var gid int
-		uid, gid, err = utils.GetFileOwner(fileName)
-		if err != nil {
-			return
-		}
+		detail := fmt.Sprintf("write %d bytes to %s", len(updateOpts.Content), fileName)
+		if !client.Pending(Type, fileName, resources.ActionUpdate, detail) {
+			writeOpts := executor.WriteOpts{
+				Mode:        fi.Mode,
+				ValidateCmd: updateOpts.ValidateCmd,
+			}
 
-		if updateOpts.Owner != "" {
-			uid, err = utils.UsernameToID(updateOpts.Owner)
+			err = client.WriteFile(fileName, []byte(updateOpts.Content), writeOpts)
 			if err != nil {
 				return
 			}
 		}
+	}
 
-		if updateOpts.Group != "" {
-			gid, err = utils.GroupToID(updateOpts.Group)
+	// The mode is changed once the new content was validated and written,
+	// so that a file which fails validation is left untouched.
+	if updateOpts.Mode != "" {
+		detail := fmt.Sprintf("chmod %s %s", updateOpts.Mode, fileName)
+		if !client.Pending(Type, fileName, resources.ActionUpdate, detail) {
+			err = system.Chmod(fileName, mode, false)
 			if err != nil {
 				return
 			}
 		}
+	}
 
-		err = os.Chown(fileName, uid, gid)
-		if err != nil {
-			return
+	if updateOpts.Owner != "" || updateOpts.Group != "" {
+		detail := fmt.Sprintf("chown %s:%s %s", updateOpts.Owner, updateOpts.Group, fileName)
+		if !client.Pending(Type, fileName, resources.ActionUpdate, detail) {
+			err = system.Chown(fileName, updateOpts.Owner, updateOpts.Group, false)
+			if err != nil {
+				return
+			}
 		}
 	}
 
@@ -258,10 +375,278 @@ func Update(client client.Client, fileName string, updateOpts UpdateOpts) (err e
 func Delete(client client.Client, fileName string) (err error) {
 	client.Logger.Debugf("Deleting file %s", fileName)
 
-	err = os.Remove(fileName)
+	if client.Pending(Type, fileName, resources.ActionDelete, fmt.Sprintf("rm %s", fileName)) {
+		return
+	}
+
+	err = client.System().Remove(fileName, false)
 	if err != nil {
 		return
 	}
 
 	return
 }
+
+// fileContent is an internal function that will return the contents of
+// a file, rendering its template or reading its source if it has one.
+// The contents are verified against the expected checksum, if any.
+func fileContent(client client.Client, fileName string, opts fileContentOpts) (content string, err error) {
+	var set []string
+	for name, v := range map[string]string{"Content": opts.Content, "Template": opts.Template, "Source": opts.Source, "LocalSource": opts.LocalSource} {
+		if v != "" {
+			set = append(set, name)
+		}
+	}
+
+	if len(set) > 1 {
+		sort.Strings(set)
+		err = fmt.Errorf("Only one of %s of file %s can be set", strings.Join(set, " and "), fileName)
+		return
+	}
+
+	switch {
+	case opts.Template != "":
+		content, err = Render(client, fileName, opts.Template, opts.Vars)
+	case opts.Source != "":
+		var data []byte
+		data, err = fileReadSource(client, opts.FS, opts.Source)
+		content = string(data)
+	case opts.LocalSource != "":
+		var data []byte
+		data, err = ioutil.ReadFile(opts.LocalSource)
+		content = string(data)
+	default:
+		content = opts.Content
+	}
+
+	if err != nil || opts.SHA256 == "" {
+		return
+	}
+
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	if !strings.EqualFold(sum, opts.SHA256) {
+		err = fmt.Errorf("Checksum mismatch for file %s: expected %s, got %s", fileName, opts.SHA256, sum)
+		content = ""
+	}
+
+	return
+}
+
+// fileSourceTimeout limits how long a source is downloaded for.
+const fileSourceTimeout = 5 * time.Minute
+
+// fileSourceDryRunTimeout limits how long a source is downloaded for in
+// dry-run mode, so that a plan does not wait on a slow download.
+const fileSourceDryRunTimeout = 30 * time.Second
+
+// fileReadSource is an internal function that will read the contents of
+// a source from a filesystem, a URL, or the system being managed.
+func fileReadSource(client client.Client, fsys fs.FS, source string) (data []byte, err error) {
+	client.Logger.Debugf("Reading file source %s", source)
+
+	if fsys != nil {
+		return fs.ReadFile(fsys, source)
+	}
+
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return client.System().ReadFile(source)
+	}
+
+	timeout := fileSourceTimeout
+	if client.DryRun {
+		timeout = fileSourceDryRunTimeout
+	}
+
+	httpClient := &http.Client{Timeout: timeout}
+	res, err := httpClient.Get(source)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("Unable to download %s: %s", source, res.Status)
+		return
+	}
+
+	return ioutil.ReadAll(res.Body)
+}
+
+// Resource represents the desired state of a file.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
+var _ resources.Preparer = Resource{}
+
+// Apply will converge a file to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the file.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the file.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the file exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff will compare an existing file to the desired state.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	file, _ := current.(File)
+
+	desired := r.CreateOpts
+	if invalid := resources.Desired(&desired); invalid != nil {
+		return invalid
+	}
+
+	if desired.Owner != "" && desired.Owner != file.Owner {
+		diffs = append(diffs, resources.Diff{Field: "Owner", Old: file.Owner, New: desired.Owner})
+	}
+
+	if desired.Group != "" && desired.Group != file.Group {
+		diffs = append(diffs, resources.Diff{Field: "Group", Old: file.Group, New: desired.Group})
+	}
+
+	if desired.Mode != "" {
+		if mode, err := utils.StringToMode(desired.Mode); err == nil {
+			if v := utils.ModeToString(mode); v != file.Mode {
+				diffs = append(diffs, resources.Diff{Field: "Mode", Old: file.Mode, New: v})
+			}
+		}
+	}
+
+	if desired.Content != "" {
+		sum := fmt.Sprintf("%x", md5.Sum([]byte(desired.Content)))
+		if sum != file.MD5 {
+			diffs = append(diffs, resources.Diff{Field: "Content", Old: file.MD5, New: sum})
+		}
+	}
+
+	return
+}
+
+// Create will create the file.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will update the differing fields of the file.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		ValidateCmd: r.ValidateCmd,
+	}
+
+	desired := r.CreateOpts
+	if err := utils.BuildRequest(&desired); err != nil {
+		return err
+	}
+
+	for _, diff := range diffs {
+		switch diff.Field {
+		case "Owner":
+			updateOpts.Owner = desired.Owner
+		case "Group":
+			updateOpts.Group = desired.Group
+		case "Mode":
+			updateOpts.Mode = desired.Mode
+		case "Content":
+			updateOpts.Content = desired.Content
+		}
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will delete the file.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// ContentDiff will return a unified diff between the current content of
+// the file and the desired content.
+func (r Resource) ContentDiff(client client.Client) (diff string, err error) {
+	if r.Content == "" {
+		return
+	}
+
+	var current []byte
+	current, err = client.System().ReadFile(r.Name)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+		err = nil
+	}
+
+	// Binary files, such as those copied from a source, are not shown.
+	if strings.ContainsRune(r.Content, 0) || bytes.IndexByte(current, 0) >= 0 {
+		if string(current) != r.Content {
+			diff = fmt.Sprintf("Binary file %s differs\n", r.Name)
+		}
+		return
+	}
+
+	diff = utils.UnifiedDiff(r.Name, r.Name, string(current), r.Content)
+	return
+}
+
+// Prepare will render the template or read the source of the file, if
+// any, so that the result is compared to the current content.
+func (r Resource) Prepare(client client.Client) (resources.Resource, error) {
+	content, err := fileContent(client, r.Name, fileContentOpts{
+		Content:     r.Content,
+		Template:    r.Template,
+		Vars:        r.Vars,
+		Source:      r.Source,
+		FS:          r.FS,
+		LocalSource: r.LocalSource,
+		SHA256:      r.SHA256,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.Content = content
+	r.Template = ""
+	r.Vars = nil
+	r.Source = ""
+	r.FS = nil
+	r.LocalSource = ""
+	r.SHA256 = ""
+
+	return r, nil
+}
+
+// AutoRequires returns the owner, group, and parent directory of the file
+// so that they are applied before the file when they are managed.
+func (r Resource) AutoRequires() (refs []resources.Ref) {
+	refs = append(refs, resources.Ref{Type: directory.Type, ID: path.Dir(r.Name)})
+
+	if r.Owner != "" {
+		refs = append(refs, resources.Ref{Type: useradd.Type, ID: r.Owner})
+	}
+
+	if r.Group != "" {
+		refs = append(refs, resources.Ref{Type: groupadd.Type, ID: r.Group})
+	}
+
+	return
+}
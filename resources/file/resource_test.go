@@ -1,10 +1,22 @@
 package file
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
 	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,3 +64,261 @@ func Test_File_Apply(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, false, exists, "should be equal")
 }
+
+func Test_File_DryRun(t *testing.T) {
+	c := testhelper.TestClient()
+	c.DryRun = true
+	c.Plan = &client.Plan{}
+
+	fileName := filepath.Join(os.TempDir(), "craft-dry-run.txt")
+
+	createOpts := CreateOpts{
+		Name:    fileName,
+		Content: "Hello, World!\n",
+	}
+
+	err := Create(c, createOpts)
+	assert.Nil(t, err)
+
+	_, err = os.Stat(fileName)
+	assert.True(t, os.IsNotExist(err), "file should not exist")
+
+	assert.Equal(t, 1, len(c.Plan.Steps()), "should be equal")
+	assert.Equal(t, Type, c.Plan.Steps()[0].Type, "should be equal")
+	assert.Equal(t, fileName, c.Plan.Steps()[0].Name, "should be equal")
+	assert.Equal(t, "create", c.Plan.Steps()[0].Action, "should be equal")
+}
+
+func Test_File_ApplyChange(t *testing.T) {
+	acc := os.Getenv("TEST_ACC")
+	if acc == "" {
+		t.Skip("TEST_ACC is not set. Skipping")
+	}
+
+	c := testhelper.TestClient()
+
+	fileName := filepath.Join(os.TempDir(), "craft-change.txt")
+	defer os.Remove(fileName)
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:    fileName,
+			Mode:    "0640",
+			Content: "foo\n",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "create", change.Action, "should be equal")
+	assert.Contains(t, change.Diffs, resources.Diff{Field: "Mode", Old: "", New: "0640"})
+	assert.Contains(t, change.ContentDiff, "+foo\n")
+
+	r.Mode = "0600"
+	r.Content = "bar\n"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "update", change.Action, "should be equal")
+	assert.Contains(t, change.Diffs, resources.Diff{Field: "Mode", Old: "0640", New: "0600"})
+	assert.Contains(t, change.ContentDiff, "-foo\n+bar\n")
+}
+
+func Test_File_Fake(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/foo", "foo\n", 0644)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:    "/etc/foo",
+			Owner:   "nobody",
+			Mode:    "0600",
+			Content: "bar\n",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "update", change.Action, "should be equal")
+	assert.Equal(t, "bar\n", string(fake.Files["/etc/foo"].Content), "should be equal")
+	assert.Equal(t, os.FileMode(0600), fake.Files["/etc/foo"].Mode, "should be equal")
+	assert.Equal(t, "nobody", fake.Files["/etc/foo"].Owner, "should be equal")
+
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	// A file which was removed since it was read is not reported as
+	// updated.
+	delete(fake.Files, "/etc/foo")
+	err = r.Update(c, []resources.Diff{{Field: "Content"}})
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_File_Template(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/os-release", "ID=ubuntu\nVERSION_CODENAME=jammy\n", 0644)
+	fake.Mkdir("/etc/app", 0755, true)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:     "/etc/app/app.conf",
+			Template: "release = {{ .Facts.OS.Codename }}\nport = {{ .Vars.port }}\n",
+			Vars: map[string]interface{}{
+				"port": 8080,
+			},
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "create", change.Action, "should be equal")
+	assert.Contains(t, change.ContentDiff, "+port = 8080\n")
+	assert.Equal(t, "release = jammy\nport = 8080\n", string(fake.Files["/etc/app/app.conf"].Content), "should be equal")
+
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Template = "release = {{ .Facts.OS.Codename }}\nport = {{ .Vars.prot }}\n"
+	_, err = Apply(c, r)
+	assert.Equal(t, `/etc/app/app.conf:2: <.Vars.prot>: map has no entry for key "prot"`, err.Error(), "should be equal")
+
+	r.Content = "port = 8080\n"
+	_, err = Apply(c, r)
+	assert.Equal(t, "Only one of Content and Template of file /etc/app/app.conf can be set", err.Error(), "should be equal")
+}
+
+func Test_File_Source(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app.conf" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "from http\n")
+	}))
+	defer server.Close()
+
+	localFile := filepath.Join(t.TempDir(), "app.conf")
+	err := ioutil.WriteFile(localFile, []byte("from local\n"), 0644)
+	assert.Nil(t, err)
+
+	bundle := fstest.MapFS{
+		"files/app.conf": {Data: []byte("from bundle\n")},
+	}
+
+	tests := []struct {
+		createOpts CreateOpts
+		content    string
+	}{
+		{CreateOpts{Source: server.URL + "/app.conf"}, "from http\n"},
+		{CreateOpts{Source: "/srv/app.conf"}, "from managed system\n"},
+		{CreateOpts{LocalSource: localFile}, "from local\n"},
+		{CreateOpts{Source: "files/app.conf", FS: bundle}, "from bundle\n"},
+	}
+
+	for _, test := range tests {
+		fake := executor.NewFake()
+		fake.Mkdir("/etc", 0755, true)
+		fake.AddFile("/srv/app.conf", "from managed system\n", 0644)
+
+		c := testhelper.TestClient()
+		c.Executor = fake
+
+		r := Resource{CreateOpts: test.createOpts}
+		r.Name = "/etc/app.conf"
+		r.SHA256 = fmt.Sprintf("%x", sha256.Sum256([]byte(test.content)))
+
+		change, err := Apply(c, r)
+		assert.Nil(t, err)
+		assert.Equal(t, "create", change.Action, "should be equal")
+		assert.Equal(t, test.content, string(fake.Files["/etc/app.conf"].Content), "should be equal")
+	}
+
+	_, err = fileReadSource(testhelper.TestClient(), nil, server.URL+"/missing")
+	assert.Equal(t, fmt.Sprintf("Unable to download %s/missing: 404 Not Found", server.URL), err.Error(), "should be equal")
+}
+
+func Test_File_SourceChecksum(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/usr/local/bin/app", "old\x00", 0755)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	bundle := fstest.MapFS{
+		"app": {Data: []byte("new\x00")},
+	}
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:   "/usr/local/bin/app",
+			Mode:   "0755",
+			Source: "app",
+			FS:     bundle,
+			SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte("other"))),
+		},
+	}
+
+	_, err := Apply(c, r)
+	assert.Contains(t, err.Error(), "Checksum mismatch for file /usr/local/bin/app")
+	assert.Equal(t, "old\x00", string(fake.Files["/usr/local/bin/app"].Content), "should be equal")
+
+	r.SHA256 = fmt.Sprintf("%x", sha256.Sum256([]byte("new\x00")))
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "Binary file /usr/local/bin/app differs\n", change.ContentDiff, "should be equal")
+	assert.Equal(t, "new\x00", string(fake.Files["/usr/local/bin/app"].Content), "should be equal")
+}
+
+func Test_File_Validate(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/sudoers", "root ALL=(ALL) ALL\n", 0440)
+
+	var staged string
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		args := strings.Fields(eo.Args[2])
+		staged = args[len(args)-1]
+		content, _ := fake.ReadFile(staged)
+		if strings.Contains(string(content), "(ALL ALL") {
+			er.ExitStatus = 1
+			er.Stderr = "syntax error near line 1"
+			err = fmt.Errorf("%s: exit status 1: %s", eo.String(), er.Stderr)
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:        "/etc/sudoers",
+			Mode:        "0400",
+			Content:     "root ALL=(ALL ALL\n",
+			ValidateCmd: "visudo -cf %s",
+		},
+	}
+
+	_, err := Apply(c, r)
+	assert.Contains(t, err.Error(), "Validation of /etc/sudoers failed")
+	assert.Contains(t, err.Error(), "syntax error near line 1")
+	assert.Equal(t, "root ALL=(ALL) ALL\n", string(fake.Files["/etc/sudoers"].Content), "should be equal")
+	assert.Equal(t, os.FileMode(0440), fake.Files["/etc/sudoers"].Mode, "should be equal")
+	assert.True(t, strings.HasPrefix(staged, "/etc/.sudoers.craft-validate-"))
+
+	// The staged copy is removed.
+	_, ok := fake.Files[staged]
+	assert.False(t, ok)
+
+	r.Content = "root ALL=(ALL) NOPASSWD: ALL\n"
+	_, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "root ALL=(ALL) NOPASSWD: ALL\n", string(fake.Files["/etc/sudoers"].Content), "should be equal")
+	assert.Equal(t, os.FileMode(0400), fake.Files["/etc/sudoers"].Mode, "should be equal")
+}
@@ -1,11 +1,14 @@
 package fileini
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/go-ini/ini"
 	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
 	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/file"
 	"github.com/jtopjian/craft/utils"
 )
 
@@ -90,7 +93,7 @@ func Read(client client.Client, getOpts GetOpts) (entry FileIni, err error) {
 
 	client.Logger.Debugf("FileIni Read Options: %#v", getOpts)
 
-	cfg, err := ini.LoadSources(ini.LoadOptions{AllowBooleanKeys: true}, getOpts.FileName)
+	cfg, err := fileIniLoad(client, getOpts.FileName)
 	if err != nil {
 		return
 	}
@@ -157,11 +160,87 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 		return
 	}
 
-	cfg, err := ini.LoadSources(ini.LoadOptions{AllowBooleanKeys: true}, createOpts.FileName)
+	client.Logger.Debugf("FileIni Create Options: %#v", createOpts)
+
+	return fileIniSetKey(client, resources.ActionCreate, createOpts)
+}
+
+// Update will update an existing file ini entry.
+func Update(client client.Client, updateOpts UpdateOpts) (err error) {
+	client.Logger.Debugf("Updating FileIni entry")
+
+	if err = utils.BuildRequest(&updateOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("FileIni Update Options: %#v", updateOpts)
+
+	exists, err := Exists(client, updateOpts.FileName, updateOpts.Section, updateOpts.Key)
 	if err != nil {
 		return
 	}
 
+	if !exists {
+		err = fmt.Errorf("FileIni entry does not exist")
+		return
+	}
+
+	createOpts := CreateOpts{
+		FileName: updateOpts.FileName,
+		Section:  updateOpts.Section,
+		Key:      updateOpts.Key,
+		Value:    updateOpts.Value,
+	}
+
+	return fileIniSetKey(client, resources.ActionUpdate, createOpts)
+}
+
+func Delete(client client.Client, deleteOpts DeleteOpts) (err error) {
+	client.Logger.Debugf("Deleting FileIni entry")
+
+	if err = utils.BuildRequest(&deleteOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("FileIni Delete Options: %#v", deleteOpts)
+
+	cfg, err := fileIniLoad(client, deleteOpts.FileName)
+	if err != nil {
+		return
+	}
+
+	resourceTitle := fmt.Sprintf("%s/%s/%s", deleteOpts.FileName, deleteOpts.Section, deleteOpts.Key)
+	detail := fmt.Sprintf("remove key %s from section [%s] of %s",
+		deleteOpts.Key, deleteOpts.Section, deleteOpts.FileName)
+	if client.Pending(Type, resourceTitle, resources.ActionDelete, detail) {
+		return
+	}
+
+	cfg.Section(deleteOpts.Section).DeleteKey(deleteOpts.Key)
+	err = fileIniSave(client, cfg, deleteOpts.FileName)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// fileIniSetKey is an internal function that will set the value of a key
+// in an ini file, creating the section and key if needed. The action is
+// what is being done to the entry.
+func fileIniSetKey(client client.Client, action string, createOpts CreateOpts) (err error) {
+	cfg, err := fileIniLoad(client, createOpts.FileName)
+	if err != nil {
+		return
+	}
+
+	resourceTitle := fmt.Sprintf("%s/%s/%s", createOpts.FileName, createOpts.Section, createOpts.Key)
+	detail := fmt.Sprintf("set %s = %s in section [%s] of %s",
+		createOpts.Key, createOpts.Value, createOpts.Section, createOpts.FileName)
+	if client.Pending(Type, resourceTitle, action, detail) {
+		return
+	}
+
 	section, err := cfg.GetSection(createOpts.Section)
 	if err != nil {
 		section, err = cfg.NewSection(createOpts.Section)
@@ -184,7 +263,7 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 		}
 	}
 
-	err = cfg.SaveTo(createOpts.FileName)
+	err = fileIniSave(client, cfg, createOpts.FileName)
 	if err != nil {
 		return
 	}
@@ -192,66 +271,110 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 	return
 }
 
-// Update will update an existing file ini entry.
-func Update(client client.Client, updateOpts UpdateOpts) (err error) {
-	client.Logger.Debugf("Updating FileIni entry")
-
-	if err = utils.BuildRequest(&updateOpts); err != nil {
+// fileIniLoad is an internal function that will load an ini file from
+// the managed system.
+func fileIniLoad(client client.Client, fileName string) (cfg *ini.File, err error) {
+	content, err := client.System().ReadFile(fileName)
+	if err != nil {
 		return
 	}
 
-	client.Logger.Debugf("FileIni Update Options: %#v", updateOpts)
+	return ini.LoadSources(ini.LoadOptions{AllowBooleanKeys: true}, content)
+}
 
-	exists, err := Exists(client, updateOpts.FileName, updateOpts.Section, updateOpts.Key)
-	if err != nil {
+// fileIniSave is an internal function that will save an ini file to the
+// managed system. A new file is created with mode 0644.
+func fileIniSave(client client.Client, cfg *ini.File, fileName string) (err error) {
+	var buf bytes.Buffer
+	if _, err = cfg.WriteTo(&buf); err != nil {
 		return
 	}
 
-	if !exists {
-		err = fmt.Errorf("FileIni entry does not exist")
-		return
-	}
+	return client.WriteFile(fileName, buf.Bytes(), executor.WriteOpts{Mode: 0644})
+}
 
-	deleteOpts := DeleteOpts{
-		FileName: updateOpts.FileName,
-		Section:  updateOpts.Section,
-		Key:      updateOpts.Key,
-	}
+// Resource represents the desired state of an ini file entry.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
 
-	err = Delete(client, deleteOpts)
-	if err != nil {
-		return
-	}
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
 
-	createOpts := CreateOpts{
-		FileName: updateOpts.FileName,
-		Section:  updateOpts.Section,
-		Key:      updateOpts.Key,
-		Value:    updateOpts.Value,
+// Apply will converge an ini file entry to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the file name, section, and key of the entry.
+func (r Resource) ID() string {
+	return fmt.Sprintf("%s/%s/%s", r.FileName, r.Section, r.Key)
+}
+
+// Read will read the entry.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	getOpts := GetOpts{
+		FileName: r.FileName,
+		Section:  r.Section,
+		Key:      r.Key,
 	}
 
-	return Create(client, createOpts)
+	return Read(client, getOpts)
 }
 
-func Delete(client client.Client, deleteOpts DeleteOpts) (err error) {
-	client.Logger.Debugf("Deleting FileIni entry")
+// Exists will determine if the entry exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.FileName, r.Section, r.Key)
+}
 
-	if err = utils.BuildRequest(&deleteOpts); err != nil {
-		return
+// Diff will compare the value of an existing entry to the desired value.
+// An entry without a value is a boolean key and its value is not compared.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	entry, _ := current.(FileIni)
+
+	if r.Value != "" && r.Value != entry.Value {
+		diffs = append(diffs, resources.Diff{Field: "Value", Old: entry.Value, New: r.Value})
 	}
 
-	client.Logger.Debugf("FileIni Delete Options: %#v", deleteOpts)
+	return
+}
 
-	cfg, err := ini.LoadSources(ini.LoadOptions{AllowBooleanKeys: true}, deleteOpts.FileName)
-	if err != nil {
-		return
+// Create will create the entry.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will update the value of the entry.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		FileName: r.FileName,
+		Section:  r.Section,
+		Key:      r.Key,
+		Value:    r.Value,
 	}
 
-	cfg.Section(deleteOpts.Section).DeleteKey(deleteOpts.Key)
-	err = cfg.SaveTo(deleteOpts.FileName)
-	if err != nil {
-		return
+	return Update(client, updateOpts)
+}
+
+// Delete will delete the entry.
+func (r Resource) Delete(client client.Client) error {
+	deleteOpts := DeleteOpts{
+		FileName: r.FileName,
+		Section:  r.Section,
+		Key:      r.Key,
 	}
 
-	return
+	return Delete(client, deleteOpts)
+}
+
+// AutoRequires returns the file which contains the setting.
+func (r Resource) AutoRequires() []resources.Ref {
+	return []resources.Ref{{Type: file.Type, ID: r.FileName}}
 }
@@ -31,6 +31,15 @@ To create a line:
 
 	err := fileline.Create(client, createOpts)
 
+To only change the file if it is still valid with the new line:
+
+	createOpts = fileline.CreateOpts{
+		FileName:    "/etc/ssh/sshd_config",
+		Line:        "PasswordAuthentication no",
+		Match:       "^#?PasswordAuthentication",
+		ValidateCmd: "sshd -t -f %s",
+	}
+
 To delete a line:
 
 	deleteOpts := fileline.DeleteOpts{
@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
 	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/file"
 	"github.com/jtopjian/craft/utils"
 )
 
@@ -28,6 +30,12 @@ type CreateOpts struct {
 
 	// Match is a regular expression to match against an existing line.
 	Match string
+
+	// ValidateCmd is a command which checks the file with the line added
+	// before it is written, such as "sshd -t -f %s". The %s is replaced
+	// with the name of a staged copy of the file. If the command fails,
+	// the file is left untouched.
+	ValidateCmd string
 }
 
 // GetOpts represents options to get a line in a file.
@@ -65,7 +73,7 @@ func Read(client client.Client, getOpts GetOpts) (fileLine FileLine, err error)
 
 	client.Logger.Debugf("FileLine Read Options: %#v", getOpts)
 
-	lines, err := utils.FileGetLines(getOpts.FileName)
+	lines, err := executor.ReadLines(client.System(), getOpts.FileName)
 	if err != nil {
 		return
 	}
@@ -137,7 +145,7 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 		lineRe = regexp.MustCompile(createOpts.Match)
 	}
 
-	lines, err := utils.FileGetLines(createOpts.FileName)
+	lines, err := executor.ReadLines(client.System(), createOpts.FileName)
 	if err != nil {
 		return
 	}
@@ -154,12 +162,26 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 		newLines = append(newLines, line)
 	}
 
+	detail := fmt.Sprintf("replace lines matching %s in %s with \"%s\"",
+		createOpts.Match, createOpts.FileName, createOpts.Line)
 	if !changed {
 		newLines = append(newLines, createOpts.Line)
+		detail = fmt.Sprintf("append line \"%s\" to %s", createOpts.Line, createOpts.FileName)
+	}
+
+	resourceTitle := fmt.Sprintf("%s/%s", createOpts.FileName, createOpts.Line)
+	if client.Pending(Type, resourceTitle, resources.ActionCreate, detail) {
+		return
 	}
 
 	newContent := strings.Join(newLines, "\n")
-	err = utils.WriteFile(createOpts.FileName, newContent)
+	// The file exists since it was read, so its mode is kept.
+	writeOpts := executor.WriteOpts{
+		Mode:        0644,
+		ValidateCmd: createOpts.ValidateCmd,
+	}
+
+	err = client.WriteFile(createOpts.FileName, []byte(newContent), writeOpts)
 	if err != nil {
 		return
 	}
@@ -186,7 +208,7 @@ func Delete(client client.Client, deleteOpts DeleteOpts) (err error) {
 		lineRe = regexp.MustCompile(deleteOpts.Match)
 	}
 
-	lines, err := utils.FileGetLines(deleteOpts.FileName)
+	lines, err := executor.ReadLines(client.System(), deleteOpts.FileName)
 	if err != nil {
 		return
 	}
@@ -204,11 +226,101 @@ func Delete(client client.Client, deleteOpts DeleteOpts) (err error) {
 		}
 	}
 
+	resourceTitle := fmt.Sprintf("%s/%s", deleteOpts.FileName, deleteOpts.Line)
+	detail := fmt.Sprintf("remove line \"%s\" from %s", deleteOpts.Line, deleteOpts.FileName)
+	if deleteOpts.Match != "" {
+		resourceTitle = fmt.Sprintf("%s/%s", deleteOpts.FileName, deleteOpts.Match)
+		detail = fmt.Sprintf("remove lines matching %s from %s", deleteOpts.Match, deleteOpts.FileName)
+	}
+
+	if client.Pending(Type, resourceTitle, resources.ActionDelete, detail) {
+		return
+	}
+
 	newContent := strings.Join(newLines, "\n")
-	err = utils.WriteFile(deleteOpts.FileName, newContent)
+	err = client.WriteFile(deleteOpts.FileName, []byte(newContent), executor.WriteOpts{Mode: 0644})
 	if err != nil {
 		return
 	}
 
 	return
 }
+
+// Resource represents the desired state of a line in a file.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
+
+// Apply will converge a line in a file to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the file name and the line.
+func (r Resource) ID() string {
+	return fmt.Sprintf("%s/%s", r.FileName, r.Line)
+}
+
+// Read will read the line from the file. If Match is set, the line
+// matching the regular expression is returned.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	getOpts := GetOpts{
+		FileName: r.FileName,
+		Line:     r.Line,
+		Match:    r.Match,
+	}
+
+	return Read(client, getOpts)
+}
+
+// Exists will determine if the exact line exists in the file.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.FileName, r.Line)
+}
+
+// Diff will compare the line found in the file to the desired line.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	fileLine, _ := current.(FileLine)
+
+	if fileLine.Line != r.Line {
+		diffs = append(diffs, resources.Diff{Field: "Line", Old: fileLine.Line, New: r.Line})
+	}
+
+	return
+}
+
+// Create will add the line to the file.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will replace the matching line in the file.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Delete will delete the line from the file.
+func (r Resource) Delete(client client.Client) error {
+	deleteOpts := DeleteOpts{
+		FileName: r.FileName,
+		Line:     r.Line,
+		Match:    r.Match,
+	}
+
+	return Delete(client, deleteOpts)
+}
+
+// AutoRequires returns the file which contains the line.
+func (r Resource) AutoRequires() []resources.Ref {
+	return []resources.Ref{{Type: file.Type, ID: r.FileName}}
+}
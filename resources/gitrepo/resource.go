@@ -3,10 +3,14 @@ package gitrepo
 import (
 	"fmt"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/jtopjian/craft/client"
 	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/directory"
+	"github.com/jtopjian/craft/resources/groupadd"
+	"github.com/jtopjian/craft/resources/useradd"
 	"github.com/jtopjian/craft/utils"
 )
 
@@ -31,6 +35,12 @@ type GitRepo struct {
 
 	// Latest is if the git repository is at the latest update.
 	Latest bool
+
+	// Owner is the user that owns the git repository.
+	Owner string
+
+	// Group is the group owner of the repository.
+	Group string
 }
 
 // CreateOpts represents options used to create a git repo.
@@ -39,7 +49,7 @@ type CreateOpts struct {
 	Name string `required:"true"`
 
 	// Source is the source of the git repository.
-	Source string `require:"true"`
+	Source string `required:"true"`
 
 	// Owner is the user that owns the git repository.
 	Owner string `default:"root"`
@@ -83,39 +93,43 @@ func Read(client client.Client, name string) (repo GitRepo, err error) {
 
 	client.Logger.Debugf("Reading GitRepo %s", name)
 
-	_, err = os.Stat(name)
+	system := client.System()
+
+	fi, err := system.Stat(name)
 	if err != nil {
 		err = resources.NotFoundError{Type: Type, Name: name}
 		return
 	}
 
-	_, err = os.Stat(name + "/.git/config")
+	_, err = system.Stat(name + "/.git/config")
 	if err != nil {
 		if os.IsNotExist(err) {
-			err = fmt.Errorf("%s is not a git repository")
+			err = fmt.Errorf("%s is not a git repository", name)
 		}
 		return
 	}
 
 	repo.Name = name
+	repo.Owner = fi.Owner
+	repo.Group = fi.Group
 	eo.Dir = name
 
 	// try to determine the branch
-	eo.Command = "git rev-parse --abbrev-ref HEAD"
-	execResult, err := utils.Exec(eo)
+	eo.Args = []string{"git", "rev-parse", "--abbrev-ref", "HEAD"}
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
 	repo.Branch = strings.TrimSpace(execResult.Stdout)
 
-	eo.Command = "git remote update"
-	execResult, err = utils.Exec(eo)
+	eo.Args = []string{"git", "remote", "update"}
+	execResult, err = client.Exec(eo)
 	if err != nil {
 		return
 	}
 
-	eo.Command = "git status -uno"
-	execResult, err = utils.Exec(eo)
+	eo.Args = []string{"git", "status", "-uno"}
+	execResult, err = client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -124,15 +138,15 @@ func Read(client client.Client, name string) (repo GitRepo, err error) {
 		repo.Latest = true
 	}
 
-	eo.Command = "git rev-parse HEAD"
-	execResult, err = utils.Exec(eo)
+	eo.Args = []string{"git", "rev-parse", "HEAD"}
+	execResult, err = client.Exec(eo)
 	if err != nil {
 		return
 	}
 	repo.Commit = strings.TrimSpace(execResult.Stdout)
 
-	eo.Command = "git describe --always --tag"
-	execResult, err = utils.Exec(eo)
+	eo.Args = []string{"git", "describe", "--always", "--tag"}
+	execResult, err = client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -174,8 +188,8 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 
 	client.Logger.Debugf("GitRepo Create Options: %#v", createOpts)
 
-	eo.Command = fmt.Sprintf("git clone --quiet %s %s", createOpts.Source, createOpts.Name)
-	_, err = utils.Exec(eo)
+	eo.Args = []string{"git", "clone", "--quiet", createOpts.Source, createOpts.Name}
+	err = gitRepoExec(client, resources.ActionCreate, createOpts.Name, eo)
 	if err != nil {
 		return
 	}
@@ -183,40 +197,35 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 	eo.Dir = createOpts.Name
 
 	if createOpts.Commit != "" {
-		eo.Command = fmt.Sprintf("git checkout %s", createOpts.Commit)
-		_, err = utils.Exec(eo)
+		eo.Args = []string{"git", "checkout", createOpts.Commit}
+		err = gitRepoExec(client, resources.ActionCreate, createOpts.Name, eo)
 		if err != nil {
 			return
 		}
 	}
 
 	if createOpts.Tag != "" {
-		eo.Command = fmt.Sprintf("git checkout tags/%s", createOpts.Tag)
-		_, err = utils.Exec(eo)
+		eo.Args = []string{"git", "checkout", "tags/" + createOpts.Tag}
+		err = gitRepoExec(client, resources.ActionCreate, createOpts.Name, eo)
 		if err != nil {
 			return
 		}
 	}
 
 	if createOpts.Branch != "" {
-		eo.Command = fmt.Sprintf("git checkout %s", createOpts.Branch)
-		_, err = utils.Exec(eo)
+		eo.Args = []string{"git", "checkout", createOpts.Branch}
+		err = gitRepoExec(client, resources.ActionCreate, createOpts.Name, eo)
 		if err != nil {
 			return
 		}
 	}
 
-	uid, err := utils.UsernameToID(createOpts.Owner)
-	if err != nil {
+	detail := fmt.Sprintf("chown -R %s:%s %s", createOpts.Owner, createOpts.Group, createOpts.Name)
+	if client.Pending(Type, createOpts.Name, resources.ActionCreate, detail) {
 		return
 	}
 
-	gid, err := utils.GroupToID(createOpts.Group)
-	if err != nil {
-		return
-	}
-
-	err = utils.ChownR(createOpts.Name, uid, gid)
+	err = client.System().Chown(createOpts.Name, createOpts.Owner, createOpts.Group, true)
 	if err != nil {
 		return
 	}
@@ -239,15 +248,15 @@ func Update(client client.Client, name string, updateOpts UpdateOpts) (err error
 	eo.Dir = name
 
 	if updateOpts.Branch != "" {
-		eo.Command = fmt.Sprintf("git checkout %s", updateOpts.Branch)
-		_, err = utils.Exec(eo)
+		eo.Args = []string{"git", "checkout", updateOpts.Branch}
+		err = gitRepoExec(client, resources.ActionUpdate, name, eo)
 		if err != nil {
 			return
 		}
 
 		if updateOpts.Latest {
-			eo.Command = fmt.Sprintf("git pull", updateOpts.Branch)
-			_, err = utils.Exec(eo)
+			eo.Args = []string{"git", "pull"}
+			err = gitRepoExec(client, resources.ActionUpdate, name, eo)
 			if err != nil {
 				return
 			}
@@ -255,35 +264,28 @@ func Update(client client.Client, name string, updateOpts UpdateOpts) (err error
 	}
 
 	if updateOpts.Commit != "" {
-		eo.Command = fmt.Sprintf("git checkout %s", updateOpts.Commit)
-		_, err = utils.Exec(eo)
+		eo.Args = []string{"git", "checkout", updateOpts.Commit}
+		err = gitRepoExec(client, resources.ActionUpdate, name, eo)
 		if err != nil {
 			return
 		}
 	}
 
 	if updateOpts.Tag != "" {
-		eo.Command = fmt.Sprintf("git checkout tags/%s", updateOpts.Tag)
-		_, err = utils.Exec(eo)
+		eo.Args = []string{"git", "checkout", "tags/" + updateOpts.Tag}
+		err = gitRepoExec(client, resources.ActionUpdate, name, eo)
 		if err != nil {
 			return
 		}
 	}
 
 	if updateOpts.Owner != "" && updateOpts.Group != "" {
-		var uid int
-		var gid int
-		uid, err = utils.UsernameToID(updateOpts.Owner)
-		if err != nil {
+		detail := fmt.Sprintf("chown -R %s:%s %s", updateOpts.Owner, updateOpts.Group, name)
+		if client.Pending(Type, name, resources.ActionUpdate, detail) {
 			return
 		}
 
-		gid, err = utils.GroupToID(updateOpts.Group)
-		if err != nil {
-			return
-		}
-
-		err = utils.ChownR(name, uid, gid)
+		err = client.System().Chown(name, updateOpts.Owner, updateOpts.Group, true)
 		if err != nil {
 			return
 		}
@@ -296,10 +298,148 @@ func Update(client client.Client, name string, updateOpts UpdateOpts) (err error
 func Delete(client client.Client, name string) (err error) {
 	client.Logger.Debugf("Deleting GitRepo %s", name)
 
-	err = os.RemoveAll(name)
+	if client.Pending(Type, name, resources.ActionDelete, fmt.Sprintf("rm -r %s", name)) {
+		return
+	}
+
+	err = client.System().Remove(name, true)
 	if err != nil {
 		return
 	}
 
 	return
 }
+
+// gitRepoExec is an internal function that will run a git command
+// unless the client is in dry-run mode. The action is what is being
+// done to the repository.
+func gitRepoExec(client client.Client, action, name string, eo utils.ExecOptions) (err error) {
+	if client.Pending(Type, name, action, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+	return
+}
+
+// Resource represents the desired state of a git repository.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
+
+// Apply will converge a git repository to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the path of the repository.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the repository.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the repository exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff will compare the checked out revision and the owner of an
+// existing repository to the desired state.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	repo, _ := current.(GitRepo)
+
+	desired := r.CreateOpts
+	if invalid := resources.Desired(&desired); invalid != nil {
+		return invalid
+	}
+
+	if desired.Owner != repo.Owner {
+		diffs = append(diffs, resources.Diff{Field: "Owner", Old: repo.Owner, New: desired.Owner})
+	}
+
+	if desired.Group != repo.Group {
+		diffs = append(diffs, resources.Diff{Field: "Group", Old: repo.Group, New: desired.Group})
+	}
+
+	if r.Branch != "" && r.Branch != repo.Branch {
+		diffs = append(diffs, resources.Diff{Field: "Branch", Old: repo.Branch, New: r.Branch})
+	}
+
+	if r.Commit != "" && !strings.HasPrefix(repo.Commit, r.Commit) {
+		diffs = append(diffs, resources.Diff{Field: "Commit", Old: repo.Commit, New: r.Commit})
+	}
+
+	if r.Tag != "" && r.Tag != repo.Tag {
+		diffs = append(diffs, resources.Diff{Field: "Tag", Old: repo.Tag, New: r.Tag})
+	}
+
+	return
+}
+
+// Create will clone the repository.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will check out the differing revision of the repository. The
+// repository is always chowned so that the files of a checkout are owned
+// by the desired owner and group.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	desired := r.CreateOpts
+	if err := utils.BuildRequest(&desired); err != nil {
+		return err
+	}
+
+	updateOpts := UpdateOpts{
+		Owner: desired.Owner,
+		Group: desired.Group,
+	}
+
+	for _, diff := range diffs {
+		switch diff.Field {
+		case "Branch":
+			updateOpts.Branch = r.Branch
+		case "Commit":
+			updateOpts.Commit = r.Commit
+		case "Tag":
+			updateOpts.Tag = r.Tag
+		}
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will delete the repository.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// AutoRequires returns the directory which the repository is cloned into
+// as well as its owner and group.
+func (r Resource) AutoRequires() (refs []resources.Ref) {
+	refs = append(refs, resources.Ref{Type: directory.Type, ID: path.Dir(r.Name)})
+
+	if r.Owner != "" {
+		refs = append(refs, resources.Ref{Type: useradd.Type, ID: r.Owner})
+	}
+
+	if r.Group != "" {
+		refs = append(refs, resources.Ref{Type: groupadd.Type, ID: r.Group})
+	}
+
+	return
+}
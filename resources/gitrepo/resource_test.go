@@ -2,12 +2,72 @@ package gitrepo
 
 import (
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
 	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
 	"github.com/stretchr/testify/assert"
 )
 
+func Test_GitRepo_Fake(t *testing.T) {
+	branch := "master"
+
+	fake := executor.NewFake()
+	fake.AddFile("/srv/app/.git/config", "", 0644)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch strings.Join(eo.Args[1:], " ") {
+		case "rev-parse --abbrev-ref HEAD":
+			er.Stdout = branch + "\n"
+		case "status -uno":
+			er.Stdout = "Your branch is up-to-date with 'origin/master'.\n"
+		case "rev-parse HEAD":
+			er.Stdout = "0f5c2a1\n"
+		case "describe --always --tag":
+			er.Stdout = "v1.0\n"
+		case "checkout develop":
+			branch = "develop"
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	repo, err := Read(c, "/srv/app")
+	assert.Nil(t, err)
+	assert.Equal(t, "root", repo.Owner, "should be equal")
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:   "/srv/app",
+			Source: "https://example.com/app.git",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	// The files of a checkout are chowned.
+	r.Owner = "deploy"
+	r.Branch = "develop"
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, resources.ActionUpdate, change.Action, "should be equal")
+	assert.Equal(t, []resources.Diff{
+		{Field: "Owner", Old: "root", New: "deploy"},
+		{Field: "Branch", Old: "master", New: "develop"},
+	}, change.Diffs, "should be equal")
+	assert.Equal(t, "deploy", fake.Files["/srv/app/.git/config"].Owner, "should be equal")
+
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+}
+
 func Test_GitRepo_Apply(t *testing.T) {
 	acc := os.Getenv("TEST_ACC")
 	if acc == "" {
@@ -2,10 +2,10 @@ package groupadd
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
 	"github.com/jtopjian/craft/resources"
 	"github.com/jtopjian/craft/utils"
 )
@@ -40,14 +40,23 @@ type UpdateOpts struct {
 func Read(client client.Client, name string) (group Group, err error) {
 	client.Logger.Debugf("Reading Group %s", name)
 
-	gid, err := utils.GroupToID(name)
+	var eo utils.ExecOptions
+
+	eo.Args = []string{"getent", "group", name}
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		err = resources.NotFoundError{Type: Type, Name: name}
 		return
 	}
 
+	parts := strings.Split(strings.TrimSpace(execResult.Stdout), ":")
+	if len(parts) < 3 {
+		err = fmt.Errorf("Unable to parse group entry: %s", execResult.Stdout)
+		return
+	}
+
 	group.Name = name
-	group.GID = strconv.Itoa(gid)
+	group.GID = parts[2]
 
 	return
 }
@@ -72,7 +81,7 @@ func Exists(client client.Client, name string) (exists bool, err error) {
 func List(client client.Client) (groups []Group, err error) {
 	client.Logger.Debug("Retrieving all groups")
 
-	lines, err := utils.FileGetLines("/etc/group")
+	lines, err := executor.ReadLines(client.System(), "/etc/group")
 	if err != nil {
 		return
 	}
@@ -80,6 +89,10 @@ func List(client client.Client) (groups []Group, err error) {
 	for _, line := range lines {
 		var group Group
 		parts := strings.Split(line, ":")
+		if len(parts) < 3 {
+			continue
+		}
+
 		group.Name = parts[0]
 		group.GID = parts[2]
 
@@ -103,12 +116,16 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 	client.Logger.Debugf("Group Create Options: %#v", createOpts)
 
 	if createOpts.GID != "" {
-		createArgs = append(createArgs, fmt.Sprintf("-g %s", createOpts.GID))
+		createArgs = append(createArgs, "-g", createOpts.GID)
 	}
 
 	createArgs = append(createArgs, createOpts.Name)
-	eo.Command = fmt.Sprintf("groupadd %s", strings.Join(createArgs, " "))
-	execResult, err := utils.Exec(eo)
+	eo.Args = append([]string{"groupadd"}, createArgs...)
+	if client.Pending(Type, createOpts.Name, resources.ActionCreate, eo.String()) {
+		return
+	}
+
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -135,11 +152,16 @@ func Update(client client.Client, name string, updateOpts UpdateOpts) (err error
 	client.Logger.Debugf("Group Update Options: %#v", updateOpts)
 
 	if updateOpts.GID != "" {
-		updateArgs = append(updateArgs, fmt.Sprintf("-g %s", updateOpts.GID))
+		updateArgs = append(updateArgs, "-g", updateOpts.GID)
+	}
+
+	updateArgs = append(updateArgs, name)
+	eo.Args = append([]string{"groupmod"}, updateArgs...)
+	if client.Pending(Type, name, resources.ActionUpdate, eo.String()) {
+		return
 	}
 
-	eo.Command = fmt.Sprintf("groupmod %s %s", strings.Join(updateArgs, " "), name)
-	execResult, err := utils.Exec(eo)
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -158,8 +180,12 @@ func Delete(client client.Client, name string) (err error) {
 
 	client.Logger.Debugf("Deleting Group %s", name)
 
-	eo.Command = fmt.Sprintf("groupdel %s", name)
-	execResult, err := utils.Exec(eo)
+	eo.Args = []string{"groupdel", name}
+	if client.Pending(Type, name, resources.ActionDelete, eo.String()) {
+		return
+	}
+
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -171,3 +197,67 @@ func Delete(client client.Client, name string) (err error) {
 
 	return
 }
+
+// Resource represents the desired state of a group.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+
+// Apply will converge a group to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the group.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the group.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the group exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff will compare an existing group to the desired state.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	group, _ := current.(Group)
+
+	if r.GID != "" && r.GID != group.GID {
+		diffs = append(diffs, resources.Diff{Field: "GID", Old: group.GID, New: r.GID})
+	}
+
+	return
+}
+
+// Create will create the group.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will update the group to the desired state.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	updateOpts := UpdateOpts{
+		GID: r.GID,
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will delete the group.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
@@ -0,0 +1,62 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ref is a reference to a resource by its type and ID. It is written as
+// Type[ID], such as "File[/etc/hosts]". An ID of "*" refers to every
+// resource of the type, and an ID ending in "/*", such as
+// "SystemdUnit[nginx.service/*]", refers to every resource whose ID is
+// below it.
+type Ref struct {
+	Type string
+	ID   string
+}
+
+// RefOf returns a reference to a resource.
+func RefOf(r Resource) Ref {
+	return Ref{Type: r.Type(), ID: r.ID()}
+}
+
+// ParseRef will parse a reference in the form Type[ID].
+func ParseRef(s string) (ref Ref, err error) {
+	i := strings.Index(s, "[")
+	if i < 1 || !strings.HasSuffix(s, "]") || i == len(s)-2 {
+		err = fmt.Errorf("Invalid resource reference %q: expected Type[ID]", s)
+		return
+	}
+
+	ref.Type = s[:i]
+	ref.ID = s[i+1 : len(s)-1]
+
+	return
+}
+
+// Wildcard reports whether the reference may refer to many resources.
+func (r Ref) Wildcard() bool {
+	return r.ID == "*" || strings.HasSuffix(r.ID, "/*")
+}
+
+// Matches reports whether the reference refers to the resource with the
+// given reference.
+func (r Ref) Matches(other Ref) bool {
+	if r.Type != other.Type {
+		return false
+	}
+
+	switch {
+	case r.ID == "*":
+		return true
+	case strings.HasSuffix(r.ID, "/*"):
+		return strings.HasPrefix(other.ID, strings.TrimSuffix(r.ID, "*"))
+	}
+
+	return r.ID == other.ID
+}
+
+// String returns the reference in the form Type[ID].
+func (r Ref) String() string {
+	return fmt.Sprintf("%s[%s]", r.Type, r.ID)
+}
@@ -2,8 +2,95 @@ package resources
 
 import (
 	"fmt"
+
+	"github.com/jtopjian/craft/client"
 )
 
+// Resource is implemented by every resource type so that resources can
+// be managed uniformly without knowing their concrete type.
+//
+// An implementation holds the desired state of a single resource. The
+// package-level functions of each resource package remain available and
+// the implementations are thin wrappers around them.
+type Resource interface {
+	// Type returns the type of the resource, such as "File".
+	Type() string
+
+	// ID returns an identifier of the resource which is unique within
+	// its type.
+	ID() string
+
+	// Metadata returns the parameters common to all resources.
+	Metadata() Meta
+
+	// Read retrieves the current state of the resource.
+	// A NotFoundError is returned if the resource does not exist.
+	Read(client client.Client) (interface{}, error)
+
+	// Exists determines if the resource exists.
+	Exists(client client.Client) (bool, error)
+
+	// Diff compares the current state of the resource, as returned by
+	// Read, to the desired state and returns the fields which differ.
+	// If current is nil, the resource does not exist and every field
+	// with a desired value is returned.
+	Diff(current interface{}) []Diff
+
+	// Create creates the resource.
+	Create(client client.Client) error
+
+	// Update updates the given fields of an existing resource to the
+	// desired state.
+	Update(client client.Client, diffs []Diff) error
+
+	// Delete deletes the resource.
+	Delete(client client.Client) error
+}
+
+// ContentDiffer is implemented by resources which manage the content of
+// a file, such as the file resource.
+type ContentDiffer interface {
+	// ContentDiff returns a unified diff between the current content
+	// and the desired content. An empty string is returned if the
+	// content is not managed or does not differ.
+	ContentDiff(client client.Client) (string, error)
+}
+
+// Preparer is implemented by resources whose desired state depends on
+// the system, such as a file whose content is rendered from a template.
+type Preparer interface {
+	// Prepare returns the resource with its desired state resolved.
+	// It is called by Apply before the resource is compared or changed.
+	Prepare(client client.Client) (Resource, error)
+}
+
+// AutoRequirer is implemented by resources which implicitly depend on
+// other resources, such as a file which depends on the user who owns it.
+//
+// Unlike Meta.Requires, an automatic requirement is ignored if the
+// resource it refers to is not being managed.
+type AutoRequirer interface {
+	AutoRequires() []Ref
+}
+
+// Locker is implemented by resources which must not be applied at the
+// same time as other resources with the same lock name, such as apt
+// packages, which all need the dpkg lock.
+type Locker interface {
+	// LockName returns the name of the lock. An empty name means the
+	// resource does not need a lock.
+	LockName() string
+}
+
+// Handler is implemented by resources which can be notified by other
+// resources, such as a command which reloads a service after its
+// configuration changed.
+type Handler interface {
+	// Handle runs the handler. It is called once at the end of a run if
+	// any of the resources which notify it changed.
+	Handle(client client.Client) error
+}
+
 // NotFoundError is returned when a resource was not found.
 type NotFoundError struct {
 	Type string
@@ -0,0 +1,64 @@
+/*
+Package service manages a systemd service with systemctl.
+
+A service exists if its unit is loaded, and it is active if it is running
+or enabled. Creating a service starts and enables it, and deleting a
+service stops and disables it.
+
+A service resource converges any loaded unit to its desired state and
+startup, so a service which should be stopped and disabled is left alone
+once it is. Only a service whose ensure is absent is stopped and disabled
+while it is active.
+
+To read a service:
+
+	service, err := service.Read(client, "nginx")
+
+	fmt.Println(service.State, service.Startup, service.MainPID)
+
+To check if a service exists:
+
+	exists, err := service.Exists(client, "nginx")
+
+To check if a service is running or enabled:
+
+	active, err := service.Active(client, "nginx")
+
+To start and enable a service:
+
+	createOpts := service.CreateOpts{
+		Name: "nginx",
+	}
+
+	err := service.Create(client, createOpts)
+
+To keep a service stopped but start it at boot:
+
+	createOpts := service.CreateOpts{
+		Name:    "nginx",
+		State:   "stopped",
+		Startup: "enabled",
+	}
+
+To change a service:
+
+	updateOpts := service.UpdateOpts{
+		State: "stopped",
+	}
+
+	err := service.Update(client, "nginx", updateOpts)
+
+To stop and disable a service:
+
+	err := service.Delete(client, "nginx")
+
+To restart or reload a service which is running:
+
+	err := service.Restart(client, "nginx")
+
+	err = service.Reload(client, "nginx")
+
+A service is a handler, so it is restarted when a resource which notifies
+it changes. Set NotifyAction to "reload" to reload it instead.
+*/
+package service
@@ -0,0 +1,485 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/systemdunit"
+	"github.com/jtopjian/craft/utils"
+)
+
+const Type = "Service"
+
+// Valid values of the state of a service.
+const (
+	Running = "running"
+	Stopped = "stopped"
+)
+
+// Valid values of the startup of a service.
+const (
+	Enabled  = "enabled"
+	Disabled = "disabled"
+)
+
+// Valid actions taken when a service is notified.
+const (
+	NotifyRestart = "restart"
+	NotifyReload  = "reload"
+)
+
+// showProperties are the properties which are read with systemctl show.
+var showProperties = []string{
+	"Id", "Description", "LoadState", "ActiveState", "SubState",
+	"UnitFileState", "MainPID", "FragmentPath",
+}
+
+// Service represents a systemd service.
+type Service struct {
+	// Name is the name of the unit, such as "nginx.service".
+	Name string
+
+	// Description is the description of the unit.
+	Description string
+
+	// LoadState is the load state of the unit, such as "loaded" or
+	// "not-found".
+	LoadState string
+
+	// ActiveState is the active state of the unit, such as "active" or
+	// "inactive".
+	ActiveState string
+
+	// SubState is the unit type specific state, such as "running" or
+	// "dead".
+	SubState string
+
+	// UnitFileState is the state of the unit file, such as "enabled",
+	// "disabled", or "static".
+	UnitFileState string
+
+	// MainPID is the process ID of the main process of the service, or 0
+	// if it is not running.
+	MainPID int
+
+	// FragmentPath is the name of the unit file.
+	FragmentPath string
+
+	// State is "running" if the service is active and "stopped"
+	// otherwise.
+	State string
+
+	// Startup is "enabled" or "disabled" depending on whether the service
+	// is started at boot. Units which cannot be enabled, such as static
+	// units, have their UnitFileState instead.
+	Startup string
+}
+
+// CreateOpts represents options used to manage a service.
+type CreateOpts struct {
+	// Name is the name of the service, such as "nginx".
+	Name string `required:"true"`
+
+	// State is the desired state of the service: "running" or "stopped".
+	State string `default:"running"`
+
+	// Startup determines if the service is started at boot: "enabled" or
+	// "disabled".
+	Startup string `default:"enabled"`
+
+	// NotifyAction is what is done when the service is notified:
+	// "restart" or "reload".
+	NotifyAction string `default:"restart"`
+}
+
+// UpdateOpts represents options used to change a service.
+type UpdateOpts struct {
+	// State is the desired state of the service: "running" or "stopped".
+	State string
+
+	// Startup determines if the service is started at boot: "enabled" or
+	// "disabled".
+	Startup string
+}
+
+// Read will read the state of a service with systemctl show.
+func Read(client client.Client, name string) (service Service, err error) {
+	client.Logger.Debugf("Reading service %s", name)
+
+	var eo utils.ExecOptions
+	eo.Args = []string{"systemctl", "show", "--property=" + strings.Join(showProperties, ","), "--", name}
+
+	execResult, err := client.Exec(eo)
+	if err != nil {
+		return
+	}
+
+	service, err = ParseShow(execResult.Stdout)
+	if err != nil {
+		return
+	}
+
+	if service.LoadState == "not-found" {
+		err = resources.NotFoundError{Type: Type, Name: name}
+		return
+	}
+
+	return
+}
+
+// Exists will determine if the unit of a service is loaded, whether the
+// service is running or not.
+func Exists(client client.Client, name string) (exists bool, err error) {
+	client.Logger.Debugf("Checking if service %s exists", name)
+
+	_, err = Read(client, name)
+	if err != nil {
+		if _, ok := err.(resources.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	exists = true
+
+	return
+}
+
+// Active will determine if a service is running or enabled. A service
+// which does not exist is not active.
+func Active(client client.Client, name string) (active bool, err error) {
+	client.Logger.Debugf("Checking if service %s is active", name)
+
+	service, err := Read(client, name)
+	if err != nil {
+		if _, ok := err.(resources.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	active = service.State == Running || service.Startup == Enabled
+
+	return
+}
+
+// Create will start or stop and enable or disable a service.
+func Create(client client.Client, createOpts CreateOpts) (err error) {
+	client.Logger.Debugf("Creating service")
+
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("Service Create Options: %#v", createOpts)
+
+	if err = serviceValidate(createOpts); err != nil {
+		return
+	}
+
+	updateOpts := UpdateOpts{
+		State:   createOpts.State,
+		Startup: createOpts.Startup,
+	}
+
+	return serviceChange(client, createOpts.Name, resources.ActionCreate, updateOpts)
+}
+
+// Update will change the state or startup of a service.
+func Update(client client.Client, name string, updateOpts UpdateOpts) (err error) {
+	client.Logger.Debugf("Updating service %s", name)
+
+	if err = utils.BuildRequest(&updateOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("Service Update Options: %#v", updateOpts)
+
+	return serviceChange(client, name, resources.ActionUpdate, updateOpts)
+}
+
+// Delete will stop and disable a service.
+func Delete(client client.Client, name string) (err error) {
+	client.Logger.Debugf("Deleting service %s", name)
+
+	updateOpts := UpdateOpts{
+		State:   Stopped,
+		Startup: Disabled,
+	}
+
+	return serviceChange(client, name, resources.ActionDelete, updateOpts)
+}
+
+// Restart will restart a service. A service which is stopped is
+// left stopped.
+func Restart(client client.Client, name string) (err error) {
+	client.Logger.Debugf("Restarting service %s", name)
+
+	return serviceRun(client, name, resources.ActionNotify, "try-restart")
+}
+
+// Reload will reload the configuration of a service. A service
+// which cannot reload its configuration is restarted instead.
+func Reload(client client.Client, name string) (err error) {
+	client.Logger.Debugf("Reloading service %s", name)
+
+	return serviceRun(client, name, resources.ActionNotify, "try-reload-or-restart")
+}
+
+// ParseShow will parse the output of systemctl show.
+func ParseShow(output string) (service Service, err error) {
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+
+		v := strings.SplitN(line, "=", 2)
+		if len(v) != 2 {
+			err = fmt.Errorf("Unable to parse systemctl show output: %s", line)
+			return
+		}
+
+		key, value := v[0], v[1]
+		switch key {
+		case "Id":
+			service.Name = value
+		case "Description":
+			service.Description = value
+		case "LoadState":
+			service.LoadState = value
+		case "ActiveState":
+			service.ActiveState = value
+		case "SubState":
+			service.SubState = value
+		case "UnitFileState":
+			service.UnitFileState = value
+		case "MainPID":
+			if service.MainPID, err = strconv.Atoi(value); err != nil {
+				err = fmt.Errorf("Unable to parse MainPID of %s: %s", service.Name, value)
+				return
+			}
+		case "FragmentPath":
+			service.FragmentPath = value
+		}
+	}
+
+	switch service.ActiveState {
+	case "active", "activating", "reloading":
+		service.State = Running
+	default:
+		service.State = Stopped
+	}
+
+	switch service.UnitFileState {
+	case "enabled", "enabled-runtime", "linked", "linked-runtime", "alias":
+		service.Startup = Enabled
+	case "disabled", "masked", "masked-runtime":
+		service.Startup = Disabled
+	default:
+		service.Startup = service.UnitFileState
+	}
+
+	return
+}
+
+// serviceValidate is an internal function that will validate the state
+// and startup of a service.
+func serviceValidate(createOpts CreateOpts) error {
+	if createOpts.State != Running && createOpts.State != Stopped {
+		return fmt.Errorf("Invalid state for service %s: %s", createOpts.Name, createOpts.State)
+	}
+
+	if createOpts.Startup != Enabled && createOpts.Startup != Disabled {
+		return fmt.Errorf("Invalid startup for service %s: %s", createOpts.Name, createOpts.Startup)
+	}
+
+	if createOpts.NotifyAction != NotifyRestart && createOpts.NotifyAction != NotifyReload {
+		return fmt.Errorf("Invalid notify action for service %s: %s", createOpts.Name, createOpts.NotifyAction)
+	}
+
+	return nil
+}
+
+// serviceChange is an internal function that will apply the given state
+// and startup of a service.
+func serviceChange(client client.Client, name, action string, updateOpts UpdateOpts) (err error) {
+	switch updateOpts.State {
+	case Running:
+		err = serviceRun(client, name, action, "start")
+	case Stopped:
+		err = serviceRun(client, name, action, "stop")
+	}
+	if err != nil {
+		return
+	}
+
+	switch updateOpts.Startup {
+	case Enabled:
+		err = serviceRun(client, name, action, "enable")
+	case Disabled:
+		err = serviceRun(client, name, action, "disable")
+	}
+
+	return
+}
+
+// serviceRun is an internal function that will run a systemctl command
+// on a service. A reload of the systemd configuration which was deferred
+// since a unit changed is run first.
+func serviceRun(client client.Client, name, action, command string) (err error) {
+	var eo utils.ExecOptions
+
+	if err = client.RunDeferred(systemdunit.DaemonReload); err != nil {
+		return
+	}
+
+	eo.Args = []string{"systemctl", command, "--", name}
+	if client.Pending(Type, name, action, eo.String()) {
+		return
+	}
+
+	_, err = client.Exec(eo)
+
+	return
+}
+
+// Resource represents the desired state of a service.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.Handler = Resource{}
+var _ resources.AutoRequirer = Resource{}
+
+// Apply will converge a service to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the service.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the service.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the service is loaded, so that its state and
+// startup are converged by Diff and Update. A service which should be
+// absent only exists while it is active, so that it is not stopped and
+// disabled again.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	if r.Ensure == resources.Absent {
+		return Active(client, r.Name)
+	}
+
+	return Exists(client, r.Name)
+}
+
+// Diff will compare the state and startup of the service to the desired
+// state. The startup of a unit which cannot be enabled is not compared.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	service, _ := current.(Service)
+	desired := r.CreateOpts
+	if invalid := resources.Desired(&desired); invalid != nil {
+		return invalid
+	}
+
+	if desired.State != service.State {
+		diffs = append(diffs, resources.Diff{Field: "State", Old: service.State, New: desired.State})
+	}
+
+	canEnable := current == nil || service.Startup == Enabled || service.Startup == Disabled
+	if canEnable && desired.Startup != service.Startup {
+		diffs = append(diffs, resources.Diff{Field: "Startup", Old: service.Startup, New: desired.Startup})
+	}
+
+	return
+}
+
+// Create will start or stop and enable or disable the service.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will change the differing fields of the service.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	var updateOpts UpdateOpts
+
+	desired := r.CreateOpts
+	if err := utils.BuildRequest(&desired); err != nil {
+		return err
+	}
+
+	if err := serviceValidate(desired); err != nil {
+		return err
+	}
+
+	for _, diff := range diffs {
+		switch diff.Field {
+		case "State":
+			updateOpts.State = desired.State
+		case "Startup":
+			updateOpts.Startup = desired.Startup
+		}
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will stop and disable the service.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// Handle will restart or reload the service when it is notified. A
+// service which is stopped is not started.
+func (r Resource) Handle(client client.Client) error {
+	desired := r.CreateOpts
+	if err := utils.BuildRequest(&desired); err != nil {
+		return err
+	}
+
+	if desired.NotifyAction == NotifyReload {
+		return Reload(client, r.Name)
+	}
+
+	return Restart(client, r.Name)
+}
+
+// serviceUnitTypes are the suffixes of the types of systemd units.
+var serviceUnitTypes = map[string]bool{
+	".service": true, ".socket": true, ".device": true, ".mount": true,
+	".automount": true, ".swap": true, ".target": true, ".path": true,
+	".timer": true, ".slice": true, ".scope": true,
+}
+
+// AutoRequires returns the unit of the service and its drop-ins so that
+// the service is changed after they have been written.
+func (r Resource) AutoRequires() []resources.Ref {
+	// Like systemctl, a name without the suffix of a unit type is the
+	// name of a service.
+	unit := r.Name
+	if !serviceUnitTypes[path.Ext(unit)] {
+		unit += ".service"
+	}
+
+	return []resources.Ref{
+		{Type: systemdunit.Type, ID: unit},
+		{Type: systemdunit.Type, ID: unit + "/*"},
+	}
+}
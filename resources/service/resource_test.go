@@ -0,0 +1,204 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testShowRunning = `Id=nginx.service
+Description=A high performance web server and a reverse proxy server
+LoadState=loaded
+ActiveState=active
+SubState=running
+UnitFileState=enabled
+MainPID=1234
+FragmentPath=/lib/systemd/system/nginx.service
+`
+
+const testShowStopped = `Id=nginx.service
+Description=A high performance web server and a reverse proxy server
+LoadState=loaded
+ActiveState=inactive
+SubState=dead
+UnitFileState=disabled
+MainPID=0
+FragmentPath=/lib/systemd/system/nginx.service
+`
+
+const testShowNotFound = `Id=nope.service
+Description=nope.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+UnitFileState=
+MainPID=0
+FragmentPath=
+`
+
+// testSystemd returns a fake system with a service whose active and
+// unit file states follow the systemctl commands which are run.
+func testSystemd(show string) *executor.Fake {
+	service, _ := ParseShow(show)
+	activeState, unitFileState := service.ActiveState, service.UnitFileState
+
+	fake := executor.NewFake()
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch eo.Args[1] {
+		case "show":
+			if eo.Args[4] != "nginx" {
+				er.Stdout = testShowNotFound
+				return
+			}
+			er.Stdout = testShowStopped
+			er.Stdout = strings.Replace(er.Stdout, "ActiveState=inactive", "ActiveState="+activeState, 1)
+			er.Stdout = strings.Replace(er.Stdout, "UnitFileState=disabled", "UnitFileState="+unitFileState, 1)
+		case "start":
+			activeState = "active"
+		case "stop":
+			activeState = "inactive"
+		case "enable":
+			unitFileState = "enabled"
+		case "disable":
+			unitFileState = "disabled"
+		case "try-restart", "try-reload-or-restart":
+		default:
+			err = fmt.Errorf("unexpected command: %s", eo.String())
+		}
+		return
+	}
+
+	return fake
+}
+
+func Test_Service_Apply(t *testing.T) {
+	acc := os.Getenv("TEST_ACC")
+	if acc == "" {
+		t.Skip("TEST_ACC is not set. Skipping")
+	}
+
+	client := testhelper.TestClient()
+
+	service, err := Read(client, "cron")
+	assert.Nil(t, err)
+	assert.Equal(t, "cron.service", service.Name, "should be equal")
+}
+
+func Test_ParseShow(t *testing.T) {
+	service, err := ParseShow(testShowRunning)
+	assert.Nil(t, err)
+
+	expected := Service{
+		Name:          "nginx.service",
+		Description:   "A high performance web server and a reverse proxy server",
+		LoadState:     "loaded",
+		ActiveState:   "active",
+		SubState:      "running",
+		UnitFileState: "enabled",
+		MainPID:       1234,
+		FragmentPath:  "/lib/systemd/system/nginx.service",
+		State:         Running,
+		Startup:       Enabled,
+	}
+	assert.Equal(t, expected, service, "should be equal")
+
+	service, err = ParseShow("ActiveState=inactive\nUnitFileState=static\n")
+	assert.Nil(t, err)
+	assert.Equal(t, Stopped, service.State, "should be equal")
+	assert.Equal(t, "static", service.Startup, "should be equal")
+
+	_, err = ParseShow("MainPID=none\n")
+	assert.NotNil(t, err)
+}
+
+func Test_Service_Fake(t *testing.T) {
+	fake := testSystemd(testShowStopped)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	exists, err := Exists(c, "nope")
+	assert.Nil(t, err)
+	assert.Equal(t, false, exists, "should be equal")
+
+	exists, err = Exists(c, "nginx")
+	assert.Nil(t, err)
+	assert.Equal(t, true, exists, "should be equal")
+
+	active, err := Active(c, "nginx")
+	assert.Nil(t, err)
+	assert.Equal(t, false, active, "should be equal")
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name: "nginx",
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, resources.ActionUpdate, change.Action, "should be equal")
+	assert.Contains(t, fake.Commands, "systemctl start -- nginx")
+	assert.Contains(t, fake.Commands, "systemctl enable -- nginx")
+
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.State = Stopped
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, resources.ActionUpdate, change.Action, "should be equal")
+	assert.Equal(t, []resources.Diff{{Field: "State", Old: Running, New: Stopped}}, change.Diffs, "should be equal")
+	assert.Equal(t, "systemctl stop -- nginx", fake.Commands[len(fake.Commands)-1], "should be equal")
+
+	r.NotifyAction = NotifyReload
+	err = r.Handle(c)
+	assert.Nil(t, err)
+	assert.Equal(t, "systemctl try-reload-or-restart -- nginx", fake.Commands[len(fake.Commands)-1], "should be equal")
+
+	r.State = "paused"
+	_, err = Apply(c, r)
+	assert.Equal(t, "Invalid state for service nginx: paused", err.Error(), "should be equal")
+}
+
+func Test_Service_Stopped(t *testing.T) {
+	fake := testSystemd(testShowRunning)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:    "nginx",
+			State:   Stopped,
+			Startup: Disabled,
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, resources.ActionUpdate, change.Action, "should be equal")
+	assert.Contains(t, fake.Commands, "systemctl stop -- nginx")
+	assert.Contains(t, fake.Commands, "systemctl disable -- nginx")
+
+	fake.Commands = nil
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+	assert.NotContains(t, fake.Commands, "systemctl stop -- nginx")
+	assert.NotContains(t, fake.Commands, "systemctl disable -- nginx")
+
+	// A service which should be absent is already stopped and disabled.
+	r.Ensure = resources.Absent
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+}
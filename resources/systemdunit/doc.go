@@ -0,0 +1,54 @@
+/*
+Package systemdunit manages systemd unit files and drop-ins in
+/etc/systemd/system.
+
+To read a unit or a drop-in:
+
+	unit, err := systemdunit.Read(client, systemdunit.GetOpts{
+		Name: "app.service",
+	})
+
+	dropIn, err := systemdunit.Read(client, systemdunit.GetOpts{
+		Name:   "nginx.service",
+		DropIn: "override",
+	})
+
+To write a unit from its raw content:
+
+	createOpts := systemdunit.CreateOpts{
+		Name:    "app.service",
+		Content: "[Service]\nExecStart=/usr/local/bin/app\n",
+	}
+
+	err := systemdunit.Create(client, createOpts)
+
+To write a drop-in, /etc/systemd/system/nginx.service.d/override.conf,
+from sections. A key with a list of values is repeated:
+
+	createOpts := systemdunit.CreateOpts{
+		Name:   "nginx.service",
+		DropIn: "override",
+		Sections: map[string]map[string]interface{}{
+			"Service": {
+				"ExecStart": []string{"", "/usr/sbin/nginx -g 'daemon off;'"},
+				"Restart":   "always",
+			},
+		},
+	}
+
+	err := systemdunit.Create(client, createOpts)
+
+A unit given as sections is only rewritten if its parsed sections differ,
+so comments and formatting in the existing file are ignored.
+
+To delete a unit or drop-in:
+
+	err := systemdunit.Delete(client, systemdunit.GetOpts{
+		Name: "app.service",
+	})
+
+After a unit changes, systemctl daemon-reload is run. When the units are
+applied with the graph package, the reload is deferred so that it runs
+once: before the next service is changed, or at the end of the run.
+*/
+package systemdunit
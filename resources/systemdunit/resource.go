@@ -0,0 +1,482 @@
+package systemdunit
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-ini/ini"
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/utils"
+)
+
+const Type = "SystemdUnit"
+
+// DaemonReload is the name of the deferred action which reloads the
+// systemd configuration after units changed.
+const DaemonReload = "systemd-daemon-reload"
+
+// UnitDir is the directory which units are written to.
+const UnitDir = "/etc/systemd/system"
+
+// Sections are the sections of a unit, keyed by section and key. A key
+// may have several values, such as an empty ExecStart which clears the
+// ExecStart of the unit a drop-in overrides.
+type Sections map[string]map[string][]string
+
+// SystemdUnit represents a systemd unit file or drop-in on a system.
+type SystemdUnit struct {
+	// Name is the name of the unit, such as "app.service".
+	Name string
+
+	// DropIn is the name of the drop-in, such as "override".
+	DropIn string
+
+	// FileName is the name of the file of the unit or drop-in.
+	FileName string
+
+	// Content is the content of the file.
+	Content string
+
+	// Sections are the parsed sections of the file.
+	Sections Sections
+}
+
+// CreateOpts represents options used to write a unit or drop-in.
+type CreateOpts struct {
+	// Name is the name of the unit, such as "app.service".
+	Name string `required:"true"`
+
+	// DropIn is the name of a drop-in which overrides the unit, such as
+	// "override". If set, the drop-in is written to
+	// /etc/systemd/system/<name>.d/<dropin>.conf instead of the unit.
+	DropIn string
+
+	// Content is the raw content of the file. It cannot be used with
+	// Sections.
+	Content string
+
+	// Sections are the sections of the file, keyed by section and key.
+	// A value is either a string or a list of strings for keys which are
+	// repeated.
+	Sections map[string]map[string]interface{}
+}
+
+// GetOpts represents options used to read a unit or drop-in.
+type GetOpts struct {
+	// Name is the name of the unit.
+	Name string `required:"true"`
+
+	// DropIn is the name of a drop-in of the unit.
+	DropIn string
+}
+
+// FileName returns the name of the file of a unit or drop-in.
+func FileName(name, dropIn string) string {
+	if dropIn == "" {
+		return path.Join(UnitDir, name)
+	}
+
+	return path.Join(UnitDir, name+".d", strings.TrimSuffix(dropIn, ".conf")+".conf")
+}
+
+// Read will read a unit or drop-in.
+func Read(client client.Client, getOpts GetOpts) (unit SystemdUnit, err error) {
+	client.Logger.Debugf("Reading systemd unit")
+
+	if err = utils.BuildRequest(&getOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("SystemdUnit Read Options: %#v", getOpts)
+
+	fileName := FileName(getOpts.Name, getOpts.DropIn)
+	content, err := client.System().ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = resources.NotFoundError{Type: Type, Name: systemdUnitID(getOpts.Name, getOpts.DropIn)}
+		}
+		return
+	}
+
+	sections, err := ParseUnit(content)
+	if err != nil {
+		err = fmt.Errorf("Unable to parse %s: %s", fileName, err)
+		return
+	}
+
+	unit = SystemdUnit{
+		Name:     getOpts.Name,
+		DropIn:   getOpts.DropIn,
+		FileName: fileName,
+		Content:  string(content),
+		Sections: sections,
+	}
+
+	return
+}
+
+// Exists will determine if a unit or drop-in exists.
+func Exists(client client.Client, getOpts GetOpts) (exists bool, err error) {
+	client.Logger.Debugf("Checking if systemd unit %s exists", systemdUnitID(getOpts.Name, getOpts.DropIn))
+
+	_, err = Read(client, getOpts)
+	if err != nil {
+		if _, ok := err.(resources.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	exists = true
+
+	return
+}
+
+// Create will write a unit or drop-in. The systemd configuration is
+// reloaded once before it is next needed.
+func Create(client client.Client, createOpts CreateOpts) (err error) {
+	client.Logger.Debugf("Creating systemd unit")
+
+	if err = utils.BuildRequest(&createOpts); err != nil {
+		return
+	}
+
+	client.Logger.Debugf("SystemdUnit Create Options: %#v", createOpts)
+
+	content, err := systemdUnitContent(createOpts)
+	if err != nil {
+		return
+	}
+
+	id := systemdUnitID(createOpts.Name, createOpts.DropIn)
+	fileName := FileName(createOpts.Name, createOpts.DropIn)
+
+	detail := fmt.Sprintf("write %d bytes to %s", len(content), fileName)
+	if !client.Pending(Type, id, resources.ActionCreate, detail) {
+		system := client.System()
+
+		if createOpts.DropIn != "" {
+			if err = system.Mkdir(path.Dir(fileName), 0755, true); err != nil {
+				return
+			}
+		}
+
+		err = client.WriteFile(fileName, []byte(content), executor.WriteOpts{Mode: 0644})
+		if err != nil {
+			return
+		}
+	}
+
+	return client.Defer(systemdUnitDaemonReload())
+}
+
+// Update will rewrite a unit or drop-in.
+func Update(client client.Client, createOpts CreateOpts) (err error) {
+	return Create(client, createOpts)
+}
+
+// Delete will remove a unit or drop-in.
+func Delete(client client.Client, getOpts GetOpts) (err error) {
+	client.Logger.Debugf("Deleting systemd unit %s", systemdUnitID(getOpts.Name, getOpts.DropIn))
+
+	fileName := FileName(getOpts.Name, getOpts.DropIn)
+	if !client.Pending(Type, systemdUnitID(getOpts.Name, getOpts.DropIn), resources.ActionDelete, "rm "+fileName) {
+		if err = client.System().Remove(fileName, false); err != nil {
+			return
+		}
+	}
+
+	return client.Defer(systemdUnitDaemonReload())
+}
+
+// ParseUnit will parse the sections of a unit file.
+func ParseUnit(content []byte) (sections Sections, err error) {
+	loadOpts := ini.LoadOptions{
+		AllowShadows:            true,
+		IgnoreInlineComment:     true,
+		PreserveSurroundedQuote: true,
+	}
+
+	cfg, err := ini.LoadSources(loadOpts, content)
+	if err != nil {
+		return
+	}
+
+	sections = make(Sections)
+	for _, section := range cfg.Sections() {
+		keys := section.Keys()
+		if len(keys) == 0 {
+			continue
+		}
+
+		values := make(map[string][]string)
+		for _, key := range keys {
+			values[key.Name()] = key.ValueWithShadows()
+		}
+
+		sections[section.Name()] = values
+	}
+
+	return
+}
+
+// RenderUnit will render the sections of a unit file. The Unit section is
+// first and the Install section is last. Other sections and all keys are
+// sorted.
+func RenderUnit(sections Sections) string {
+	var names []string
+	for name := range sections {
+		if name != "Unit" && name != "Install" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if _, ok := sections["Unit"]; ok {
+		names = append([]string{"Unit"}, names...)
+	}
+
+	if _, ok := sections["Install"]; ok {
+		names = append(names, "Install")
+	}
+
+	var buf bytes.Buffer
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+
+		fmt.Fprintf(&buf, "[%s]\n", name)
+
+		var keys []string
+		for key := range sections[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			for _, value := range sections[name][key] {
+				fmt.Fprintf(&buf, "%s=%s\n", key, value)
+			}
+		}
+	}
+
+	return buf.String()
+}
+
+// systemdUnitID is an internal function that will return the identifier
+// of a unit or drop-in.
+func systemdUnitID(name, dropIn string) string {
+	if dropIn == "" {
+		return name
+	}
+
+	return name + "/" + dropIn
+}
+
+// systemdUnitSections is an internal function that will convert the
+// sections given in create options to Sections.
+func systemdUnitSections(createOpts CreateOpts) (sections Sections, err error) {
+	sections = make(Sections)
+	for name, keys := range createOpts.Sections {
+		values := make(map[string][]string)
+		for key, value := range keys {
+			switch v := value.(type) {
+			case string:
+				values[key] = []string{v}
+			case []string:
+				values[key] = v
+			case []interface{}:
+				for _, item := range v {
+					s, ok := item.(string)
+					if !ok {
+						err = fmt.Errorf("Invalid value of %s in section %s of systemd unit %s: %v",
+							key, name, createOpts.Name, item)
+						return
+					}
+					values[key] = append(values[key], s)
+				}
+			default:
+				values[key] = []string{fmt.Sprint(v)}
+			}
+		}
+		sections[name] = values
+	}
+
+	return
+}
+
+// systemdUnitContent is an internal function that will return the content
+// of a unit or drop-in.
+func systemdUnitContent(createOpts CreateOpts) (content string, err error) {
+	if createOpts.Content != "" && createOpts.Sections != nil {
+		err = fmt.Errorf("Only one of Content and Sections of systemd unit %s can be set",
+			systemdUnitID(createOpts.Name, createOpts.DropIn))
+		return
+	}
+
+	if createOpts.Sections == nil {
+		content = createOpts.Content
+		return
+	}
+
+	sections, err := systemdUnitSections(createOpts)
+	if err != nil {
+		return
+	}
+
+	content = RenderUnit(sections)
+
+	return
+}
+
+// systemdUnitDaemonReload is an internal function that will return the
+// deferred action which reloads the systemd configuration.
+func systemdUnitDaemonReload() client.DeferredAction {
+	return client.DeferredAction{
+		Type: Type,
+		Name: DaemonReload,
+		Run: func(client client.Client) (err error) {
+			var eo utils.ExecOptions
+
+			eo.Args = []string{"systemctl", "daemon-reload"}
+			if client.Pending(Type, DaemonReload, resources.ActionUpdate, eo.String()) {
+				return
+			}
+
+			_, err = client.Exec(eo)
+
+			return
+		},
+	}
+}
+
+// Resource represents the desired state of a systemd unit or drop-in.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.ContentDiffer = Resource{}
+
+// Apply will converge a systemd unit to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the unit, followed by the name of the drop-in
+// if it is one, such as "app.service/override".
+func (r Resource) ID() string {
+	return systemdUnitID(r.Name, r.DropIn)
+}
+
+// Read will read the unit or drop-in.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.getOpts())
+}
+
+// Exists will determine if the unit or drop-in exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.getOpts())
+}
+
+// Diff will compare the unit to the desired state. Units given as
+// sections are compared by their parsed sections, so that differences in
+// formatting are ignored.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	unit, _ := current.(SystemdUnit)
+
+	content, err := systemdUnitContent(r.CreateOpts)
+	if err != nil {
+		// The error is reported when the unit is written.
+		return []resources.Diff{{Field: "Content", Old: unit.md5(), New: "invalid"}}
+	}
+
+	if current != nil && r.Sections != nil {
+		sections, _ := systemdUnitSections(r.CreateOpts)
+		if reflect.DeepEqual(sections, unit.Sections) {
+			return
+		}
+	}
+
+	if current != nil && content == unit.Content {
+		return
+	}
+
+	diffs = append(diffs, resources.Diff{
+		Field: "Content",
+		Old:   unit.md5(),
+		New:   fmt.Sprintf("%x", md5.Sum([]byte(content))),
+	})
+
+	return
+}
+
+// Create will write the unit or drop-in.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will rewrite the unit or drop-in.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	return Update(client, r.CreateOpts)
+}
+
+// Delete will remove the unit or drop-in.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.getOpts())
+}
+
+// ContentDiff will return a unified diff between the current content of
+// the unit and the desired content.
+func (r Resource) ContentDiff(client client.Client) (diff string, err error) {
+	content, err := systemdUnitContent(r.CreateOpts)
+	if err != nil {
+		return
+	}
+
+	fileName := FileName(r.Name, r.DropIn)
+	current, err := client.System().ReadFile(fileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+		err = nil
+	}
+
+	diff = utils.UnifiedDiff(fileName, fileName, string(current), content)
+	return
+}
+
+// getOpts returns the options used to read the unit.
+func (r Resource) getOpts() GetOpts {
+	return GetOpts{
+		Name:   r.Name,
+		DropIn: r.DropIn,
+	}
+}
+
+// md5 returns the md5sum of the content of a unit, or an empty string if
+// the unit does not exist.
+func (u SystemdUnit) md5() string {
+	if u.FileName == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", md5.Sum([]byte(u.Content)))
+}
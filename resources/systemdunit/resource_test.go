@@ -0,0 +1,111 @@
+package systemdunit
+
+import (
+	"testing"
+
+	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/testhelper"
+	"github.com/stretchr/testify/assert"
+)
+
+const testUnit = `# Managed by hand
+[Unit]
+Description=App
+After=network.target
+
+[Service]
+ExecStart=/usr/local/bin/app --listen ":8080"
+Environment=A=1
+Environment=B=2
+
+[Install]
+WantedBy=multi-user.target
+`
+
+func Test_ParseUnit(t *testing.T) {
+	sections, err := ParseUnit([]byte(testUnit))
+	assert.Nil(t, err)
+
+	expected := Sections{
+		"Unit": {
+			"Description": {"App"},
+			"After":       {"network.target"},
+		},
+		"Service": {
+			"ExecStart":   {`/usr/local/bin/app --listen ":8080"`},
+			"Environment": {"A=1", "B=2"},
+		},
+		"Install": {
+			"WantedBy": {"multi-user.target"},
+		},
+	}
+	assert.Equal(t, expected, sections, "should be equal")
+
+	expectedContent := `[Unit]
+After=network.target
+Description=App
+
+[Service]
+Environment=A=1
+Environment=B=2
+ExecStart=/usr/local/bin/app --listen ":8080"
+
+[Install]
+WantedBy=multi-user.target
+`
+	assert.Equal(t, expectedContent, RenderUnit(sections), "should be equal")
+}
+
+func Test_SystemdUnit_DropIn(t *testing.T) {
+	fake := executor.NewFake()
+	fake.Mkdir(UnitDir, 0755, true)
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:   "app.service",
+			DropIn: "override",
+			Sections: map[string]map[string]interface{}{
+				"Service": {
+					"ExecStart": []interface{}{"", "/usr/local/bin/app --debug"},
+					"Restart":   "always",
+				},
+			},
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, resources.ActionCreate, change.Action, "should be equal")
+	assert.Equal(t, "app.service/override", change.Name, "should be equal")
+
+	fileName := "/etc/systemd/system/app.service.d/override.conf"
+	expected := "[Service]\nExecStart=\nExecStart=/usr/local/bin/app --debug\nRestart=always\n"
+	assert.Equal(t, expected, string(fake.Files[fileName].Content), "should be equal")
+
+	// Without a client which defers actions, systemd is reloaded at once.
+	assert.Equal(t, []string{"systemctl daemon-reload"}, fake.Commands, "should be equal")
+
+	// Formatting and comments do not matter.
+	fake.AddFile(fileName, "# override\n[Service]\nRestart = always\nExecStart=\nExecStart=/usr/local/bin/app --debug\n", 0644)
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Sections["Service"]["Restart"] = "on-failure"
+	c.DryRun = true
+	c.Plan = &client.Plan{}
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, resources.ActionUpdate, change.Action, "should be equal")
+	assert.Contains(t, change.ContentDiff, "+Restart=on-failure\n")
+	assert.Equal(t, "systemctl daemon-reload", c.Plan.Steps()[1].Detail, "should be equal")
+
+	r.Content = "[Service]\nRestart=always\n"
+	_, err = Apply(c, r)
+	assert.Equal(t, "Only one of Content and Sections of systemd unit app.service/override can be set", err.Error(), "should be equal")
+}
@@ -31,6 +31,10 @@ To update a user:
 
 	err := useradd.Update(client, updateOpts)
 
+A user with Sudo set has the file /etc/sudoers.d/<name>, which is removed
+when Sudo is not set. A user is only made a system account when it is
+created, since usermod cannot change it.
+
 To delete a user:
 
 	err : useradd.Delete(client, userName)
@@ -3,11 +3,14 @@ package useradd
 import (
 	"fmt"
 	"os"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/jtopjian/craft/client"
+	"github.com/jtopjian/craft/executor"
 	"github.com/jtopjian/craft/resources"
+	"github.com/jtopjian/craft/resources/groupadd"
 	"github.com/jtopjian/craft/utils"
 )
 
@@ -41,6 +44,10 @@ type User struct {
 
 	// Passwd is a passwd hash of the user.
 	Passwd string
+
+	// System is if the user is a system account, which has a UID below
+	// UID_MIN of /etc/login.defs.
+	System bool
 }
 
 // CreateOpts represents options used to create a user with useradd.
@@ -96,10 +103,12 @@ type UpdateOpts struct {
 	// CreateHome will create the user's home directory.
 	CreateHome bool
 
-	// Sudo will give the user sudo rights.
+	// Sudo will give the user sudo rights, or take them away if it is
+	// not set.
 	Sudo bool
 
-	// System will make the account a system account.
+	// System must not be set, since an existing user cannot be made a
+	// system account.
 	System bool
 
 	// Comment is a descriptive comment of the user.
@@ -116,40 +125,60 @@ type UpdateOpts struct {
 func Read(client client.Client, name string) (user User, err error) {
 	client.Logger.Debugf("Reading user %s", name)
 
-	ge, err := getent("passwd", name)
+	ge, err := getent(client, "passwd", name)
+	if err != nil {
+		return
+	}
+
 	if len(ge) < 7 {
-		err = resources.NotFoundError{Type: Type, Name: name}
+		err = fmt.Errorf("Unable to read user %s: unexpected passwd entry %q", name, strings.Join(ge, ":"))
 		return
 	}
 
+	user.Name = name
 	user.UID = ge[2]
 	user.GID = ge[3]
 	user.Comment = ge[4]
 	user.HomeDir = ge[5]
 	user.Shell = ge[6]
 
-	ge, err = getent("shadow", name)
-	if len(ge) > 0 {
+	uidMin, err := userUIDMin(client)
+	if err != nil {
+		return
+	}
+
+	if uid, err := strconv.Atoi(user.UID); err == nil {
+		user.System = uid < uidMin
+	}
+
+	// The shadow entry cannot be read without root, so a missing entry
+	// only leaves the hash empty.
+	ge, err = getent(client, "shadow", name)
+	if err != nil {
+		if _, ok := err.(resources.NotFoundError); !ok {
+			return
+		}
+		err = nil
+	}
+
+	if len(ge) > 1 {
 		user.Passwd = ge[1]
 	}
 
+	system := client.System()
+
 	sudoFile := fmt.Sprintf("/etc/sudoers.d/%s", name)
-	_, err = os.Stat(sudoFile)
-	if err == nil {
-		user.Sudo = true
+	user.Sudo, err = executor.Exists(system, sudoFile)
+	if err != nil {
+		return
 	}
 
-	lines, err := utils.FileGetLines("/etc/group")
+	lines, err := executor.ReadLines(system, "/etc/group")
 	if err != nil {
 		return
 	}
 
-	groupRe := regexp.MustCompile(fmt.Sprintf("(.+):.+:.+:*%s*", name))
-	for _, line := range lines {
-		if v := groupRe.FindStringSubmatch(line); v != nil {
-			user.Groups = append(user.Groups, v[1])
-		}
-	}
+	user.Groups = userParseGroups(lines, name)
 
 	return
 }
@@ -175,7 +204,7 @@ func Exists(client client.Client, name string) (exists bool, err error) {
 func List(client client.Client) (users []User, err error) {
 	client.Logger.Debug("Retriving all users")
 
-	lines, err := utils.FileGetLines("/etc/passwd")
+	lines, err := executor.ReadLines(client.System(), "/etc/passwd")
 	if err != nil {
 		return
 	}
@@ -183,6 +212,10 @@ func List(client client.Client) (users []User, err error) {
 	for _, line := range lines {
 		var user User
 		parts := strings.Split(line, ":")
+		if parts[0] == "" {
+			continue
+		}
+
 		user, err = Read(client, parts[0])
 		if err != nil {
 			continue
@@ -207,45 +240,49 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 	client.Logger.Debugf("User Create Options: %#v", createOpts)
 
 	if createOpts.UID != "" {
-		createArgs = append(createArgs, fmt.Sprintf("-u %s", createOpts.UID))
+		createArgs = append(createArgs, "-u", createOpts.UID)
 	}
 
 	if createOpts.GID != "" {
-		createArgs = append(createArgs, fmt.Sprintf("-g %s", createOpts.GID))
+		createArgs = append(createArgs, "-g", createOpts.GID)
 	}
 
 	if createOpts.HomeDir != "" {
-		createArgs = append(createArgs, fmt.Sprintf("-d %s", createOpts.HomeDir))
+		createArgs = append(createArgs, "-d", createOpts.HomeDir)
 	}
 
 	if createOpts.CreateHome {
-		createArgs = append(createArgs, fmt.Sprintf("-m"))
+		createArgs = append(createArgs, "-m")
 	}
 
 	if createOpts.Shell != "" {
-		createArgs = append(createArgs, fmt.Sprintf("-s %s", createOpts.Shell))
+		createArgs = append(createArgs, "-s", createOpts.Shell)
 	}
 
 	if createOpts.Passwd != "" {
-		createArgs = append(createArgs, fmt.Sprintf("-p \"%s\"", createOpts.Passwd))
+		createArgs = append(createArgs, "-p", createOpts.Passwd)
 	}
 
 	if createOpts.Comment != "" {
-		createArgs = append(createArgs, fmt.Sprintf("-p \"%s\"", createOpts.Comment))
+		createArgs = append(createArgs, "-c", createOpts.Comment)
 	}
 
 	if len(createOpts.Groups) > 0 {
 		v := strings.Join(createOpts.Groups, ",")
-		createArgs = append(createArgs, fmt.Sprintf("-G %s", v))
+		createArgs = append(createArgs, "-G", v)
 	}
 
 	if createOpts.System {
-		createArgs = append(createArgs, fmt.Sprintf("-r"))
+		createArgs = append(createArgs, "-r")
 	}
 
 	createArgs = append(createArgs, createOpts.Name)
-	eo.Command = fmt.Sprintf("useradd %s", strings.Join(createArgs, " "))
-	execResult, err := utils.Exec(eo)
+	eo.Args = append([]string{"useradd"}, createArgs...)
+	if client.Pending(Type, createOpts.Name, resources.ActionCreate, eo.String()) {
+		return
+	}
+
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -255,6 +292,13 @@ func Create(client client.Client, createOpts CreateOpts) (err error) {
 		return
 	}
 
+	if createOpts.Sudo {
+		err = userSudo(client, resources.ActionCreate, createOpts.Name, true)
+		if err != nil {
+			return
+		}
+	}
+
 	return
 }
 
@@ -276,35 +320,59 @@ func Update(client client.Client, name string, updateOpts UpdateOpts) (err error
 		return
 	}
 
+	if updateOpts.System && !user.System {
+		err = fmt.Errorf("Unable to update user %s: usermod cannot make an existing user a system account", name)
+		return
+	}
+
+	if updateOpts.Sudo != user.Sudo {
+		if err = userSudo(client, resources.ActionUpdate, name, updateOpts.Sudo); err != nil {
+			return
+		}
+	}
+
 	if updateOpts.UID != "" && updateOpts.UID != user.UID {
-		updateArgs = append(updateArgs, fmt.Sprintf("-u %s", updateOpts.UID))
+		updateArgs = append(updateArgs, "-u", updateOpts.UID)
 	}
 
 	if updateOpts.GID != "" && updateOpts.GID != user.GID {
-		updateArgs = append(updateArgs, fmt.Sprintf("-g %s", updateOpts.GID))
+		updateArgs = append(updateArgs, "-g", updateOpts.GID)
 	}
 
 	if updateOpts.Comment != "" && updateOpts.Comment != user.Comment {
-		updateArgs = append(updateArgs, fmt.Sprintf("-p \"%s\"", updateOpts.Comment))
+		updateArgs = append(updateArgs, "-c", updateOpts.Comment)
 	}
 
 	if updateOpts.HomeDir != "" && updateOpts.HomeDir != user.HomeDir {
-		updateArgs = append(updateArgs, fmt.Sprintf("-d %s", updateOpts.HomeDir))
+		updateArgs = append(updateArgs, "-d", updateOpts.HomeDir)
 	}
 
 	if updateOpts.Shell != "" && updateOpts.Shell != user.Shell {
-		updateArgs = append(updateArgs, fmt.Sprintf("-s %s", updateOpts.Shell))
+		updateArgs = append(updateArgs, "-s", updateOpts.Shell)
 	}
 
 	if len(updateOpts.Groups) > 0 {
 		v := strings.Join(updateOpts.Groups, ",")
-		updateArgs = append(updateArgs, fmt.Sprintf("-G %s", v))
+		updateArgs = append(updateArgs, "-G", v)
+	}
+
+	if updateOpts.Passwd != "" && updateOpts.Passwd != user.Passwd {
+		updateArgs = append(updateArgs, "-p", updateOpts.Passwd)
+	}
+
+	if len(updateArgs) == 0 {
+		client.Logger.Debugf("User %s does not need to be updated", name)
+		return
 	}
 
 	updateArgs = append(updateArgs, name)
 
-	eo.Command = fmt.Sprintf("usermod %s", strings.Join(updateArgs, " "))
-	execResult, err := utils.Exec(eo)
+	eo.Args = append([]string{"usermod"}, updateArgs...)
+	if client.Pending(Type, name, resources.ActionUpdate, eo.String()) {
+		return
+	}
+
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -323,8 +391,12 @@ func Delete(client client.Client, name string) (err error) {
 
 	client.Logger.Debugf("Deleting user %s", name)
 
-	eo.Command = fmt.Sprintf("userdel %s", name)
-	execResult, err := utils.Exec(eo)
+	eo.Args = []string{"userdel", name}
+	if client.Pending(Type, name, resources.ActionDelete, eo.String()) {
+		return
+	}
+
+	execResult, err := client.Exec(eo)
 	if err != nil {
 		return
 	}
@@ -337,16 +409,243 @@ func Delete(client client.Client, name string) (err error) {
 	return
 }
 
-func getent(ent, user string) (getent []string, err error) {
+// userSudo is an internal function that will give a user sudo rights
+// with a file in /etc/sudoers.d, or take them away by removing the file.
+// The action is what is being done to the user.
+func userSudo(client client.Client, action, name string, sudo bool) error {
+	sudoFile := fmt.Sprintf("/etc/sudoers.d/%s", name)
+
+	if !sudo {
+		if client.Pending(Type, name, action, fmt.Sprintf("rm %s", sudoFile)) {
+			return nil
+		}
+
+		return client.System().Remove(sudoFile, false)
+	}
+
+	content := fmt.Sprintf("%s ALL=(ALL) NOPASSWD:ALL\n", name)
+	if client.Pending(Type, name, action, fmt.Sprintf("write %q to %s", content, sudoFile)) {
+		return nil
+	}
+
+	writeOpts := executor.WriteOpts{
+		Mode:        0440,
+		ValidateCmd: "visudo -cf %s",
+	}
+
+	return client.WriteFile(sudoFile, []byte(content), writeOpts)
+}
+
+// userUIDMin is an internal function that will read the lowest UID of
+// regular users from /etc/login.defs. It is 1000 if it is not set.
+func userUIDMin(client client.Client) (uidMin int, err error) {
+	uidMin = 1000
+
+	lines, err := executor.ReadLines(client.System(), "/etc/login.defs")
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "UID_MIN" {
+			if v, err := strconv.Atoi(fields[1]); err == nil {
+				uidMin = v
+			}
+		}
+	}
+
+	return
+}
+
+// getent is an internal function that will return the fields of the
+// entry of a user in a database such as passwd or shadow. If there is no
+// entry, a NotFoundError is returned.
+func getent(client client.Client, ent, user string) (getent []string, err error) {
 	var eo utils.ExecOptions
 
-	eo.Command = fmt.Sprintf("getent %s %s", ent, user)
-	execResult, err := utils.Exec(eo)
+	// getent exits with a status of 2 if the key was not found.
+	eo.Args = []string{"getent", ent, user}
+	execResult, err := client.Exec(eo)
 	if err != nil {
+		if execResult.ExitStatus == 2 {
+			err = resources.NotFoundError{Type: Type, Name: user}
+		}
 		return
 	}
 
-	getent = strings.Split(execResult.Stdout, ":")
+	getent = strings.Split(strings.TrimSpace(execResult.Stdout), ":")
+
+	return
+}
+
+// userParseGroups is an internal function that will return the
+// supplementary groups of a user from the lines of /etc/group.
+func userParseGroups(lines []string, name string) (groups []string) {
+	for _, line := range lines {
+		parts := strings.Split(line, ":")
+		if len(parts) < 4 {
+			continue
+		}
+
+		for _, member := range strings.Split(parts[3], ",") {
+			if member == name {
+				groups = append(groups, parts[0])
+			}
+		}
+	}
+
+	return
+}
+
+// Resource represents the desired state of a user.
+// It implements the resources.Resource interface.
+type Resource struct {
+	resources.Meta
+	CreateOpts
+}
+
+var _ resources.Resource = Resource{}
+var _ resources.AutoRequirer = Resource{}
+
+// Apply will converge a user to its desired state.
+func Apply(client client.Client, r Resource) (resources.Change, error) {
+	return resources.Apply(client, r)
+}
+
+// Type returns the type of the resource.
+func (r Resource) Type() string {
+	return Type
+}
+
+// ID returns the name of the user.
+func (r Resource) ID() string {
+	return r.Name
+}
+
+// Read will read the user.
+func (r Resource) Read(client client.Client) (interface{}, error) {
+	return Read(client, r.Name)
+}
+
+// Exists will determine if the user exists.
+func (r Resource) Exists(client client.Client) (bool, error) {
+	return Exists(client, r.Name)
+}
+
+// Diff will compare an existing user to the desired state. The password
+// hash is not reported. A user which should be a system account is
+// reported, but it cannot be updated, since usermod cannot change it.
+func (r Resource) Diff(current interface{}) (diffs []resources.Diff) {
+	user, _ := current.(User)
+
+	desired := r.CreateOpts
+	if invalid := resources.Desired(&desired); invalid != nil {
+		return invalid
+	}
+
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"UID", user.UID, desired.UID},
+		{"GID", user.GID, desired.GID},
+		{"Shell", user.Shell, desired.Shell},
+		{"HomeDir", user.HomeDir, desired.HomeDir},
+		{"Comment", user.Comment, desired.Comment},
+	}
+
+	for _, f := range fields {
+		if f.new != "" && f.old != f.new {
+			diffs = append(diffs, resources.Diff{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+
+	if len(desired.Groups) > 0 {
+		oldGroups := append([]string{}, user.Groups...)
+		newGroups := append([]string{}, desired.Groups...)
+		sort.Strings(oldGroups)
+		sort.Strings(newGroups)
+
+		old := strings.Join(oldGroups, ",")
+		new := strings.Join(newGroups, ",")
+		if old != new {
+			diffs = append(diffs, resources.Diff{Field: "Groups", Old: old, New: new})
+		}
+	}
+
+	if desired.Passwd != "" && desired.Passwd != user.Passwd {
+		diffs = append(diffs, resources.Diff{Field: "Passwd", Old: "(hidden)", New: "(hidden)"})
+	}
+
+	if desired.Sudo != user.Sudo {
+		diffs = append(diffs, resources.Diff{Field: "Sudo", Old: strconv.FormatBool(user.Sudo), New: strconv.FormatBool(desired.Sudo)})
+	}
+
+	if desired.System && !user.System {
+		diffs = append(diffs, resources.Diff{Field: "System", Old: "false", New: "true"})
+	}
+
+	return
+}
+
+// Create will create the user.
+func (r Resource) Create(client client.Client) error {
+	return Create(client, r.CreateOpts)
+}
+
+// Update will update the differing fields of the user. Sudo and System
+// are always passed since they are the desired state of the user.
+func (r Resource) Update(client client.Client, diffs []resources.Diff) error {
+	desired := r.CreateOpts
+	if err := utils.BuildRequest(&desired); err != nil {
+		return err
+	}
+
+	updateOpts := UpdateOpts{
+		Sudo:   desired.Sudo,
+		System: desired.System,
+	}
+
+	for _, diff := range diffs {
+		switch diff.Field {
+		case "UID":
+			updateOpts.UID = desired.UID
+		case "GID":
+			updateOpts.GID = desired.GID
+		case "Shell":
+			updateOpts.Shell = desired.Shell
+		case "HomeDir":
+			updateOpts.HomeDir = desired.HomeDir
+		case "Comment":
+			updateOpts.Comment = desired.Comment
+		case "Groups":
+			updateOpts.Groups = desired.Groups
+		case "Passwd":
+			updateOpts.Passwd = desired.Passwd
+		}
+	}
+
+	return Update(client, r.Name, updateOpts)
+}
+
+// Delete will delete the user.
+func (r Resource) Delete(client client.Client) error {
+	return Delete(client, r.Name)
+}
+
+// AutoRequires returns the primary and supplementary groups of the user.
+func (r Resource) AutoRequires() (refs []resources.Ref) {
+	if r.GID != "" {
+		refs = append(refs, resources.Ref{Type: groupadd.Type, ID: r.GID})
+	}
+
+	for _, group := range r.Groups {
+		refs = append(refs, resources.Ref{Type: groupadd.Type, ID: group})
+	}
 
 	return
 }
@@ -1,10 +1,17 @@
 package useradd
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/jtopjian/craft/executor"
+	"github.com/jtopjian/craft/resources"
 	"github.com/jtopjian/craft/testhelper"
+	"github.com/jtopjian/craft/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,3 +44,147 @@ func Test_User_Apply(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, false, exists, "should be equal")
 }
+
+func Test_userParseGroups(t *testing.T) {
+	lines := []string{
+		"root:x:0:",
+		"sudo:x:27:foobar",
+		"docker:x:999:jdoe,foobar",
+		"foobar:x:1002:",
+		"staff:x:50:foobarbaz",
+	}
+
+	groups := userParseGroups(lines, "foobar")
+	assert.Equal(t, []string{"sudo", "docker"}, groups, "should be equal")
+}
+
+func Test_User_Comment(t *testing.T) {
+	// A fake useradd records its arguments, one per line.
+	dir, err := ioutil.TempDir("", "useradd")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	args := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + args + "\n"
+	err = ioutil.WriteFile(filepath.Join(dir, "useradd"), []byte(script), 0755)
+	assert.Nil(t, err)
+
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+":"+path)
+
+	createOpts := CreateOpts{
+		Name:    "jdoe",
+		Comment: "Jane",
+	}
+
+	err = Create(testhelper.TestClient(), createOpts)
+	assert.Nil(t, err)
+
+	content, err := ioutil.ReadFile(args)
+	assert.Nil(t, err)
+
+	lines := strings.Split(string(content), "\n")
+	assert.Contains(t, lines, "-c")
+	assert.NotContains(t, lines, "-p")
+}
+
+func Test_User_Read(t *testing.T) {
+	fake := executor.NewFake()
+	fake.AddFile("/etc/group", "sudo:x:27:deploy\n", 0644)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch {
+		case eo.Args[2] == "nobody-here":
+			er.ExitStatus = 2
+			err = fmt.Errorf("%s: exit status 2", eo.String())
+		case eo.Args[2] == "unreachable":
+			err = fmt.Errorf("ssh: connection reset")
+		case eo.Args[1] == "passwd":
+			er.Stdout = "deploy:x:1001:1001:Deploy:/home/deploy:/bin/bash\n"
+		case eo.Args[1] == "shadow":
+			er.ExitStatus = 2
+			err = fmt.Errorf("%s: exit status 2", eo.String())
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	user, err := Read(c, "deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, User{Name: "deploy", UID: "1001", GID: "1001", Comment: "Deploy", HomeDir: "/home/deploy", Shell: "/bin/bash", Groups: []string{"sudo"}}, user, "should be equal")
+
+	exists, err := Exists(c, "nobody-here")
+	assert.Nil(t, err)
+	assert.Equal(t, false, exists, "should be equal")
+
+	// A failure to run getent is not mistaken for a missing user.
+	_, err = Apply(c, Resource{CreateOpts: CreateOpts{Name: "unreachable"}})
+	assert.Equal(t, "ssh: connection reset", err.Error(), "should be equal")
+	for _, command := range fake.Commands {
+		assert.False(t, strings.HasPrefix(command, "useradd"), command)
+	}
+}
+
+func Test_User_Converge(t *testing.T) {
+	passwd := "$6$old"
+
+	fake := executor.NewFake()
+	fake.AddFile("/etc/group", "", 0644)
+	fake.Mkdir("/etc/sudoers.d", 0755, true)
+	fake.Handler = func(eo utils.ExecOptions) (er utils.ExecResult, err error) {
+		switch eo.Args[0] {
+		case "getent":
+			if eo.Args[1] == "passwd" {
+				er.Stdout = "deploy:x:1001:1001::/home/deploy:/bin/bash\n"
+			} else {
+				er.Stdout = "deploy:" + passwd + ":19000:0:99999:7:::\n"
+			}
+		case "usermod":
+			if eo.Args[1] == "-p" {
+				passwd = eo.Args[2]
+			}
+		}
+		return
+	}
+
+	c := testhelper.TestClient()
+	c.Executor = fake
+
+	r := Resource{
+		CreateOpts: CreateOpts{
+			Name:   "deploy",
+			Shell:  "/bin/bash",
+			Passwd: "$6$new",
+			Sudo:   true,
+		},
+	}
+
+	change, err := Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, "update", change.Action, "should be equal")
+	assert.Equal(t, []resources.Diff{
+		{Field: "Passwd", Old: "(hidden)", New: "(hidden)"},
+		{Field: "Sudo", Old: "false", New: "true"},
+	}, change.Diffs, "should be equal")
+	assert.Equal(t, "$6$new", passwd, "should be equal")
+	assert.Equal(t, "deploy ALL=(ALL) NOPASSWD:ALL\n", string(fake.Files["/etc/sudoers.d/deploy"].Content), "should be equal")
+	assert.Equal(t, os.FileMode(0440), fake.Files["/etc/sudoers.d/deploy"].Mode, "should be equal")
+
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, false, change.Changed(), "should be equal")
+
+	r.Sudo = false
+	change, err = Apply(c, r)
+	assert.Nil(t, err)
+	assert.Equal(t, []resources.Diff{{Field: "Sudo", Old: "true", New: "false"}}, change.Diffs, "should be equal")
+	_, ok := fake.Files["/etc/sudoers.d/deploy"]
+	assert.False(t, ok)
+
+	// An existing user cannot be made a system account.
+	r.System = true
+	_, err = Apply(c, r)
+	assert.Equal(t, "Unable to update user deploy: usermod cannot make an existing user a system account", err.Error(), "should be equal")
+}
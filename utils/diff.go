@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around a change.
+const diffContext = 3
+
+// diffMaxCells limits the size of the table used to compare two texts.
+// Texts which are larger are reported as differing without details.
+const diffMaxCells = 4000000
+
+// UnifiedDiff returns a unified diff between two texts. An empty string
+// is returned if the texts are equal.
+func UnifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	oldLines := diffSplitLines(oldText)
+	newLines := diffSplitLines(newText)
+
+	header := fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName)
+
+	if len(oldLines)*len(newLines) > diffMaxCells {
+		return header + fmt.Sprintf("@@ -1,%d +1,%d @@ texts are too large to compare\n",
+			len(oldLines), len(newLines))
+	}
+
+	ops := diffLines(oldLines, newLines)
+
+	var b strings.Builder
+	b.WriteString(header)
+	for _, hunk := range diffHunks(ops) {
+		b.WriteString(hunk)
+	}
+
+	return b.String()
+}
+
+// diffOp is an internal type that represents a line of a diff.
+type diffOp struct {
+	// Kind is one of ' ', '-', or '+'.
+	Kind byte
+	Line string
+}
+
+// diffSplitLines is an internal function that will split a text into
+// lines, noting a missing newline at the end of the text.
+func diffSplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	last := lines[len(lines)-1]
+	if !strings.HasSuffix(last, "\n") {
+		lines[len(lines)-1] = last + "\n\\ No newline at end of file\n"
+	}
+
+	return lines
+}
+
+// diffLines is an internal function that will compute the operations
+// which turn one list of lines into another using the longest common
+// subsequence of the lines.
+func diffLines(a, b []string) (ops []diffOp) {
+	n, m := len(a), len(b)
+
+	// lcs[i][j] is the length of the longest common subsequence
+	// of a[i:] and b[j:].
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{Kind: ' ', Line: a[i]})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{Kind: '+', Line: b[j]})
+			j++
+		default:
+			ops = append(ops, diffOp{Kind: '-', Line: a[i]})
+			i++
+		}
+	}
+
+	return
+}
+
+// diffHunks is an internal function that will group diff operations
+// into unified diff hunks.
+func diffHunks(ops []diffOp) (hunks []string) {
+	i := 0
+	for i < len(ops) {
+		// Find the next change.
+		for i < len(ops) && ops[i].Kind == ' ' {
+			i++
+		}
+
+		if i == len(ops) {
+			break
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk until there are more than twice the context
+		// of unchanged lines between changes.
+		end := i
+		unchanged := 0
+		for end < len(ops) && unchanged <= 2*diffContext {
+			if ops[end].Kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+
+		end -= unchanged
+		end += diffContext
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		hunks = append(hunks, diffFormatHunk(ops[start:end], ops[:start]))
+		i = end
+	}
+
+	return
+}
+
+// diffFormatHunk is an internal function that will format a hunk. The
+// preceding operations are used to determine the starting line numbers.
+func diffFormatHunk(ops, preceding []diffOp) string {
+	var oldStart, newStart, oldCount, newCount int
+
+	for _, op := range preceding {
+		if op.Kind != '+' {
+			oldStart++
+		}
+		if op.Kind != '-' {
+			newStart++
+		}
+	}
+
+	var body strings.Builder
+	for _, op := range ops {
+		if op.Kind != '+' {
+			oldCount++
+		}
+		if op.Kind != '-' {
+			newCount++
+		}
+		body.WriteByte(op.Kind)
+		body.WriteString(op.Line)
+	}
+
+	// Empty ranges start at the line before the change.
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+
+	return fmt.Sprintf("@@ -%s +%s @@\n%s",
+		diffRange(oldStart, oldCount), diffRange(newStart, newCount), body.String())
+}
+
+// diffRange is an internal function that will format a hunk range.
+func diffRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UnifiedDiff(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	newText := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+
+	expected := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -9,3 +9,4 @@
+ i
+ j
+ k
++l
+`
+
+	assert.Equal(t, expected, UnifiedDiff("old", "new", oldText, newText), "should be equal")
+	assert.Equal(t, "", UnifiedDiff("old", "new", oldText, oldText), "should be equal")
+
+	expected = `--- old
++++ new
+@@ -0,0 +1,2 @@
++foo
++bar
+\ No newline at end of file
+`
+
+	assert.Equal(t, expected, UnifiedDiff("old", "new", "", "foo\nbar"), "should be equal")
+}
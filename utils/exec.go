@@ -1,84 +1,229 @@
 package utils
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"syscall"
+	"time"
 )
 
+// ExecOptions represents options used to run a command.
 type ExecOptions struct {
+	// Args is the command and its arguments. Arguments are passed to
+	// the command as-is, so they may contain spaces and quotes.
+	Args []string
+
+	// Command is a command line which is split on whitespace. It is
+	// only used if Args is not set.
 	Command string
-	Dir     string
-	Env     []string
+
+	// Dir is the working directory of the command.
+	Dir string
+
+	// Env are entries such as "FOO=bar" which are added to the
+	// environment of the command.
+	Env []string
+
+	// Stdin is the standard input of the command.
+	Stdin io.Reader
+
+	// Context will kill the command when it is done.
+	Context context.Context
+
+	// Timeout will kill the command if it runs longer.
+	Timeout time.Duration
+
+	// OnStdout and OnStderr are called with every line of output as the
+	// command runs, without the trailing newline.
+	OnStdout func(line string)
+	OnStderr func(line string)
 }
 
+// Argv returns the command and its arguments.
+func (eo ExecOptions) Argv() []string {
+	if len(eo.Args) > 0 {
+		return eo.Args
+	}
+
+	return strings.Fields(eo.Command)
+}
+
+// String returns the command line with arguments quoted for a shell.
+func (eo ExecOptions) String() string {
+	var parts []string
+	for _, arg := range eo.Argv() {
+		parts = append(parts, ShellQuote(arg))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// ExecResult represents the result of a command.
 type ExecResult struct {
-	Stdout     string
-	Stderr     string
+	Stdout string
+	Stderr string
+
+	// ExitStatus is the exit status of the command. It is -1 if the
+	// command was killed by a signal.
 	ExitStatus int
+
+	// Signal is the name of the signal which killed the command,
+	// such as "killed".
+	Signal string
 }
 
-func Exec(eo ExecOptions) (ExecResult, error) {
-	var err error
-	var cmd *exec.Cmd
-	var er ExecResult
+// Exec will run a command on the local system. An error is returned if
+// the command could not be run, did not exit successfully, or was killed
+// because of its context or timeout.
+func Exec(eo ExecOptions) (er ExecResult, err error) {
+	argv := eo.Argv()
+	if len(argv) == 0 {
+		err = fmt.Errorf("No command given")
+		return
+	}
 
-	var stdout io.ReadCloser
-	var stderr io.ReadCloser
-	var stdoutBuf bytes.Buffer
-	var stderrBuf bytes.Buffer
+	ctx, cancel := ExecContext(eo)
+	defer cancel()
 
-	x := strings.Split(eo.Command, " ")
-	if len(x) > 1 {
-		cmd = exec.Command(x[0], x[1:]...)
-	} else {
-		cmd = exec.Command(x[0])
-	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = eo.Dir
+	cmd.Stdin = eo.Stdin
 
 	if len(eo.Env) > 0 {
-		cmd.Env = eo.Env
+		cmd.Env = append(os.Environ(), eo.Env...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	stdoutLines := NewLineWriter(eo.OnStdout)
+	stderrLines := NewLineWriter(eo.OnStderr)
+	cmd.Stdout = io.MultiWriter(&stdout, stdoutLines)
+	cmd.Stderr = io.MultiWriter(&stderr, stderrLines)
+
+	err = cmd.Run()
+	stdoutLines.Flush()
+	stderrLines.Flush()
+
+	er.Stdout = stdout.String()
+	er.Stderr = stderr.String()
+
+	if cmd.ProcessState != nil {
+		er.ExitStatus = cmd.ProcessState.ExitCode()
+		if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			er.Signal = ws.Signal().String()
+		}
 	}
 
-	if eo.Dir != "" {
-		cmd.Dir = eo.Dir
+	err = ExecError(ctx, eo, er, err)
+
+	return
+}
+
+// ExecContext returns the context in which a command runs, taking its
+// timeout into account. The cancel function must be called once the
+// command has finished.
+func ExecContext(eo ExecOptions) (context.Context, context.CancelFunc) {
+	ctx := eo.Context
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
-	stdout, err = cmd.StdoutPipe()
-	if err != nil {
-		return er, err
+	if eo.Timeout > 0 {
+		return context.WithTimeout(ctx, eo.Timeout)
 	}
 
-	stderr, err = cmd.StderrPipe()
-	if err != nil {
-		return er, err
+	return context.WithCancel(ctx)
+}
+
+// ExecError returns a descriptive error for a command which has run.
+// The error given is the one returned when running the command.
+func ExecError(ctx context.Context, eo ExecOptions, er ExecResult, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil && (err != nil || er.ExitStatus != 0) {
+		return fmt.Errorf("%s: %w", eo.String(), ctxErr)
 	}
 
-	if err := cmd.Start(); err != nil {
-		return er, err
+	if er.Signal != "" {
+		return fmt.Errorf("%s: killed by signal: %s", eo.String(), er.Signal)
 	}
 
-	if _, err := bufio.NewReader(stderr).WriteTo(&stderrBuf); err != nil {
-		return er, err
+	if er.ExitStatus != 0 {
+		msg := fmt.Sprintf("%s: exit status %d", eo.String(), er.ExitStatus)
+		if stderr := strings.TrimSpace(er.Stderr); stderr != "" {
+			msg = fmt.Sprintf("%s: %s", msg, stderr)
+		}
+		return fmt.Errorf("%s", msg)
 	}
 
-	if _, err := bufio.NewReader(stdout).WriteTo(&stdoutBuf); err != nil {
-		return er, err
+	return err
+}
+
+// LineWriter is an io.Writer which calls a function with every line
+// written to it.
+type LineWriter struct {
+	fn      func(line string)
+	partial []byte
+}
+
+// NewLineWriter returns a LineWriter which calls fn. If fn is nil, the
+// output is discarded.
+func NewLineWriter(fn func(line string)) *LineWriter {
+	return &LineWriter{fn: fn}
+}
+
+// Write calls the function for every complete line. Incomplete lines
+// are kept until they are completed or Flush is called.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	if w.fn == nil {
+		return len(p), nil
 	}
 
-	if err := cmd.Wait(); err != nil {
-		if exit, ok := err.(*exec.ExitError); ok {
-			er.ExitStatus = int(exit.ProcessState.Sys().(syscall.WaitStatus) / 256)
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
 		}
+
+		w.fn(strings.TrimSuffix(string(w.partial[:i]), "\r"))
+		w.partial = w.partial[i+1:]
 	}
 
-	er.Stdout = stdoutBuf.String()
-	er.Stderr = stderrBuf.String()
+	return len(p), nil
+}
+
+// Flush calls the function with an incomplete last line, if any.
+func (w *LineWriter) Flush() {
+	if w.fn != nil && len(w.partial) > 0 {
+		w.fn(string(w.partial))
+	}
+
+	w.partial = nil
+}
+
+// ShellQuote quotes an argument for a POSIX shell.
+func ShellQuote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+
+	safe := true
+	for _, r := range arg {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("-_./=:,+@%", r)) {
+			safe = false
+			break
+		}
+	}
+
+	if safe {
+		return arg
+	}
 
-	return er, err
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
 }
 
 func RequiredCommands(commands []string) error {
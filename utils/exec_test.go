@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Exec(t *testing.T) {
+	eo := ExecOptions{
+		Args: []string{"sh", "-c", `printf '%s\n' "$1"; cat; echo oops >&2`, "sh", "Some Comment"},
+		Env:  []string{"FOO=bar"},
+	}
+	eo.Stdin = strings.NewReader("from stdin\n")
+
+	var lines []string
+	eo.OnStdout = func(line string) {
+		lines = append(lines, line)
+	}
+
+	er, err := Exec(eo)
+	assert.Nil(t, err)
+	assert.Equal(t, "Some Comment\nfrom stdin\n", er.Stdout, "should be equal")
+	assert.Equal(t, "oops\n", er.Stderr, "should be equal")
+	assert.Equal(t, []string{"Some Comment", "from stdin"}, lines, "should be equal")
+
+	er, err = Exec(ExecOptions{Args: []string{"sh", "-c", "exit 3"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, er.ExitStatus, "should be equal")
+
+	er, err = Exec(ExecOptions{Args: []string{"sh", "-c", "kill -9 $$"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, "killed", er.Signal, "should be equal")
+}
+
+func Test_Exec_Timeout(t *testing.T) {
+	start := time.Now()
+	_, err := Exec(ExecOptions{
+		Args:    []string{"sleep", "10"},
+		Timeout: 100 * time.Millisecond,
+	})
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), context.DeadlineExceeded.Error()))
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func Test_Exec_LargeOutput(t *testing.T) {
+	// Filling both pipes must not block the command.
+	script := `i=0; while [ $i -lt 20000 ]; do echo out; echo err >&2; i=$((i+1)); done`
+	er, err := Exec(ExecOptions{Args: []string{"sh", "-c", script}})
+	assert.Nil(t, err)
+	assert.Equal(t, 20000*4, len(er.Stdout), "should be equal")
+	assert.Equal(t, 20000*4, len(er.Stderr), "should be equal")
+}
+
+func Test_ExecOptions_String(t *testing.T) {
+	eo := ExecOptions{Args: []string{"useradd", "-c", "Some Comment", "foo"}}
+	assert.Equal(t, "useradd -c 'Some Comment' foo", eo.String(), "should be equal")
+}
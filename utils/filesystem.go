@@ -21,6 +21,9 @@ func FileGetLines(fileName string) (lines []string, err error) {
 	return
 }
 
+// WriteFile writes content to an existing file in place.
+//
+// Deprecated: Use client.WriteFile, which replaces files atomically.
 func WriteFile(fileName, content string) (err error) {
 	fi, err := os.Stat(fileName)
 	if err != nil {
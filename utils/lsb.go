@@ -4,6 +4,9 @@ import (
 	"regexp"
 )
 
+// LSBInfo represents the output of "lsb_release -a".
+//
+// Deprecated: Use facts.OS, which does not require lsb_release.
 type LSBInfo struct {
 	DistributorID string
 	Description   string
@@ -11,10 +14,14 @@ type LSBInfo struct {
 	Codename      string
 }
 
+// GetLSBInfo runs "lsb_release -a" on the local system.
+//
+// Deprecated: Use facts.ReadOS, which reads /etc/os-release on the system
+// managed by a client and does not require lsb_release.
 func GetLSBInfo() (LSBInfo, error) {
 	var eo ExecOptions
 
-	eo.Command = "lsb_release -a"
+	eo.Args = []string{"lsb_release", "-a"}
 	execResult, err := Exec(eo)
 	if err != nil {
 		return LSBInfo{}, err
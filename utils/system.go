@@ -44,7 +44,7 @@ func UIDToName(uid int) (username string, err error) {
 		return
 	}
 
-	username = v.Uid
+	username = v.Username
 	return
 }
 
@@ -67,7 +67,7 @@ func GIDToName(gid int) (group string, err error) {
 		return
 	}
 
-	group = v.Gid
+	group = v.Name
 	return
 }
 
@@ -93,8 +93,9 @@ func GetFileOwner(path string) (uid, gid int, err error) {
 	return
 }
 
+// StringToMode converts an octal string such as "0640" to a file mode.
 func StringToMode(v string) (mode os.FileMode, err error) {
-	m, err := strconv.Atoi(v)
+	m, err := strconv.ParseUint(v, 8, 32)
 	if err != nil {
 		return
 	}
@@ -103,3 +104,8 @@ func StringToMode(v string) (mode os.FileMode, err error) {
 
 	return
 }
+
+// ModeToString converts a file mode to an octal string such as "0640".
+func ModeToString(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", mode.Perm())
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,3 +18,22 @@ func Test_GroupToID(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, gid, 0, "should be equal")
 }
+
+func Test_UIDToName(t *testing.T) {
+	name, err := UIDToName(0)
+	assert.Nil(t, err)
+	assert.Equal(t, name, "root", "should be equal")
+}
+
+func Test_GIDToName(t *testing.T) {
+	name, err := GIDToName(0)
+	assert.Nil(t, err)
+	assert.Equal(t, name, "root", "should be equal")
+}
+
+func Test_StringToMode(t *testing.T) {
+	mode, err := StringToMode("0640")
+	assert.Nil(t, err)
+	assert.Equal(t, mode, os.FileMode(0640), "should be equal")
+	assert.Equal(t, ModeToString(mode), "0640", "should be equal")
+}
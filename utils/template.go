@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs are the functions available to templates in addition to
+// the built-in functions of text/template.
+var TemplateFuncs = template.FuncMap{
+	"join":    templateJoin,
+	"split":   strings.Split,
+	"indent":  templateIndent,
+	"default": templateDefault,
+	"toJSON":  templateToJSON,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"replace": templateReplace,
+	"quote":   strconv.Quote,
+}
+
+// TemplateError is returned when a template could not be rendered.
+type TemplateError struct {
+	// Name is the name of the template.
+	Name string
+
+	// Line is the line of the template where the error occurred.
+	// It is 0 if the line is not known.
+	Line int
+
+	// Message describes the error.
+	Message string
+}
+
+func (e TemplateError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.Name, e.Line, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Name, e.Message)
+}
+
+// templateErrorRe matches the errors of text/template, such as
+// "template: name:3:12: executing ..." or "template: name:3: unexpected ...".
+var templateErrorRe = regexp.MustCompile(`^template: (.*?):(\d+):(?:\d+:)? (.*)$`)
+
+// RenderTemplate will render a text/template with the given data. Using
+// a key which is missing from a map is an error; the index function can
+// be used for optional keys.
+//
+// A TemplateError is returned if the template could not be parsed or
+// rendered.
+func RenderTemplate(name, text string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(TemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", templateError(name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", templateError(name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// templateError is an internal function that will convert an error of
+// text/template to a TemplateError.
+func templateError(name string, err error) error {
+	e := TemplateError{Name: name, Message: err.Error()}
+
+	if v := templateErrorRe.FindStringSubmatch(err.Error()); v != nil {
+		e.Line, _ = strconv.Atoi(v[2])
+		e.Message = v[3]
+
+		// Execution errors start with the name of the template, as in
+		// executing "name" at <.Vars.port>: map has no entry ...
+		if i := strings.Index(e.Message, " at <"); strings.HasPrefix(e.Message, "executing ") && i > 0 {
+			e.Message = e.Message[i+4:]
+		}
+	}
+
+	return e
+}
+
+// templateJoin is an internal function that will join the items of a
+// list, such as a []string or a []interface{} from a manifest.
+func templateJoin(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a list, got %T", list)
+	}
+
+	var items []string
+	for i := 0; i < v.Len(); i++ {
+		items = append(items, fmt.Sprint(v.Index(i).Interface()))
+	}
+
+	return strings.Join(items, sep), nil
+}
+
+// templateIndent is an internal function that will indent every line
+// of a text by a number of spaces.
+func templateIndent(spaces int, text string) string {
+	pad := strings.Repeat(" ", spaces)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// templateDefault is an internal function that will return a default
+// value if a value is empty, such as "", 0, or nil.
+func templateDefault(def, value interface{}) interface{} {
+	if value == nil {
+		return def
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.String:
+		if v.Len() == 0 {
+			return def
+		}
+	default:
+		if v.IsZero() {
+			return def
+		}
+	}
+
+	return value
+}
+
+// templateToJSON is an internal function that will encode a value as JSON.
+func templateToJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// templateReplace is an internal function that will replace every
+// occurrence of old with new. The text comes last so that it can be piped.
+func templateReplace(old, new, text string) string {
+	return strings.Replace(text, old, new, -1)
+}
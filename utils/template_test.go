@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RenderTemplate(t *testing.T) {
+	text := `listen {{ .port | default 8080 }}
+servers {{ join "," .servers }}
+{{ if .debug }}debug: {{ toJSON .debug }}{{ end }}
+{{ indent 2 "a\nb" }}
+{{ index . "missing" | default "none" | upper }}`
+
+	data := map[string]interface{}{
+		"port":    0,
+		"servers": []interface{}{"a", "b"},
+		"debug":   true,
+	}
+
+	actual, err := RenderTemplate("app.conf", text, data)
+	assert.Nil(t, err)
+
+	expected := "listen 8080\nservers a,b\ndebug: true\n  a\n  b\nNONE"
+	assert.Equal(t, expected, actual, "should be equal")
+}
+
+func Test_RenderTemplate_Errors(t *testing.T) {
+	_, err := RenderTemplate("app.conf", "a\nb {{ .port }}\n", map[string]interface{}{})
+	expected := TemplateError{
+		Name:    "app.conf",
+		Line:    2,
+		Message: `<.port>: map has no entry for key "port"`,
+	}
+	assert.Equal(t, expected, err, "should be equal")
+	assert.Equal(t, `app.conf:2: <.port>: map has no entry for key "port"`, err.Error(), "should be equal")
+
+	_, err = RenderTemplate("app.conf", "a\n\n{{ if }}\n", nil)
+	assert.Equal(t, "app.conf:3: missing value for if", err.Error(), "should be equal")
+}
@@ -6,6 +6,16 @@ import (
 	"time"
 )
 
+// MissingInputError is returned by BuildRequest when a required field
+// is not set.
+type MissingInputError struct {
+	Field string
+}
+
+func (e MissingInputError) Error() string {
+	return fmt.Sprintf("Missing input: %s", e.Field)
+}
+
 // BuildRequest
 func BuildRequest(opts interface{}) (err error) {
 	vValue := reflect.ValueOf(opts)
@@ -27,7 +37,7 @@ func BuildRequest(opts interface{}) (err error) {
 
 			if requiredTag := tField.Tag.Get("required"); requiredTag == "true" {
 				if zero {
-					return fmt.Errorf("Missing input: %s", tField.Name)
+					return MissingInputError{Field: tField.Name}
 				}
 			}
 